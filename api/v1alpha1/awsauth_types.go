@@ -29,6 +29,62 @@ type AWSAuthSpec struct {
 	// MapUsers holds a list of MapUserItem
 	//+kubebuilder:validation:Optional
 	MapUsers []MapUserItem `json:"mapUsers,omitempty" yaml:"mapUsers,omitempty"`
+
+	// MapAccounts holds a list of AWS account IDs
+	//+kubebuilder:validation:Optional
+	MapAccounts []string `json:"mapAccounts,omitempty" yaml:"mapAccounts,omitempty"`
+
+	// Mode defines how the items are reconciled, either into the aws-auth ConfigMap
+	// or as EKS access entries
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:default=ConfigMap
+	Mode AWSAuthMode `json:"mode,omitempty" yaml:"mode,omitempty"`
+
+	// AccessEntries configures the EKS cluster for the AccessEntries mode
+	//+kubebuilder:validation:Optional
+	AccessEntries *AWSAuthAccessEntries `json:"accessEntries,omitempty" yaml:"accessEntries,omitempty"`
+}
+
+// AWSAuthMode defines how the items of AWSAuth are reconciled
+// +kubebuilder:validation:Enum=ConfigMap;AccessEntries
+type AWSAuthMode string
+
+const (
+	// AWSAuthModeConfigMap reconciles the items into the aws-auth ConfigMap.
+	AWSAuthModeConfigMap AWSAuthMode = "ConfigMap"
+	// AWSAuthModeAccessEntries reconciles the items as EKS access entries.
+	// mapAccounts are not supported by access entries and are ignored.
+	AWSAuthModeAccessEntries AWSAuthMode = "AccessEntries"
+)
+
+// AWSAuthAccessEntries defines the EKS cluster of the access entries
+type AWSAuthAccessEntries struct {
+	// ClusterName is the name of the EKS cluster
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	ClusterName string `json:"clusterName" yaml:"clusterName"`
+
+	// Credentials to manage the access entries
+	//+kubebuilder:validation:Optional
+	Credentials *Credentials `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+}
+
+// AccessPolicy defines an EKS access policy associated with an access entry
+type AccessPolicy struct {
+	// The ARN of the access policy
+	//+kubebuilder:validation:Required
+	//+kubebuilder:validation:MinLength=1
+	PolicyArn string `json:"policyArn" yaml:"policyArn"`
+
+	// The type of the access scope, either cluster or namespace
+	//+kubebuilder:validation:Optional
+	//+kubebuilder:validation:Enum=cluster;namespace
+	//+kubebuilder:default=cluster
+	AccessScopeType string `json:"accessScopeType,omitempty" yaml:"accessScopeType,omitempty"`
+
+	// The namespaces of the namespace access scope
+	//+kubebuilder:validation:Optional
+	Namespaces []string `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
 }
 
 // MapRoleItem defines the mapRole item of AWSAuth
@@ -47,6 +103,10 @@ type MapRoleItem struct {
 	//+kubebuilder:validation:Required
 	//+kubebuilder:validation:MinItems=1
 	Groups []string `json:"groups" yaml:"groups"`
+
+	// A list of access policies associated with the access entry of the role (AccessEntries mode only)
+	//+kubebuilder:validation:Optional
+	AccessPolicies []AccessPolicy `json:"accessPolicies,omitempty" yaml:"accessPolicies,omitempty"`
 }
 
 // MapUserItem defines the mapUser item of AWSAuth
@@ -65,11 +125,19 @@ type MapUserItem struct {
 	//+kubebuilder:validation:Required
 	//+kubebuilder:validation:MinItems=1
 	Groups []string `json:"groups" yaml:"groups"`
+
+	// A list of access policies associated with the access entry of the user (AccessEntries mode only)
+	//+kubebuilder:validation:Optional
+	AccessPolicies []AccessPolicy `json:"accessPolicies,omitempty" yaml:"accessPolicies,omitempty"`
 }
 
 // AWSAuthStatus defines the observed state of AWSAuth
 type AWSAuthStatus struct {
 	ConditionedStatus `json:",inline"`
+
+	// Mode is the mode the items were last reconciled in
+	//+kubebuilder:validation:Optional
+	Mode AWSAuthMode `json:"mode,omitempty" yaml:"mode,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -97,6 +165,12 @@ func init() {
 	SchemeBuilder.Register(&AWSAuth{}, &AWSAuthList{})
 }
 
+// OwnsAccessEntries checks whether EKS access entries managed by the AWSAuth may remain: access entries
+// are configured and they were not removed yet in ConfigMap mode, which is recorded in the status.
+func (r *AWSAuth) OwnsAccessEntries() bool {
+	return r.Spec.AccessEntries != nil && r.Status.Mode != AWSAuthModeConfigMap
+}
+
 // GetSpec returns spec of AWSAuth
 func (r *AWSAuth) GetSpec() any {
 	return &r.Spec
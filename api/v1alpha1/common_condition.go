@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	"fmt"
 	"sort"
 
 	corev1 "k8s.io/api/core/v1"
@@ -18,6 +19,18 @@ const (
 	// TypeSynced resources are believed to be in sync with the
 	// Kubernetes resources that manage their lifecycle.
 	TypeSynced ConditionType = "Synced"
+
+	// TypeConflicted resources have entries that conflict with
+	// entries owned by someone else.
+	TypeConflicted ConditionType = "Conflicted"
+
+	// TypeDrifted resources were changed outside of the controller, and
+	// the changes were reverted by the most recent reconciliation.
+	TypeDrifted ConditionType = "Drifted"
+
+	// TypePolicyValid resources have policies which passed validation
+	// before being applied to the cloud provider.
+	TypePolicyValid ConditionType = "PolicyValid"
 )
 
 // A ConditionReason represents the reason a resource is in a condition.
@@ -35,6 +48,25 @@ const (
 const (
 	ReasonReconcileSuccess ConditionReason = "ReconcileSuccess"
 	ReasonReconcileError   ConditionReason = "ReconcileError"
+	ReasonDryRun           ConditionReason = "DryRun"
+)
+
+// Reasons a resource is or is not conflicted.
+const (
+	ReasonConflict   ConditionReason = "Conflict"
+	ReasonNoConflict ConditionReason = "NoConflict"
+)
+
+// Reasons a resource is or is not drifted.
+const (
+	ReasonDrifted ConditionReason = "Drifted"
+	ReasonNoDrift ConditionReason = "NoDrift"
+)
+
+// Reasons the policies of a resource are or are not valid.
+const (
+	ReasonPolicyValid   ConditionReason = "PolicyValid"
+	ReasonPolicyInvalid ConditionReason = "PolicyInvalid"
 )
 
 // A Condition that may apply to a resource.
@@ -241,3 +273,84 @@ func ReconcileError(err error) Condition {
 		Message:            err.Error(),
 	}
 }
+
+// DryRun returns a condition indicating that the most recent reconciliation
+// of the resource only planned the changes, without applying them.
+func DryRun(changes int) Condition {
+	return Condition{
+		Type:               TypeSynced,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDryRun,
+		Message:            fmt.Sprintf("%d change(s) planned, see status.plan", changes),
+	}
+}
+
+// Conflicted returns a condition indicating that some of the entries of the
+// resource conflict with existing entries and were not applied.
+func Conflicted(msg string) Condition {
+	return Condition{
+		Type:               TypeConflicted,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonConflict,
+		Message:            msg,
+	}
+}
+
+// NoConflict returns a condition indicating that all the entries of the
+// resource were applied without conflicts.
+func NoConflict() Condition {
+	return Condition{
+		Type:               TypeConflicted,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNoConflict,
+	}
+}
+
+// Drifted returns a condition indicating that the resource was changed outside
+// of the controller, and that the changes were reverted.
+func Drifted(msg string) Condition {
+	return Condition{
+		Type:               TypeDrifted,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDrifted,
+		Message:            msg,
+	}
+}
+
+// NoDrift returns a condition indicating that the resource was not changed
+// outside of the controller.
+func NoDrift() Condition {
+	return Condition{
+		Type:               TypeDrifted,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNoDrift,
+	}
+}
+
+// PolicyValid returns a condition indicating that the policies of the
+// resource passed validation.
+func PolicyValid() Condition {
+	return Condition{
+		Type:               TypePolicyValid,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonPolicyValid,
+	}
+}
+
+// PolicyInvalid returns a condition indicating that some of the policies of
+// the resource are invalid and were not applied.
+func PolicyInvalid(msg string) Condition {
+	return Condition{
+		Type:               TypePolicyValid,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonPolicyInvalid,
+		Message:            msg,
+	}
+}
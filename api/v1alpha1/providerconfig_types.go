@@ -0,0 +1,165 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ProviderConfigKind is the kind of ProviderConfig
+	ProviderConfigKind = "ProviderConfig"
+	// ClusterProviderConfigKind is the kind of ClusterProviderConfig
+	ClusterProviderConfigKind = "ClusterProviderConfig"
+)
+
+// ProviderConfigSpec defines the credentials and the defaults of a cloud provider,
+// shared by the WorkloadIdentities which reference it
+type ProviderConfigSpec struct {
+	// Provider of the credentials
+	// +required
+	Provider Provider `json:"provider"`
+	// Credentials of the provider. The namespace of the secret defaults to the namespace
+	// of the ProviderConfig and is required for a ClusterProviderConfig.
+	// +optional
+	Credentials *Credentials `json:"credentials,omitempty"`
+	// Region is the default AWS region
+	// +optional
+	Region string `json:"region,omitempty"`
+	// Location is the default Azure or GCP location
+	// +optional
+	Location string `json:"location,omitempty"`
+	// Project is the default GCP project
+	// +optional
+	Project string `json:"project,omitempty"`
+	// ResourceGroup is the default Azure resource group
+	// +optional
+	ResourceGroup string `json:"resourceGroup,omitempty"`
+	// NamePrefix of the identities, instead of the one of the manager
+	// +optional
+	NamePrefix string `json:"namePrefix,omitempty"`
+	// Tags of the identities, in addition to the ones of the manager
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// ProviderConfigStatus defines the observed state of ProviderConfig
+type ProviderConfigStatus struct {
+	ConditionedStatus `json:",inline"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.provider`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=='Ready')].status`
+
+// ProviderConfig is the Schema for the providerconfigs API
+type ProviderConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProviderConfigSpec   `json:"spec,omitempty"`
+	Status ProviderConfigStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ProviderConfigList contains a list of ProviderConfig
+type ProviderConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProviderConfig `json:"items"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.provider`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=='Ready')].status`
+
+// ClusterProviderConfig is the Schema for the clusterproviderconfigs API
+type ClusterProviderConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProviderConfigSpec   `json:"spec,omitempty"`
+	Status ProviderConfigStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterProviderConfigList contains a list of ClusterProviderConfig
+type ClusterProviderConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterProviderConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProviderConfig{}, &ProviderConfigList{})
+	SchemeBuilder.Register(&ClusterProviderConfig{}, &ClusterProviderConfigList{})
+}
+
+// GetSpec returns spec of ProviderConfig
+func (r *ProviderConfig) GetSpec() any {
+	return &r.Spec
+}
+
+// GetStatus returns status of ProviderConfig
+func (r *ProviderConfig) GetStatus() any {
+	return &r.Status
+}
+
+// GetSpecCopy returns spec's copy of ProviderConfig
+func (r *ProviderConfig) GetSpecCopy() any {
+	return r.Spec.DeepCopy()
+}
+
+// GetStatusCopy returns status's copy of ProviderConfig
+func (r *ProviderConfig) GetStatusCopy() any {
+	return r.Status.DeepCopy()
+}
+
+// GetConditionedStatus returns condition status of ProviderConfig
+func (r *ProviderConfig) GetConditionedStatus() *ConditionedStatus {
+	return &r.Status.ConditionedStatus
+}
+
+// GetSpec returns spec of ClusterProviderConfig
+func (r *ClusterProviderConfig) GetSpec() any {
+	return &r.Spec
+}
+
+// GetStatus returns status of ClusterProviderConfig
+func (r *ClusterProviderConfig) GetStatus() any {
+	return &r.Status
+}
+
+// GetSpecCopy returns spec's copy of ClusterProviderConfig
+func (r *ClusterProviderConfig) GetSpecCopy() any {
+	return r.Spec.DeepCopy()
+}
+
+// GetStatusCopy returns status's copy of ClusterProviderConfig
+func (r *ClusterProviderConfig) GetStatusCopy() any {
+	return r.Status.DeepCopy()
+}
+
+// GetConditionedStatus returns condition status of ClusterProviderConfig
+func (r *ClusterProviderConfig) GetConditionedStatus() *ConditionedStatus {
+	return &r.Status.ConditionedStatus
+}
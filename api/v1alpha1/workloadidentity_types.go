@@ -22,7 +22,8 @@ import (
 
 // WorkloadIdentitySpec defines the desired state of WorkloadIdentity
 type WorkloadIdentitySpec struct {
-	// Name of the WorkloadIdentity
+	// Name of the WorkloadIdentity.
+	// Identities are never renamed: the name cannot change once the identity is created.
 	// +optional
 	Name string `json:"name,omitempty"`
 	// DisplayName of the WorkloadIdentity
@@ -34,6 +35,10 @@ type WorkloadIdentitySpec struct {
 	// Credentials to manage the WorkloadIdentity
 	// +optional
 	Credentials *Credentials `json:"credentials,omitempty"`
+	// ProviderConfigRef references the ProviderConfig or ClusterProviderConfig of the credentials
+	// and the defaults of the provider. Credentials take precedence over the ones of the provider config.
+	// +optional
+	ProviderConfigRef *ProviderConfigRef `json:"providerConfigRef,omitempty"`
 	// Provider of the WorkloadIdentity
 	// +required
 	Provider Provider `json:"provider"`
@@ -88,22 +93,53 @@ const (
 	// CredentialsSourceSecret indicates that a provider should acquire
 	// credentials from a secret.
 	CredentialsSourceSecret CredentialsSource = "Secret"
+
+	// CredentialsSourceInjectedIdentity indicates that a provider should use the
+	// identity injected into the controller: IRSA or EKS Pod Identity, Azure managed
+	// identity or GKE workload identity.
+	CredentialsSourceInjectedIdentity CredentialsSource = "InjectedIdentity"
+
+	// CredentialsSourceEnvironment indicates that a provider should acquire
+	// credentials from the environment variables of the controller.
+	CredentialsSourceEnvironment CredentialsSource = "Environment"
+
+	// CredentialsSourceFilesystem indicates that a provider should acquire
+	// credentials from a file mounted into the controller.
+	CredentialsSourceFilesystem CredentialsSource = "Filesystem"
 )
 
 // Credentials defines the credentials of the cloud provider
 type Credentials struct {
 	// Source of the credentials
-	// +kubebuilder:validation:Enum=Secret
+	// +kubebuilder:validation:Enum=Secret;InjectedIdentity;Environment;Filesystem
 	// +optional
 	Source CredentialsSource `json:"source,omitempty"`
-	// SecretRef to fetch the credentials
+	// SecretRef to fetch the credentials, for the Secret source
 	// +optional
 	SecretRef *SecretRef `json:"secretRef,omitempty"`
+	// Path of the file mounted into the controller, for the Filesystem source: a shared credentials
+	// file or a web identity token, as set by the file_type property, for AWS, a client certificate
+	// for Azure, a service account key or an external account configuration for GCP.
+	// It must be in the directory set by the --credentials-dir flag of the controller.
+	// +optional
+	Path string `json:"path,omitempty"`
 	// Properties indicates extra properties of credentials
 	// +optional
 	Properties map[string]string `json:"properties,omitempty"`
 }
 
+// ProviderConfigRef references a ProviderConfig in the namespace of the WorkloadIdentity, or a ClusterProviderConfig
+type ProviderConfigRef struct {
+	// Kind of the provider config
+	// +kubebuilder:validation:Enum=ProviderConfig;ClusterProviderConfig
+	// +kubebuilder:default=ProviderConfig
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Name of the provider config
+	// +required
+	Name string `json:"name"`
+}
+
 // SecretRef defines the reference to the secret
 type SecretRef struct {
 	// Namespace of the secret.
@@ -289,15 +325,28 @@ type WorkloadIdentityAWS struct {
 	// MaxSessionDuration of the Role
 	// +optional
 	MaxSessionDuration int64 `json:"maxSessionDuration,omitempty"`
-	// AssumeRolePolicy of the Role
-	// +required
-	AssumeRolePolicy string `json:"assumeRolePolicy"`
+	// AssumeRolePolicy of the Role.
+	// Generated when not set, either for PodIdentity if enabled, or else for the ServiceAccounts
+	// using the cluster's OIDC provider (oidc_provider credentials property or --aws-oidc-provider flag).
+	// Policy documents are templates: <(accountID), <(region), <(namespace), <(name),
+	// <(roleName) and <(clusterName) are replaced with the values of the WorkloadIdentity.
+	// +optional
+	AssumeRolePolicy string `json:"assumeRolePolicy,omitempty"`
+	// AssumeRolePolicyFrom reads the AssumeRolePolicy from a ConfigMap or a Secret, when AssumeRolePolicy is not set
+	// +optional
+	AssumeRolePolicyFrom *PolicyValueFrom `json:"assumeRolePolicyFrom,omitempty"`
 	// InlinePolicies of the Role
 	// +optional
 	InlinePolicies map[string]string `json:"inlinePolicies,omitempty"`
+	// InlinePoliciesFrom are InlinePolicies read from ConfigMaps or Secrets, keyed by policy name
+	// +optional
+	InlinePoliciesFrom map[string]PolicyValueFrom `json:"inlinePoliciesFrom,omitempty"`
 	// Policies of the Role
 	// +optional
 	Policies []string `json:"policies,omitempty"`
+	// ManagedPolicies are the customer managed policies created for the Role, keyed by policy name
+	// +optional
+	ManagedPolicies map[string]string `json:"managedPolicies,omitempty"`
 	// PermissionsBoundaryARN of Role
 	// +optional
 	PermissionsBoundaryARN string `json:"permissionsBoundaryARN,omitempty"`
@@ -307,6 +356,63 @@ type WorkloadIdentityAWS struct {
 	// Pods to be managed
 	// +optional
 	Pods []*PodSelector `json:"pods,omitempty"`
+	// ClusterAccess maps the Role into the cluster through an AWSAuth
+	// +optional
+	ClusterAccess *AWSClusterAccess `json:"clusterAccess,omitempty"`
+	// PodIdentity associates the ServiceAccounts with the Role using EKS Pod Identity instead of IRSA
+	// +optional
+	PodIdentity *AWSPodIdentity `json:"podIdentity,omitempty"`
+}
+
+// PolicyValueFrom selects a policy document from a ConfigMap or a Secret
+// in the namespace of the WorkloadIdentity
+type PolicyValueFrom struct {
+	// ConfigMapKeyRef selects a key of a ConfigMap
+	// +optional
+	ConfigMapKeyRef *KeyRef `json:"configMapKeyRef,omitempty"`
+	// SecretKeyRef selects a key of a Secret
+	// +optional
+	SecretKeyRef *KeyRef `json:"secretKeyRef,omitempty"`
+}
+
+// KeyRef selects a key of a ConfigMap or a Secret
+type KeyRef struct {
+	// Name of the ConfigMap or Secret
+	// +required
+	Name string `json:"name"`
+	// Key within the ConfigMap or Secret
+	// +required
+	Key string `json:"key"`
+}
+
+// AWSPodIdentity defines the EKS Pod Identity associations of the Role
+type AWSPodIdentity struct {
+	// ClusterName is the name of the EKS cluster
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	ClusterName string `json:"clusterName"`
+}
+
+// AWSClusterAccess defines the Kubernetes access granted to the Role
+type AWSClusterAccess struct {
+	// AWSAuthName is the name of the target AWSAuth
+	// +optional
+	// +kubebuilder:default=aws-auth
+	AWSAuthName string `json:"awsAuthName,omitempty"`
+	// AWSAuthNamespace is the namespace of the target AWSAuth
+	// +optional
+	// +kubebuilder:default=kube-system
+	AWSAuthNamespace string `json:"awsAuthNamespace,omitempty"`
+	// Username within Kubernetes to map to the Role.
+	// <(name), <(namespace) and <(roleName) are replaced with the WorkloadIdentity's values,
+	// aws-auth templates like {{SessionName}} are kept as is.
+	// Defaults to <(namespace):<(name):{{SessionName}}
+	// +optional
+	Username string `json:"username,omitempty"`
+	// Groups within Kubernetes to which the Role is mapped
+	// +required
+	// +kubebuilder:validation:MinItems=1
+	Groups []string `json:"groups"`
 }
 
 // PodSelector defines the pod selector
@@ -371,6 +477,53 @@ type ExternalResource struct {
 	Type string `json:"type,omitempty"`
 }
 
+// DriftChange is the change made to a resource of the identity outside of the controller
+// +kubebuilder:validation:Enum=Added;Modified;Removed
+type DriftChange string
+
+const (
+	// DriftChangeAdded - the resource was added, e.g. a policy attached
+	DriftChangeAdded DriftChange = "Added"
+	// DriftChangeModified - the resource was modified, e.g. the trust policy changed
+	DriftChangeModified DriftChange = "Modified"
+	// DriftChangeRemoved - the resource was removed, e.g. a tag or a role assignment deleted
+	DriftChangeRemoved DriftChange = "Removed"
+)
+
+// Drift is a change made to a resource of the identity outside of the controller,
+// which was reverted by the controller
+type Drift struct {
+	// Type of the drifted resource, e.g. AssumeRolePolicy, Policy, Tag or RoleAssignment
+	Type string `json:"type"`
+	// Name of the drifted resource
+	// +optional
+	Name string `json:"name,omitempty"`
+	// Change made outside of the controller
+	Change DriftChange `json:"change"`
+}
+
+// ObservedIdentity is the cloud identity read by the ObserveOnly management policy
+type ObservedIdentity struct {
+	// ClientID of the Azure managed identity
+	// +optional
+	ClientID string `json:"clientID,omitempty"`
+	// PrincipalID of the Azure managed identity
+	// +optional
+	PrincipalID string `json:"principalID,omitempty"`
+	// Email of the GCP service account
+	// +optional
+	Email string `json:"email,omitempty"`
+	// Policies are the arns of the policies attached to the AWS role
+	// +optional
+	Policies []string `json:"policies,omitempty"`
+	// InlinePolicies are the names of the inline policies of the AWS role
+	// +optional
+	InlinePolicies []string `json:"inlinePolicies,omitempty"`
+	// Roles granted to the identity: the GCP roles on the project, or the Azure role definition ids of the role assignments
+	// +optional
+	Roles []string `json:"roles,omitempty"`
+}
+
 // SyncKey is the sync key's definition
 type SyncKey struct {
 	// Source of the sync key
@@ -411,6 +564,24 @@ type WorkloadIdentityStatus struct {
 	// External Resources managed bu the Identity
 	// +optional
 	ExternalResources []ExternalResource `json:"externalResources,omitempty"`
+	// Drift lists the changes made outside of the controller, found by the last
+	// reconciliation which detected drift. See the Drifted condition for the last reconciliation.
+	// +optional
+	Drift []Drift `json:"drift,omitempty"`
+	// Observed is the cloud identity read by the ObserveOnly management policy
+	// +optional
+	Observed *ObservedIdentity `json:"observed,omitempty"`
+	// ObservedGeneration is the generation of the spec last reconciled successfully
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ObservedInputs is the hash of the inputs resolved outside of the spec last reconciled successfully,
+	// e.g. the policy documents of ConfigMaps and Secrets or the options of the manager
+	// +optional
+	ObservedInputs string `json:"observedInputs,omitempty"`
+	// Plan lists the changes the last dry-run reconciliation would apply.
+	// It is only set while the identity-manager.io/dry-run annotation or the --dry-run flag is set.
+	// +optional
+	Plan []string `json:"plan,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -481,3 +652,38 @@ func (r *WorkloadIdentity) GetStatusCopy() any {
 func (r *WorkloadIdentity) GetConditionedStatus() *ConditionedStatus {
 	return &r.Status.ConditionedStatus
 }
+
+// GetObservedGeneration returns the observed generation of WorkloadIdentity
+func (r *WorkloadIdentity) GetObservedGeneration() int64 {
+	return r.Status.ObservedGeneration
+}
+
+// SetObservedGeneration sets the observed generation of WorkloadIdentity
+func (r *WorkloadIdentity) SetObservedGeneration(generation int64) {
+	r.Status.ObservedGeneration = generation
+}
+
+// GetObservedInputs returns the observed inputs of WorkloadIdentity
+func (r *WorkloadIdentity) GetObservedInputs() string {
+	return r.Status.ObservedInputs
+}
+
+// SetObservedInputs sets the observed inputs of WorkloadIdentity
+func (r *WorkloadIdentity) SetObservedInputs(inputs string) {
+	r.Status.ObservedInputs = inputs
+}
+
+// SetDrift sets the drift of WorkloadIdentity
+func (r *WorkloadIdentity) SetDrift(drift []Drift) {
+	r.Status.Drift = drift
+}
+
+// GetPlan returns the planned changes of WorkloadIdentity
+func (r *WorkloadIdentity) GetPlan() []string {
+	return r.Status.Plan
+}
+
+// SetPlan sets the planned changes of WorkloadIdentity
+func (r *WorkloadIdentity) SetPlan(changes []string) {
+	r.Status.Plan = changes
+}
@@ -36,6 +36,26 @@ func (in *AWSAuth) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSAuthAccessEntries) DeepCopyInto(out *AWSAuthAccessEntries) {
+	*out = *in
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(Credentials)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSAuthAccessEntries.
+func (in *AWSAuthAccessEntries) DeepCopy() *AWSAuthAccessEntries {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSAuthAccessEntries)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AWSAuthList) DeepCopyInto(out *AWSAuthList) {
 	*out = *in
@@ -85,6 +105,16 @@ func (in *AWSAuthSpec) DeepCopyInto(out *AWSAuthSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.MapAccounts != nil {
+		in, out := &in.MapAccounts, &out.MapAccounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AccessEntries != nil {
+		in, out := &in.AccessEntries, &out.AccessEntries
+		*out = new(AWSAuthAccessEntries)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSAuthSpec.
@@ -113,6 +143,61 @@ func (in *AWSAuthStatus) DeepCopy() *AWSAuthStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSClusterAccess) DeepCopyInto(out *AWSClusterAccess) {
+	*out = *in
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSClusterAccess.
+func (in *AWSClusterAccess) DeepCopy() *AWSClusterAccess {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSClusterAccess)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSPodIdentity) DeepCopyInto(out *AWSPodIdentity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSPodIdentity.
+func (in *AWSPodIdentity) DeepCopy() *AWSPodIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSPodIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessPolicy) DeepCopyInto(out *AccessPolicy) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessPolicy.
+func (in *AccessPolicy) DeepCopy() *AccessPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AzureIdentity) DeepCopyInto(out *AzureIdentity) {
 	*out = *in
@@ -193,6 +278,65 @@ func (in *AzureIdentitySpec) DeepCopy() *AzureIdentitySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterProviderConfig) DeepCopyInto(out *ClusterProviderConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterProviderConfig.
+func (in *ClusterProviderConfig) DeepCopy() *ClusterProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterProviderConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterProviderConfigList) DeepCopyInto(out *ClusterProviderConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterProviderConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterProviderConfigList.
+func (in *ClusterProviderConfigList) DeepCopy() *ClusterProviderConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterProviderConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterProviderConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Condition) DeepCopyInto(out *Condition) {
 	*out = *in
@@ -258,6 +402,21 @@ func (in *Credentials) DeepCopy() *Credentials {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Drift) DeepCopyInto(out *Drift) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Drift.
+func (in *Drift) DeepCopy() *Drift {
+	if in == nil {
+		return nil
+	}
+	out := new(Drift)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExternalResource) DeepCopyInto(out *ExternalResource) {
 	*out = *in
@@ -308,6 +467,21 @@ func (in *GCPExpr) DeepCopy() *GCPExpr {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyRef) DeepCopyInto(out *KeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyRef.
+func (in *KeyRef) DeepCopy() *KeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MapRoleItem) DeepCopyInto(out *MapRoleItem) {
 	*out = *in
@@ -316,6 +490,13 @@ func (in *MapRoleItem) DeepCopyInto(out *MapRoleItem) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AccessPolicies != nil {
+		in, out := &in.AccessPolicies, &out.AccessPolicies
+		*out = make([]AccessPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MapRoleItem.
@@ -336,6 +517,13 @@ func (in *MapUserItem) DeepCopyInto(out *MapUserItem) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AccessPolicies != nil {
+		in, out := &in.AccessPolicies, &out.AccessPolicies
+		*out = make([]AccessPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MapUserItem.
@@ -377,6 +565,36 @@ func (in *Metadata) DeepCopy() *Metadata {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObservedIdentity) DeepCopyInto(out *ObservedIdentity) {
+	*out = *in
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InlinePolicies != nil {
+		in, out := &in.InlinePolicies, &out.InlinePolicies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObservedIdentity.
+func (in *ObservedIdentity) DeepCopy() *ObservedIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(ObservedIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodSelector) DeepCopyInto(out *PodSelector) {
 	*out = *in
@@ -393,6 +611,148 @@ func (in *PodSelector) DeepCopy() *PodSelector {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyValueFrom) DeepCopyInto(out *PolicyValueFrom) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(KeyRef)
+		**out = **in
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(KeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyValueFrom.
+func (in *PolicyValueFrom) DeepCopy() *PolicyValueFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyValueFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProviderConfig) DeepCopyInto(out *ProviderConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderConfig.
+func (in *ProviderConfig) DeepCopy() *ProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProviderConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProviderConfigList) DeepCopyInto(out *ProviderConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ProviderConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderConfigList.
+func (in *ProviderConfigList) DeepCopy() *ProviderConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(ProviderConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProviderConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProviderConfigRef) DeepCopyInto(out *ProviderConfigRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderConfigRef.
+func (in *ProviderConfigRef) DeepCopy() *ProviderConfigRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ProviderConfigRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProviderConfigSpec) DeepCopyInto(out *ProviderConfigSpec) {
+	*out = *in
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(Credentials)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderConfigSpec.
+func (in *ProviderConfigSpec) DeepCopy() *ProviderConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProviderConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProviderConfigStatus) DeepCopyInto(out *ProviderConfigStatus) {
+	*out = *in
+	in.ConditionedStatus.DeepCopyInto(&out.ConditionedStatus)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProviderConfigStatus.
+func (in *ProviderConfigStatus) DeepCopy() *ProviderConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ProviderConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Resource) DeepCopyInto(out *Resource) {
 	*out = *in
@@ -579,6 +939,11 @@ func (in *WorkloadIdentity) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkloadIdentityAWS) DeepCopyInto(out *WorkloadIdentityAWS) {
 	*out = *in
+	if in.AssumeRolePolicyFrom != nil {
+		in, out := &in.AssumeRolePolicyFrom, &out.AssumeRolePolicyFrom
+		*out = new(PolicyValueFrom)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.InlinePolicies != nil {
 		in, out := &in.InlinePolicies, &out.InlinePolicies
 		*out = make(map[string]string, len(*in))
@@ -586,11 +951,25 @@ func (in *WorkloadIdentityAWS) DeepCopyInto(out *WorkloadIdentityAWS) {
 			(*out)[key] = val
 		}
 	}
+	if in.InlinePoliciesFrom != nil {
+		in, out := &in.InlinePoliciesFrom, &out.InlinePoliciesFrom
+		*out = make(map[string]PolicyValueFrom, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
 	if in.Policies != nil {
 		in, out := &in.Policies, &out.Policies
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ManagedPolicies != nil {
+		in, out := &in.ManagedPolicies, &out.ManagedPolicies
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.ServiceAccounts != nil {
 		in, out := &in.ServiceAccounts, &out.ServiceAccounts
 		*out = make([]*ServiceAccount, len(*in))
@@ -613,6 +992,16 @@ func (in *WorkloadIdentityAWS) DeepCopyInto(out *WorkloadIdentityAWS) {
 			}
 		}
 	}
+	if in.ClusterAccess != nil {
+		in, out := &in.ClusterAccess, &out.ClusterAccess
+		*out = new(AWSClusterAccess)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodIdentity != nil {
+		in, out := &in.PodIdentity, &out.PodIdentity
+		*out = new(AWSPodIdentity)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadIdentityAWS.
@@ -768,6 +1157,11 @@ func (in *WorkloadIdentitySpec) DeepCopyInto(out *WorkloadIdentitySpec) {
 		*out = new(Credentials)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ProviderConfigRef != nil {
+		in, out := &in.ProviderConfigRef, &out.ProviderConfigRef
+		*out = new(ProviderConfigRef)
+		**out = **in
+	}
 	if in.AWS != nil {
 		in, out := &in.AWS, &out.AWS
 		*out = new(WorkloadIdentityAWS)
@@ -821,6 +1215,21 @@ func (in *WorkloadIdentityStatus) DeepCopyInto(out *WorkloadIdentityStatus) {
 		*out = make([]ExternalResource, len(*in))
 		copy(*out, *in)
 	}
+	if in.Drift != nil {
+		in, out := &in.Drift, &out.Drift
+		*out = make([]Drift, len(*in))
+		copy(*out, *in)
+	}
+	if in.Observed != nil {
+		in, out := &in.Observed, &out.Observed
+		*out = new(ObservedIdentity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadIdentityStatus.
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	awseks "github.com/aws/aws-sdk-go/service/eks"
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/eks"
+	"github.com/invisibl-cloud/identity-manager/pkg/reconcilers"
+)
+
+// reconcileAccessEntries reconciles the current items as EKS access entries
+func (r *aaReconciler) reconcileAccessEntries(ctx context.Context, current *awsAuthItems) ([]string, error) {
+	eksClient, err := r.getEKSClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// merging with no existing items resolves the duplicated ARNs
+	merged := mergeItems(&awsAuthItems{}, current)
+	conflicts, err := eksClient.Sync(toAccessEntries(merged))
+	if err != nil {
+		return nil, err
+	}
+	conflicts = append(conflicts, ignoredItems(merged)...)
+	return append(merged.conflicts, conflicts...), nil
+}
+
+// ignoredItems reports the items which have no access entry equivalent, instead of dropping them silently
+func ignoredItems(items *awsAuthItems) []string {
+	if len(items.mapAccounts) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("mapAccounts %s are ignored in mode %s, they have no access entry equivalent",
+		strings.Join(items.mapAccounts, ", "), v1alpha1.AWSAuthModeAccessEntries)}
+}
+
+// removeAccessEntries removes the managed EKS access entries, if any
+func (r *aaReconciler) removeAccessEntries(ctx context.Context) error {
+	if !r.res.OwnsAccessEntries() {
+		return nil
+	}
+	eksClient, err := r.getEKSClient(ctx)
+	if err != nil {
+		return err
+	}
+	return eksClient.Delete()
+}
+
+func (r *aaReconciler) getEKSClient(ctx context.Context) (*eks.Client, error) {
+	spec := r.res.Spec.AccessEntries
+	if spec == nil {
+		return nil, fmt.Errorf("missing accessEntries for %s mode", v1alpha1.AWSAuthModeAccessEntries)
+	}
+	conf, err := r.getAWSConfig(ctx, spec.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := awsx.NewSession(conf)
+	if err != nil {
+		return nil, err
+	}
+	return eks.New(awseks.New(sess), spec.ClusterName, r.res.Namespace+"/"+r.res.Name), nil
+}
+
+func (r *aaReconciler) getAWSConfig(ctx context.Context, creds *v1alpha1.Credentials) (awsx.Config, error) {
+	data, err := reconcilers.LoadSourceCredentials(ctx, r.base.Client(), creds, r.res.Name, r.res.Namespace, r.base.Options())
+	if err != nil {
+		return awsx.Config{}, err
+	}
+	m := map[string][]byte{}
+	for k, v := range data {
+		m[k] = []byte(v)
+	}
+	return awsx.NewConfig(m), nil
+}
+
+// toAccessEntries converts the items into access entries. mapAccounts have no access entry equivalent.
+func toAccessEntries(items *awsAuthItems) []*eks.AccessEntry {
+	entries := []*eks.AccessEntry{}
+	for _, mapRole := range items.mapRoles {
+		entries = append(entries, &eks.AccessEntry{
+			PrincipalArn:   mapRole.RoleArn,
+			Username:       mapRole.Username,
+			Groups:         mapRole.Groups,
+			AccessPolicies: mapRole.AccessPolicies,
+		})
+	}
+	for _, mapUser := range items.mapUsers {
+		entries = append(entries, &eks.AccessEntry{
+			PrincipalArn:   mapUser.UserArn,
+			Username:       mapUser.Username,
+			Groups:         mapUser.Groups,
+			AccessPolicies: mapUser.AccessPolicies,
+		})
+	}
+	return entries
+}
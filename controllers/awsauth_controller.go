@@ -18,13 +18,20 @@ package controllers
 
 import (
 	"context"
+	"sort"
+	"strings"
 
 	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
 	"github.com/invisibl-cloud/identity-manager/pkg/consts"
 	"github.com/invisibl-cloud/identity-manager/pkg/reconcilers"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"sigs.k8s.io/yaml"
 )
 
@@ -46,13 +53,33 @@ func (r *AWSAuthReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	return reconcilers.Reconcile(ctx, r.Base, req, res, rec)
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *AWSAuthReconciler) SetupWithManager(mgr ctrl.Manager) error {
+// SetupWithManager sets up the controller with the Manager, ctx is the context the manager is started with.
+func (r *AWSAuthReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.AWSAuth{}).
+		// source configmaps and the aws-auth configmap itself (to revert manual drift)
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			// the map funcs of this controller-runtime version are not given a context
+			return awsAuthRequestsForConfigMap(ctx, mgr.GetClient(), obj)
+		})).
 		Complete(r)
 }
 
+// awsAuthRequestsForConfigMap maps a configmap to the AWSAuth it belongs to
+func awsAuthRequestsForConfigMap(ctx context.Context, c client.Reader, obj client.Object) []reconcile.Request {
+	// source configmap
+	if name := obj.GetLabels()[consts.AWSAuthNameKey]; name != "" {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}}}
+	}
+	// aws-auth configmap, which has the same name as the AWSAuth
+	key := client.ObjectKeyFromObject(obj)
+	err := c.Get(ctx, key, &v1alpha1.AWSAuth{})
+	if err != nil {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: key}}
+}
+
 // Reconciler
 type aaReconciler struct {
 	base *reconcilers.ReconcilerBase
@@ -69,72 +96,89 @@ func YAMLUnmarshal(str string, out any) error {
 
 // Reconcile reconciles the awsauth resource
 func (r *aaReconciler) Reconcile(ctx context.Context) error {
+	current, err := r.getCurrentItems(ctx)
+	if err != nil {
+		return err
+	}
+	var conflicts []string
+	if r.res.Spec.Mode == v1alpha1.AWSAuthModeAccessEntries {
+		// recorded first, the access entries are owned as soon as they may be created
+		r.res.Status.Mode = v1alpha1.AWSAuthModeAccessEntries
+		conflicts, err = r.reconcileAccessEntries(ctx, current)
+		if err != nil {
+			return err
+		}
+		// items moved to access entries, remove them from the aws-auth configmap
+		err = r.removeConfigMapItems(ctx)
+	} else {
+		conflicts, err = r.reconcileConfigMap(ctx, current)
+		if err != nil {
+			return err
+		}
+		// items moved back to the aws-auth configmap, remove the access entries once
+		err = r.removeAccessEntries(ctx)
+		if err == nil {
+			r.res.Status.Mode = v1alpha1.AWSAuthModeConfigMap
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		r.res.Status.SetConditions(v1alpha1.Conflicted(strings.Join(conflicts, "; ")))
+	} else {
+		r.res.Status.SetConditions(v1alpha1.NoConflict())
+	}
+	return nil
+}
+
+// reconcileConfigMap merges the current items into the aws-auth configmap
+func (r *aaReconciler) reconcileConfigMap(ctx context.Context, current *awsAuthItems) ([]string, error) {
+	var conflicts []string
 	cm := &corev1.ConfigMap{}
 	cm.Name = r.res.Name
 	cm.Namespace = r.res.Namespace
 	_, err := ctrl.CreateOrUpdate(ctx, r.base.Client(), cm, func() error {
 		// load existing config
-		existingMapRoles := []*MapRoleItem{}
-		err := YAMLUnmarshal(cm.Data["mapRoles"], &existingMapRoles)
-		if err != nil {
-			return err
-		}
-		retainedMapRoles := []*MapRoleItem{}
-		for _, mapRole := range existingMapRoles {
-			// retain this
-			if mapRole.Source == "" {
-				retainedMapRoles = append(retainedMapRoles, mapRole)
-			}
-		}
-		currentMapRoles, currentMapUsers, err := r.getCurrentItems(ctx)
+		existing, err := loadItems(cm)
 		if err != nil {
 			return err
 		}
-		// nothing to update. ignore and return immediately.
-		if len(currentMapRoles)+len(currentMapUsers) == 0 {
+		// nothing to update and no managed items to remove. ignore and return immediately.
+		if current.isEmpty() && !existing.hasManagedItems() {
 			return nil
 		}
-		if cm.Data == nil {
-			cm.Data = map[string]string{}
-		}
-		data, err := yaml.Marshal(append(retainedMapRoles, currentMapRoles...))
-		if err == nil {
-			cm.Data["mapRoles"] = string(data)
-		} else {
-			r.base.Log(ctx).Info("ignoring.. error marshaling mapRoles", "err", err)
-		}
-		data, err = yaml.Marshal(currentMapUsers)
-		if err == nil {
-			cm.Data["mapUsers"] = string(data)
-		} else {
-			r.base.Log(ctx).Info("ignoring.. error marshaling mapUsers", "err", err)
-		}
-		return nil
+		merged := mergeItems(existing, current)
+		conflicts = merged.conflicts
+		return merged.writeTo(cm)
 	})
-
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return conflicts, nil
 }
 
-func (r *aaReconciler) getCurrentItems(ctx context.Context) ([]*MapRoleItem, []*MapUserItem, error) {
-	currentMapRoles := []*MapRoleItem{}
-	currentMapUsers := []*MapUserItem{}
+func (r *aaReconciler) getCurrentItems(ctx context.Context) (*awsAuthItems, error) {
+	// start with the items declared in the spec
+	current := r.getSpecItems()
 	// get list of aws-auth configs from configmaps
 	l := &corev1.ConfigMapList{}
 	err := r.base.Client().List(ctx, l, client.InNamespace(r.res.Namespace), client.MatchingLabels{
 		consts.AWSAuthNameKey: r.res.Name,
 	})
 	if err != nil {
-		return nil, nil, err
-	}
-	if len(l.Items) == 0 {
-		return nil, nil, nil
+		return nil, err
 	}
+	// sort by name, so that conflicts are always resolved the same way
+	sort.Slice(l.Items, func(i, j int) bool { return l.Items[i].Name < l.Items[j].Name })
 	for _, item := range l.Items {
+		// entries of a configmap being deleted are removed
+		if item.GetDeletionTimestamp() != nil {
+			continue
+		}
 		mapRolesItems := []*MapRoleItem{}
 		mapUsersItems := []*MapUserItem{}
+		mapAccountsItems := []string{}
 		err := YAMLUnmarshal(item.Data["mapRoles"], &mapRolesItems)
 		if err != nil {
 			r.base.Log(ctx).Info("ignoring.. error unmarshaling mapRoles in configmap", "cm.ns", item.GetNamespace(), "cm.name", item.GetName())
@@ -143,39 +187,107 @@ func (r *aaReconciler) getCurrentItems(ctx context.Context) ([]*MapRoleItem, []*
 		if err != nil {
 			r.base.Log(ctx).Info("ignoring.. error unmarshaling mapUsers in configmap", "cm.ns", item.GetNamespace(), "cm.name", item.GetName())
 		}
+		err = YAMLUnmarshal(item.Data["mapAccounts"], &mapAccountsItems)
+		if err != nil {
+			r.base.Log(ctx).Info("ignoring.. error unmarshaling mapAccounts in configmap", "cm.ns", item.GetNamespace(), "cm.name", item.GetName())
+		}
 		for _, ritem := range mapRolesItems {
 			if ritem.Username != "" && ritem.RoleArn != "" && len(ritem.Groups) > 0 {
 				ritem.Source = item.Name
-				currentMapRoles = append(currentMapRoles, ritem)
+				current.mapRoles = append(current.mapRoles, ritem)
 			}
 		}
 		for _, uitem := range mapUsersItems {
 			if uitem.Username != "" && uitem.UserArn != "" && len(uitem.Groups) > 0 {
 				uitem.Source = item.Name
-				currentMapUsers = append(currentMapUsers, uitem)
+				current.mapUsers = append(current.mapUsers, uitem)
+			}
+		}
+		for _, account := range mapAccountsItems {
+			if account != "" {
+				current.mapAccounts = append(current.mapAccounts, account)
 			}
 		}
 	}
-	return currentMapRoles, currentMapUsers, nil
+	return current, nil
 }
 
+// getSpecItems returns the items declared in the AWSAuth spec,
+// tagged with the AWSAuth as their source.
+func (r *aaReconciler) getSpecItems() *awsAuthItems {
+	source := specSource(r.res)
+	items := &awsAuthItems{}
+	for _, ritem := range r.res.Spec.MapRoles {
+		items.mapRoles = append(items.mapRoles, &MapRoleItem{
+			Source:         source,
+			RoleArn:        ritem.RoleArn,
+			Username:       ritem.Username,
+			Groups:         ritem.Groups,
+			AccessPolicies: ritem.AccessPolicies,
+		})
+	}
+	for _, uitem := range r.res.Spec.MapUsers {
+		items.mapUsers = append(items.mapUsers, &MapUserItem{
+			Source:         source,
+			UserArn:        uitem.UserArn,
+			Username:       uitem.Username,
+			Groups:         uitem.Groups,
+			AccessPolicies: uitem.AccessPolicies,
+		})
+	}
+	items.mapAccounts = append(items.mapAccounts, r.res.Spec.MapAccounts...)
+	return items
+}
+
+// specSource returns the source of the items declared in the AWSAuth spec
+func specSource(res *v1alpha1.AWSAuth) string {
+	return awsAuthSourcePrefix + res.Name
+}
+
+// awsAuthSourcePrefix is the prefix of the source of the items declared in the AWSAuth spec.
+// Items sourced from configmaps use the configmap name as source.
+const awsAuthSourcePrefix = "awsauth/"
+
 // Finalize implements Finalizer interface
 func (r *aaReconciler) Finalize(ctx context.Context) error {
-	return nil
+	err := r.removeConfigMapItems(ctx)
+	if err != nil {
+		return err
+	}
+	return r.removeAccessEntries(ctx)
 }
 
-// MapRoleItem defines the mapRole item of AWSAuth
-type MapRoleItem struct {
-	Source   string   `json:"source,omitempty" yaml:"source,omitempty"`
-	RoleArn  string   `json:"rolearn" yaml:"rolearn"`
-	Username string   `json:"username" yaml:"username"`
-	Groups   []string `json:"groups" yaml:"groups"`
+// removeConfigMapItems removes the managed items from the aws-auth configmap, if it exists
+func (r *aaReconciler) removeConfigMapItems(ctx context.Context) error {
+	cm := &corev1.ConfigMap{}
+	cm.Name = r.res.Name
+	cm.Namespace = r.res.Namespace
+	err := r.base.Client().Get(ctx, client.ObjectKeyFromObject(cm), cm)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	updated, err := removeManagedItems(cm)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return nil
+	}
+	return r.base.Client().Update(ctx, cm)
 }
 
-// MapUserItem defines the mapUser item of AWSAuth
-type MapUserItem struct {
-	Source   string   `json:"source,omitempty" yaml:"source,omitempty"`
-	UserArn  string   `json:"userarn" yaml:"userarn"`
-	Username string   `json:"username" yaml:"username"`
-	Groups   []string `json:"groups" yaml:"groups"`
+// removeManagedItems removes all the managed items from the aws-auth configmap,
+// retaining the unmanaged ones. It returns true if the configmap got updated.
+func removeManagedItems(cm *corev1.ConfigMap) (bool, error) {
+	existing, err := loadItems(cm)
+	if err != nil {
+		return false, err
+	}
+	if !existing.hasManagedItems() {
+		return false, nil
+	}
+	return true, mergeItems(existing, &awsAuthItems{}).writeTo(cm)
 }
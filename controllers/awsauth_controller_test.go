@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/eks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestGetSpecItems(t *testing.T) {
+	res := &v1alpha1.AWSAuth{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws-auth",
+			Namespace: "kube-system",
+		},
+		Spec: v1alpha1.AWSAuthSpec{
+			MapRoles: []v1alpha1.MapRoleItem{
+				{
+					RoleArn:  "arn:aws:iam::12345678:role/admin",
+					Username: "admin",
+					Groups:   []string{"system:masters"},
+				},
+			},
+			MapUsers: []v1alpha1.MapUserItem{
+				{
+					UserArn:  "arn:aws:iam::12345678:user/dev",
+					Username: "dev",
+					Groups:   []string{"dev"},
+				},
+			},
+			MapAccounts: []string{"12345678"},
+		},
+	}
+	r := &aaReconciler{res: res}
+	items := r.getSpecItems()
+
+	assert.Equal(t, []*MapRoleItem{
+		{
+			Source:   "awsauth/aws-auth",
+			RoleArn:  "arn:aws:iam::12345678:role/admin",
+			Username: "admin",
+			Groups:   []string{"system:masters"},
+		},
+	}, items.mapRoles)
+	assert.Equal(t, []*MapUserItem{
+		{
+			Source:   "awsauth/aws-auth",
+			UserArn:  "arn:aws:iam::12345678:user/dev",
+			Username: "dev",
+			Groups:   []string{"dev"},
+		},
+	}, items.mapUsers)
+	assert.Equal(t, []string{"12345678"}, items.mapAccounts)
+}
+
+func TestHasManagedItems(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		items    *awsAuthItems
+		expected bool
+	}{
+		{
+			desc:     "No items",
+			items:    &awsAuthItems{},
+			expected: false,
+		},
+		{
+			desc: "Only unmanaged items",
+			items: &awsAuthItems{
+				mapRoles:    []*MapRoleItem{{RoleArn: "arn:aws:iam::12345678:role/node", Username: "node", Groups: []string{"system:nodes"}}},
+				mapUsers:    []*MapUserItem{{UserArn: "arn:aws:iam::12345678:user/dev", Username: "dev", Groups: []string{"dev"}}},
+				mapAccounts: []string{"12345678"},
+			},
+			expected: false,
+		},
+		{
+			desc: "Managed mapRoles item",
+			items: &awsAuthItems{
+				mapRoles: []*MapRoleItem{{Source: "awsauth/aws-auth", RoleArn: "arn:aws:iam::12345678:role/admin", Username: "admin", Groups: []string{"system:masters"}}},
+			},
+			expected: true,
+		},
+		{
+			desc: "Managed mapUsers item",
+			items: &awsAuthItems{
+				mapUsers: []*MapUserItem{{Source: "team-a", UserArn: "arn:aws:iam::12345678:user/dev", Username: "dev", Groups: []string{"dev"}}},
+			},
+			expected: true,
+		},
+		{
+			desc: "Managed mapAccounts item",
+			items: &awsAuthItems{
+				mapAccounts:     []string{"12345678"},
+				managedAccounts: []string{"12345678"},
+			},
+			expected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, testCase.items.hasManagedItems(), testCase.desc)
+	}
+}
+
+func TestMergeItems(t *testing.T) {
+	existing := &awsAuthItems{
+		mapRoles: []*MapRoleItem{
+			{RoleArn: "arn:aws:iam::12345678:role/node", Username: "system:node:{{EC2PrivateDNSName}}", Groups: []string{"system:nodes"}},
+			{Source: "awsauth/aws-auth", RoleArn: "arn:aws:iam::12345678:role/removed", Username: "removed", Groups: []string{"dev"}},
+		},
+		mapUsers: []*MapUserItem{
+			{UserArn: "arn:aws:iam::12345678:user/eksctl", Username: "eksctl", Groups: []string{"system:masters"}},
+			{Source: "team-a", UserArn: "arn:aws:iam::12345678:user/removed", Username: "removed", Groups: []string{"dev"}},
+		},
+		mapAccounts:     []string{"11111111", "22222222"},
+		managedAccounts: []string{"22222222"},
+	}
+	current := &awsAuthItems{
+		mapRoles: []*MapRoleItem{
+			{Source: "awsauth/aws-auth", RoleArn: "arn:aws:iam::12345678:role/admin", Username: "admin", Groups: []string{"system:masters"}},
+			{Source: "team-a", RoleArn: "arn:aws:iam::12345678:role/admin", Username: "team-a", Groups: []string{"dev"}},
+			{Source: "team-a", RoleArn: "arn:aws:iam::12345678:role/node", Username: "node", Groups: []string{"dev"}},
+		},
+		mapUsers: []*MapUserItem{
+			{Source: "awsauth/aws-auth", UserArn: "arn:aws:iam::12345678:user/dev", Username: "dev", Groups: []string{"dev"}},
+		},
+		mapAccounts: []string{"11111111", "33333333"},
+	}
+
+	merged := mergeItems(existing, current)
+
+	assert.Equal(t, []*MapRoleItem{
+		{RoleArn: "arn:aws:iam::12345678:role/node", Username: "system:node:{{EC2PrivateDNSName}}", Groups: []string{"system:nodes"}},
+		{Source: "awsauth/aws-auth", RoleArn: "arn:aws:iam::12345678:role/admin", Username: "admin", Groups: []string{"system:masters"}},
+	}, merged.mapRoles)
+	assert.Equal(t, []*MapUserItem{
+		{UserArn: "arn:aws:iam::12345678:user/eksctl", Username: "eksctl", Groups: []string{"system:masters"}},
+		{Source: "awsauth/aws-auth", UserArn: "arn:aws:iam::12345678:user/dev", Username: "dev", Groups: []string{"dev"}},
+	}, merged.mapUsers)
+	assert.Equal(t, []string{"11111111", "33333333"}, merged.mapAccounts)
+	assert.Equal(t, []string{"33333333"}, merged.managedAccounts)
+	assert.Equal(t, []string{
+		"rolearn arn:aws:iam::12345678:role/admin from team-a conflicts with an entry from awsauth/aws-auth",
+		"rolearn arn:aws:iam::12345678:role/node from team-a conflicts with an unmanaged entry",
+	}, merged.conflicts)
+}
+
+func TestLoadAndWriteItems(t *testing.T) {
+	items := &awsAuthItems{
+		mapRoles: []*MapRoleItem{
+			{Source: "awsauth/aws-auth", RoleArn: "arn:aws:iam::12345678:role/admin", Username: "admin", Groups: []string{"system:masters"}},
+		},
+		mapAccounts:     []string{"11111111"},
+		managedAccounts: []string{"11111111"},
+	}
+	cm := &corev1.ConfigMap{
+		Data: map[string]string{
+			"mapUsers": "- userarn: arn:aws:iam::12345678:user/removed\n  username: removed\n  groups:\n  - dev\n",
+		},
+	}
+	require.Nil(t, items.writeTo(cm))
+
+	_, ok := cm.Data["mapUsers"]
+	assert.False(t, ok)
+	assert.Equal(t, "11111111", cm.Annotations[consts.AWSAuthManagedAccountsKey])
+
+	loaded, err := loadItems(cm)
+	require.Nil(t, err)
+	assert.Equal(t, items.mapRoles, loaded.mapRoles)
+	assert.Empty(t, loaded.mapUsers)
+	assert.Equal(t, items.mapAccounts, loaded.mapAccounts)
+	assert.Equal(t, items.managedAccounts, loaded.managedAccounts)
+}
+
+func TestAWSAuthRequestsForConfigMap(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, v1alpha1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1alpha1.AWSAuth{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws-auth",
+			Namespace: "kube-system",
+		},
+	}).Build()
+
+	testCases := []struct {
+		desc      string
+		configMap *corev1.ConfigMap
+		expected  []reconcile.Request
+	}{
+		{
+			desc: "Source configmap",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "team-a",
+					Namespace: "kube-system",
+					Labels:    map[string]string{consts.AWSAuthNameKey: "aws-auth"},
+				},
+			},
+			expected: []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "kube-system", Name: "aws-auth"}}},
+		},
+		{
+			desc: "aws-auth configmap",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "aws-auth",
+					Namespace: "kube-system",
+				},
+			},
+			expected: []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "kube-system", Name: "aws-auth"}}},
+		},
+		{
+			desc: "Unrelated configmap",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "coredns",
+					Namespace: "kube-system",
+				},
+			},
+			expected: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, awsAuthRequestsForConfigMap(context.Background(), c, testCase.configMap), testCase.desc)
+	}
+}
+
+func TestRemoveManagedItems(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{consts.AWSAuthManagedAccountsKey: "22222222"},
+		},
+		Data: map[string]string{
+			"mapRoles": `- rolearn: arn:aws:iam::12345678:role/node
+  username: system:node:{{EC2PrivateDNSName}}
+  groups:
+  - system:nodes
+- source: awsauth/aws-auth
+  rolearn: arn:aws:iam::12345678:role/admin
+  username: admin
+  groups:
+  - system:masters
+`,
+			"mapUsers": `- source: team-a
+  userarn: arn:aws:iam::12345678:user/dev
+  username: dev
+  groups:
+  - dev
+`,
+			"mapAccounts": `- "11111111"
+- "22222222"
+`,
+		},
+	}
+
+	updated, err := removeManagedItems(cm)
+	require.Nil(t, err)
+	assert.True(t, updated)
+
+	items, err := loadItems(cm)
+	require.Nil(t, err)
+	assert.Equal(t, []*MapRoleItem{
+		{RoleArn: "arn:aws:iam::12345678:role/node", Username: "system:node:{{EC2PrivateDNSName}}", Groups: []string{"system:nodes"}},
+	}, items.mapRoles)
+	assert.Empty(t, items.mapUsers)
+	assert.Equal(t, []string{"11111111"}, items.mapAccounts)
+	assert.Empty(t, items.managedAccounts)
+
+	// nothing left to remove
+	updated, err = removeManagedItems(cm)
+	require.Nil(t, err)
+	assert.False(t, updated)
+}
+
+func TestOwnsAccessEntries(t *testing.T) {
+	accessEntries := &v1alpha1.AWSAuthAccessEntries{ClusterName: "demo"}
+	testCases := []struct {
+		desc     string
+		spec     v1alpha1.AWSAuthSpec
+		status   v1alpha1.AWSAuthStatus
+		expected bool
+	}{
+		{
+			desc:     "No access entries",
+			spec:     v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeConfigMap},
+			expected: false,
+		},
+		{
+			desc:     "Mode not recorded yet",
+			spec:     v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeConfigMap, AccessEntries: accessEntries},
+			expected: true,
+		},
+		{
+			desc:     "Switched back to the ConfigMap mode",
+			spec:     v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeConfigMap, AccessEntries: accessEntries},
+			status:   v1alpha1.AWSAuthStatus{Mode: v1alpha1.AWSAuthModeAccessEntries},
+			expected: true,
+		},
+		{
+			desc:     "Access entries removed in ConfigMap mode",
+			spec:     v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeConfigMap, AccessEntries: accessEntries},
+			status:   v1alpha1.AWSAuthStatus{Mode: v1alpha1.AWSAuthModeConfigMap},
+			expected: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		res := &v1alpha1.AWSAuth{Spec: testCase.spec, Status: testCase.status}
+		assert.Equal(t, testCase.expected, res.OwnsAccessEntries(), testCase.desc)
+		if !testCase.expected {
+			// no EKS client is created
+			assert.Nil(t, (&aaReconciler{res: res}).removeAccessEntries(context.Background()), testCase.desc)
+		}
+	}
+}
+
+func TestIgnoredItems(t *testing.T) {
+	assert.Nil(t, ignoredItems(&awsAuthItems{
+		mapRoles: []*MapRoleItem{{RoleArn: "arn:aws:iam::12345678:role/admin", Username: "admin"}},
+	}))
+	assert.Equal(t, []string{"mapAccounts 12345678, 87654321 are ignored in mode AccessEntries, they have no access entry equivalent"},
+		ignoredItems(&awsAuthItems{mapAccounts: []string{"12345678", "87654321"}}))
+}
+
+func TestToAccessEntries(t *testing.T) {
+	policies := []v1alpha1.AccessPolicy{{PolicyArn: "arn:aws:eks::aws:cluster-access-policy/AmazonEKSViewPolicy"}}
+	items := &awsAuthItems{
+		mapRoles: []*MapRoleItem{
+			{Source: "awsauth/aws-auth", RoleArn: "arn:aws:iam::12345678:role/admin", Username: "admin", Groups: []string{"admins"}, AccessPolicies: policies},
+		},
+		mapUsers: []*MapUserItem{
+			{Source: "team-a", UserArn: "arn:aws:iam::12345678:user/dev", Username: "dev", Groups: []string{"dev"}},
+		},
+		mapAccounts: []string{"12345678"},
+	}
+
+	assert.Equal(t, []*eks.AccessEntry{
+		{PrincipalArn: "arn:aws:iam::12345678:role/admin", Username: "admin", Groups: []string{"admins"}, AccessPolicies: policies},
+		{PrincipalArn: "arn:aws:iam::12345678:user/dev", Username: "dev", Groups: []string{"dev"}},
+	}, toAccessEntries(items))
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// MapRoleItem defines the mapRole item of AWSAuth
+type MapRoleItem struct {
+	Source   string   `json:"source,omitempty" yaml:"source,omitempty"`
+	RoleArn  string   `json:"rolearn" yaml:"rolearn"`
+	Username string   `json:"username" yaml:"username"`
+	Groups   []string `json:"groups" yaml:"groups"`
+	// AccessPolicies are only declared in the AWSAuth spec and used in AccessEntries mode
+	AccessPolicies []v1alpha1.AccessPolicy `json:"-" yaml:"-"`
+}
+
+// MapUserItem defines the mapUser item of AWSAuth
+type MapUserItem struct {
+	Source   string   `json:"source,omitempty" yaml:"source,omitempty"`
+	UserArn  string   `json:"userarn" yaml:"userarn"`
+	Username string   `json:"username" yaml:"username"`
+	Groups   []string `json:"groups" yaml:"groups"`
+	// AccessPolicies are only declared in the AWSAuth spec and used in AccessEntries mode
+	AccessPolicies []v1alpha1.AccessPolicy `json:"-" yaml:"-"`
+}
+
+// awsAuthItems holds the items of an aws-auth configmap.
+// Items with an empty source are not managed by identity-manager
+// and are always retained.
+type awsAuthItems struct {
+	mapRoles    []*MapRoleItem
+	mapUsers    []*MapUserItem
+	mapAccounts []string
+	// managedAccounts are the mapAccounts owned by identity-manager,
+	// since accounts can't carry a source.
+	managedAccounts []string
+	// conflicts found while merging
+	conflicts []string
+}
+
+// loadItems loads the items from the aws-auth configmap
+func loadItems(cm *corev1.ConfigMap) (*awsAuthItems, error) {
+	items := &awsAuthItems{}
+	err := YAMLUnmarshal(cm.Data["mapRoles"], &items.mapRoles)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling mapRoles: %w", err)
+	}
+	err = YAMLUnmarshal(cm.Data["mapUsers"], &items.mapUsers)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling mapUsers: %w", err)
+	}
+	err = YAMLUnmarshal(cm.Data["mapAccounts"], &items.mapAccounts)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling mapAccounts: %w", err)
+	}
+	if v := cm.GetAnnotations()[consts.AWSAuthManagedAccountsKey]; v != "" {
+		items.managedAccounts = strings.Split(v, ",")
+	}
+	return items, nil
+}
+
+// isEmpty checks whether there are no items
+func (x *awsAuthItems) isEmpty() bool {
+	return len(x.mapRoles)+len(x.mapUsers)+len(x.mapAccounts) == 0
+}
+
+// hasManagedItems checks whether any of the items is managed
+func (x *awsAuthItems) hasManagedItems() bool {
+	for _, mapRole := range x.mapRoles {
+		if mapRole.Source != "" {
+			return true
+		}
+	}
+	for _, mapUser := range x.mapUsers {
+		if mapUser.Source != "" {
+			return true
+		}
+	}
+	return len(x.managedAccounts) > 0
+}
+
+// mergeItems retains the unmanaged items of existing and replaces
+// the managed items of existing with the current items.
+// Current items whose ARN is already taken are skipped and reported as conflicts.
+func mergeItems(existing *awsAuthItems, current *awsAuthItems) *awsAuthItems {
+	merged := &awsAuthItems{}
+
+	// mapRoles
+	roleOwners := map[string]string{}
+	for _, mapRole := range existing.mapRoles {
+		if mapRole.Source == "" {
+			merged.mapRoles = append(merged.mapRoles, mapRole)
+			roleOwners[mapRole.RoleArn] = ""
+		}
+	}
+	for _, mapRole := range current.mapRoles {
+		if owner, ok := roleOwners[mapRole.RoleArn]; ok {
+			merged.conflicts = append(merged.conflicts, conflictMessage("rolearn", mapRole.RoleArn, mapRole.Source, owner))
+			continue
+		}
+		merged.mapRoles = append(merged.mapRoles, mapRole)
+		roleOwners[mapRole.RoleArn] = mapRole.Source
+	}
+
+	// mapUsers
+	userOwners := map[string]string{}
+	for _, mapUser := range existing.mapUsers {
+		if mapUser.Source == "" {
+			merged.mapUsers = append(merged.mapUsers, mapUser)
+			userOwners[mapUser.UserArn] = ""
+		}
+	}
+	for _, mapUser := range current.mapUsers {
+		if owner, ok := userOwners[mapUser.UserArn]; ok {
+			merged.conflicts = append(merged.conflicts, conflictMessage("userarn", mapUser.UserArn, mapUser.Source, owner))
+			continue
+		}
+		merged.mapUsers = append(merged.mapUsers, mapUser)
+		userOwners[mapUser.UserArn] = mapUser.Source
+	}
+
+	// mapAccounts
+	previouslyManaged := map[string]bool{}
+	for _, account := range existing.managedAccounts {
+		previouslyManaged[account] = true
+	}
+	accounts := map[string]bool{}
+	for _, account := range existing.mapAccounts {
+		if !previouslyManaged[account] && !accounts[account] {
+			merged.mapAccounts = append(merged.mapAccounts, account)
+			accounts[account] = true
+		}
+	}
+	for _, account := range current.mapAccounts {
+		// same account id means same access, so it is not a conflict.
+		if accounts[account] {
+			continue
+		}
+		merged.mapAccounts = append(merged.mapAccounts, account)
+		merged.managedAccounts = append(merged.managedAccounts, account)
+		accounts[account] = true
+	}
+	return merged
+}
+
+func conflictMessage(kind string, arn string, source string, owner string) string {
+	if owner == "" {
+		return fmt.Sprintf("%s %s from %s conflicts with an unmanaged entry", kind, arn, source)
+	}
+	return fmt.Sprintf("%s %s from %s conflicts with an entry from %s", kind, arn, source, owner)
+}
+
+// writeTo writes the items to the aws-auth configmap
+func (x *awsAuthItems) writeTo(cm *corev1.ConfigMap) error {
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	err := setYAML(cm, "mapRoles", x.mapRoles, len(x.mapRoles))
+	if err != nil {
+		return err
+	}
+	err = setYAML(cm, "mapUsers", x.mapUsers, len(x.mapUsers))
+	if err != nil {
+		return err
+	}
+	err = setYAML(cm, "mapAccounts", x.mapAccounts, len(x.mapAccounts))
+	if err != nil {
+		return err
+	}
+	annotations := cm.GetAnnotations()
+	if len(x.managedAccounts) > 0 {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[consts.AWSAuthManagedAccountsKey] = strings.Join(x.managedAccounts, ",")
+	} else {
+		delete(annotations, consts.AWSAuthManagedAccountsKey)
+	}
+	cm.SetAnnotations(annotations)
+	return nil
+}
+
+func setYAML(cm *corev1.ConfigMap, key string, value any, count int) error {
+	if count == 0 {
+		delete(cm.Data, key)
+		return nil
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("error marshaling %s: %w", key, err)
+	}
+	cm.Data[key] = string(data)
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/reconcilers"
+	"github.com/invisibl-cloud/identity-manager/pkg/reconcilers/aws"
+	"github.com/invisibl-cloud/identity-manager/pkg/reconcilers/azure"
+	"github.com/invisibl-cloud/identity-manager/pkg/reconcilers/gcp"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
+)
+
+// ProviderConfigReconciler reconciles a ProviderConfig object
+type ProviderConfigReconciler struct {
+	Base *reconcilers.ReconcilerBase
+}
+
+//+kubebuilder:rbac:groups=identity-manager.io,resources=providerconfigs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=identity-manager.io,resources=providerconfigs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=identity-manager.io,resources=providerconfigs/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+
+// Reconcile ProviderConfig
+func (r *ProviderConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	res := &v1alpha1.ProviderConfig{}
+	rec := &pcReconciler{base: r.Base, res: res, spec: &res.Spec}
+	return reconcilers.Reconcile(ctx, r.Base, req, res, rec)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ProviderConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ProviderConfig{}).
+		Complete(r)
+}
+
+// ClusterProviderConfigReconciler reconciles a ClusterProviderConfig object
+type ClusterProviderConfigReconciler struct {
+	Base *reconcilers.ReconcilerBase
+}
+
+//+kubebuilder:rbac:groups=identity-manager.io,resources=clusterproviderconfigs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=identity-manager.io,resources=clusterproviderconfigs/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=identity-manager.io,resources=clusterproviderconfigs/finalizers,verbs=update
+
+// Reconcile ClusterProviderConfig
+func (r *ClusterProviderConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	res := &v1alpha1.ClusterProviderConfig{}
+	rec := &pcReconciler{base: r.Base, res: res, spec: &res.Spec}
+	return reconcilers.Reconcile(ctx, r.Base, req, res, rec)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterProviderConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ClusterProviderConfig{}).
+		Complete(r)
+}
+
+// pcReconciler loads the credentials of a ProviderConfig or a ClusterProviderConfig, they are
+// validated once loaded
+type pcReconciler struct {
+	base *reconcilers.ReconcilerBase
+	res  types.Resource
+	spec *v1alpha1.ProviderConfigSpec
+	// the credentials loaded by Reconcile
+	creds *reconcilers.Credentials
+}
+
+// Reconcile loads the credentials
+func (r *pcReconciler) Reconcile(ctx context.Context) error {
+	creds, err := reconcilers.LoadProviderConfigCredentials(ctx, r.base.Client(), r.spec, r.res.GetName(), r.res.GetNamespace(), r.base.Options())
+	if err != nil {
+		r.res.GetConditionedStatus().SetConditions(v1alpha1.Unavailable().WithMessage(err.Error()))
+		return err
+	}
+	r.creds = creds
+	return nil
+}
+
+// Ready implements ReadyReconciler interface
+func (r *pcReconciler) Ready(ctx context.Context) error {
+	switch r.spec.Provider {
+	case v1alpha1.ProviderAWS:
+		return aws.ValidateCredentials(ctx, r.creds)
+	case v1alpha1.ProviderAzure:
+		return azure.ValidateCredentials(ctx, r.creds)
+	case v1alpha1.ProviderGCP:
+		return gcp.ValidateCredentials(ctx, r.creds)
+	}
+	return nil
+}
+
+// Finalize implements Finalizer interface, nothing is created for a provider config
+func (r *pcReconciler) Finalize(ctx context.Context) error {
+	return nil
+}
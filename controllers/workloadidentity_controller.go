@@ -39,6 +39,7 @@ type WorkloadIdentityReconciler struct {
 //+kubebuilder:rbac:groups=identity-manager.io,resources=workloadidentities/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=identity-manager.io,resources=workloadidentities/finalizers,verbs=update
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;update;patch;delete
 //+kubebuilder:rbac:groups=aadpodidentity.k8s.io,resources=azureidentities,verbs=get;list;watch;create;update;patch;delete
@@ -73,6 +74,8 @@ func (r *WorkloadIdentityReconciler) SetupWithManager(mgr ctrl.Manager) error {
 type wiReconciler struct {
 	base *reconcilers.ReconcilerBase
 	res  *v1alpha1.WorkloadIdentity
+	// the provider reconciler prepared by Reconcile
+	rec wiReconcilerInterface
 }
 
 type wiReconcilerInterface interface {
@@ -93,13 +96,26 @@ func (r *wiReconciler) Reconcile(ctx context.Context) error {
 	default:
 		return fmt.Errorf("unknown provider %s", r.res.Spec.Provider)
 	}
+	// the observed identity is only kept while observing
+	if !reconcilers.IsObserveOnly(r.res) {
+		r.res.Status.Observed = nil
+	}
 	err := rec.Prepare(ctx)
 	if err != nil {
 		return err
 	}
+	r.rec = rec
 	return rec.Reconcile(ctx)
 }
 
+// Ready implements ReadyReconciler interface
+func (r *wiReconciler) Ready(ctx context.Context) error {
+	if rec, ok := r.rec.(types.ReadyReconciler); ok {
+		return rec.Ready(ctx)
+	}
+	return nil
+}
+
 // Finalize implements Finalizer interface
 func (r *wiReconciler) Finalize(ctx context.Context) error {
 	var rec wiReconcilerInterface
@@ -10,7 +10,7 @@ require (
 	github.com/Azure/go-autorest/autorest/adal v0.9.22
 	github.com/Azure/go-autorest/autorest/azure/auth v0.5.11
 	github.com/Azure/go-autorest/autorest/to v0.4.0
-	github.com/aws/aws-sdk-go v1.44.97
+	github.com/aws/aws-sdk-go v1.55.5
 	github.com/go-logr/logr v1.2.3
 	github.com/gofrs/uuid v4.3.0+incompatible
 	github.com/google/uuid v1.3.0
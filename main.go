@@ -36,6 +36,7 @@ import (
 	"github.com/invisibl-cloud/identity-manager/controllers"
 	"github.com/invisibl-cloud/identity-manager/pkg/options"
 	"github.com/invisibl-cloud/identity-manager/pkg/reconcilers"
+	"github.com/invisibl-cloud/identity-manager/pkg/webhooks"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -58,11 +59,15 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var enableWebhooks bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Enable the defaulting and validating webhooks. "+
+			"The serving certificates are expected in the webhook server cert dir.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -86,6 +91,7 @@ func main() {
 		os.Exit(1)
 	}
 
+	ctx := ctrl.SetupSignalHandler()
 	if err = (&controllers.WorkloadIdentityReconciler{
 		Base: reconcilers.NewForManager("WorkloadIdentity", mgr, options),
 	}).SetupWithManager(mgr); err != nil {
@@ -94,10 +100,28 @@ func main() {
 	}
 	if err = (&controllers.AWSAuthReconciler{
 		Base: reconcilers.NewForManager("AWSAuth", mgr, options),
-	}).SetupWithManager(mgr); err != nil {
+	}).SetupWithManager(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AWSAuth")
 		os.Exit(1)
 	}
+	if err = (&controllers.ProviderConfigReconciler{
+		Base: reconcilers.NewForManager("ProviderConfig", mgr, options),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ProviderConfig")
+		os.Exit(1)
+	}
+	if err = (&controllers.ClusterProviderConfigReconciler{
+		Base: reconcilers.NewForManager("ClusterProviderConfig", mgr, options),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterProviderConfig")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		if err = webhooks.SetupWithManager(mgr, options); err != nil {
+			setupLog.Error(err, "unable to create webhooks")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -110,7 +134,7 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
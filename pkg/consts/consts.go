@@ -10,12 +10,39 @@ const (
 	// ImportKey defines the annotation key for Imported
 	ImportKey = "identity-manager.io/import"
 
+	// AdoptKey defines the annotation key for Adopt
+	AdoptKey = "identity-manager.io/adopt"
+
+	// DryRunKey defines the annotation key for DryRun
+	DryRunKey = "identity-manager.io/dry-run"
+
+	// ManagementPolicyKey defines the annotation key for ManagementPolicy
+	ManagementPolicyKey = "identity-manager.io/management-policy"
+
 	// InstanceKey is annotation key for instance
 	InstanceKey = "identity-manager.io/instance"
 
 	// AWSAuthNameKey is annotation key for aws-auth instance
 	AWSAuthNameKey = "aws-auth.identity-manager.io/name"
 
+	// AWSAuthManagedAccountsKey is annotation key for the mapAccounts managed in aws-auth configmap
+	AWSAuthManagedAccountsKey = "aws-auth.identity-manager.io/managed-accounts"
+
+	// DeleteValue defines the delete policy which deletes the cloud identity, even imported
+	DeleteValue = "Delete"
+
 	// OrphanValue defines the orphan value
 	OrphanValue = "Orphan"
+
+	// RetainOnImportValue defines the default delete policy which deletes the cloud identity, unless imported
+	RetainOnImportValue = "RetainOnImport"
+
+	// ObserveOnlyValue defines the management policy which only reads the cloud identity
+	ObserveOnlyValue = "ObserveOnly"
+
+	// CredentialsSourceKey is the credentials property of their source, when not loaded from a secret
+	CredentialsSourceKey = "credentials_source"
+
+	// CredentialsPathKey is the credentials property of the file of the Filesystem source
+	CredentialsPathKey = "credentials_path"
 )
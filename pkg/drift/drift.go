@@ -0,0 +1,107 @@
+// Package drift records the changes made to the identities outside of the controller,
+// which the reconcilers find and revert while syncing.
+package drift
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+)
+
+// Types of the drifted resources
+const (
+	TypeRole                = "Role"
+	TypeAssumeRolePolicy    = "AssumeRolePolicy"
+	TypeInlinePolicy        = "InlinePolicy"
+	TypePolicy              = "Policy"
+	TypeManagedPolicy       = "ManagedPolicy"
+	TypeMaxSessionDuration  = "MaxSessionDuration"
+	TypeDescription         = "Description"
+	TypePermissionsBoundary = "PermissionsBoundary"
+	TypeTag                 = "Tag"
+	TypeRoleAssignment      = "RoleAssignment"
+	TypeServiceAccount      = "ServiceAccount"
+	TypeBinding             = "Binding"
+)
+
+// Report is the list of drift found by a reconciliation
+type Report struct {
+	mu    sync.Mutex
+	items []v1alpha1.Drift
+	// inputs is the hash of the inputs resolved outside of the spec
+	inputs string
+}
+
+// New returns an empty Report
+func New() *Report {
+	return &Report{}
+}
+
+// Add records a drift. It does nothing on a nil Report, so that the
+// clients can record the drift whether or not it is reported.
+func (r *Report) Add(driftType string, name string, change v1alpha1.DriftChange) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items = append(r.items, v1alpha1.Drift{Type: driftType, Name: name, Change: change})
+}
+
+// Items returns the recorded drift in order
+func (r *Report) Items() []v1alpha1.Drift {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]v1alpha1.Drift(nil), r.items...)
+}
+
+// SetInputs records the hash of the inputs of the reconciliation which are resolved outside of the spec,
+// e.g. the policy documents of ConfigMaps or the options of the manager. It does nothing on a nil Report.
+func (r *Report) SetInputs(inputs ...any) error {
+	if r == nil {
+		return nil
+	}
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inputs = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// Inputs returns the hash of the inputs, empty when not recorded
+func (r *Report) Inputs() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.inputs
+}
+
+// String describes the drift, e.g. "Policy arn:aws:iam::aws:policy/ReadOnlyAccess Removed"
+func String(d v1alpha1.Drift) string {
+	s := []string{d.Type}
+	if d.Name != "" {
+		s = append(s, d.Name)
+	}
+	return strings.Join(append(s, string(d.Change)), " ")
+}
+
+type reportKey struct{}
+
+// NewContext returns a context that carries the Report
+func NewContext(ctx context.Context, r *Report) context.Context {
+	return context.WithValue(ctx, reportKey{}, r)
+}
+
+// FromContext returns the Report of the context, or nil when the drift is not reported
+func FromContext(ctx context.Context) *Report {
+	r, _ := ctx.Value(reportKey{}).(*Report)
+	return r
+}
@@ -0,0 +1,45 @@
+package drift
+
+import (
+	"context"
+	"testing"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	// drift is not reported without a report
+	var none *Report
+	none.Add(TypePolicy, "arn:aws:iam::aws:policy/ReadOnlyAccess", v1alpha1.DriftChangeRemoved)
+	assert.Nil(t, FromContext(context.Background()))
+
+	r := New()
+	assert.Nil(t, r.Items())
+	r.Add(TypeAssumeRolePolicy, "ccs-v1", v1alpha1.DriftChangeModified)
+	r.Add(TypeTag, "team", v1alpha1.DriftChangeRemoved)
+	assert.Same(t, r, FromContext(NewContext(context.Background(), r)))
+	assert.Equal(t, []v1alpha1.Drift{
+		{Type: TypeAssumeRolePolicy, Name: "ccs-v1", Change: v1alpha1.DriftChangeModified},
+		{Type: TypeTag, Name: "team", Change: v1alpha1.DriftChangeRemoved},
+	}, r.Items())
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "Policy arn:aws:iam::aws:policy/ReadOnlyAccess Added",
+		String(v1alpha1.Drift{Type: TypePolicy, Name: "arn:aws:iam::aws:policy/ReadOnlyAccess", Change: v1alpha1.DriftChangeAdded}))
+	assert.Equal(t, "Description Modified", String(v1alpha1.Drift{Type: TypeDescription, Change: v1alpha1.DriftChangeModified}))
+}
+
+func TestReportInputs(t *testing.T) {
+	var none *Report
+	assert.Nil(t, none.SetInputs("ignored"))
+
+	r := New()
+	assert.Empty(t, r.Inputs())
+	assert.Nil(t, r.SetInputs(map[string]string{"policy": "s3:GetObject"}, "us-east-1"))
+	inputs := r.Inputs()
+	assert.Len(t, inputs, 64)
+	assert.Nil(t, r.SetInputs(map[string]string{"policy": "s3:*"}, "us-east-1"))
+	assert.NotEqual(t, inputs, r.Inputs())
+}
@@ -12,7 +12,10 @@ type Options struct {
 	Tags       flagx.MapFlag
 	NamePrefix string
 	TagPrefix  string
-	AWS        *awsx.Options
+	DryRun     bool
+	// CredentialsDir is the directory of the files of the Filesystem credentials source
+	CredentialsDir string
+	AWS            *awsx.Options
 }
 
 // NewOptions creates new Options
@@ -25,5 +28,7 @@ func (o *Options) BindFlags(fs *flag.FlagSet) {
 	flag.StringVar(&o.NamePrefix, "name-prefix", "", "The resource name prefix.")
 	flag.StringVar(&o.TagPrefix, "tag-prefix", "", "The resource tag prefix. note: this will be applied only to spec.tags")
 	flag.Var(&o.Tags, "tag", "The resource tags. format: key=value")
+	flag.BoolVar(&o.DryRun, "dry-run", false, "Plan the changes of every WorkloadIdentity without applying them. note: same as the identity-manager.io/dry-run annotation")
+	flag.StringVar(&o.CredentialsDir, "credentials-dir", "", "The directory mounted into the controller with the files of the Filesystem credentials source. note: the source is disabled when not set")
 	o.AWS.BindFlags(fs)
 }
@@ -0,0 +1,82 @@
+package plan
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// NewClient returns a client which reads from c and records
+// every write into the Plan instead of sending it to the API server
+func NewClient(c client.Client, p *Plan) client.Client {
+	return &planClient{Client: c, plan: p}
+}
+
+type planClient struct {
+	client.Client
+	plan *Plan
+}
+
+func (c *planClient) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	c.plan.Add("create %s", c.describe(obj))
+	return nil
+}
+
+func (c *planClient) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	c.plan.Add("update %s", c.describe(obj))
+	return nil
+}
+
+func (c *planClient) Patch(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+	c.plan.Add("patch %s", c.describe(obj))
+	return nil
+}
+
+func (c *planClient) Delete(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+	c.plan.Add("delete %s", c.describe(obj))
+	return nil
+}
+
+func (c *planClient) DeleteAllOf(_ context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	o := &client.DeleteAllOfOptions{}
+	o.ApplyOptions(opts)
+	selector := labels.Everything()
+	if o.LabelSelector != nil {
+		selector = o.LabelSelector
+	}
+	c.plan.Add("delete all %s in namespace %s matching %q", c.kind(obj), o.Namespace, selector.String())
+	return nil
+}
+
+func (c *planClient) Status() client.StatusWriter {
+	return &planStatusWriter{client: c}
+}
+
+// describe returns kind namespace/name of the object
+func (c *planClient) describe(obj client.Object) string {
+	return c.kind(obj) + " " + client.ObjectKeyFromObject(obj).String()
+}
+
+func (c *planClient) kind(obj client.Object) string {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return "object"
+	}
+	return gvk.Kind
+}
+
+type planStatusWriter struct {
+	client *planClient
+}
+
+func (w *planStatusWriter) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	w.client.plan.Add("update status of %s", w.client.describe(obj))
+	return nil
+}
+
+func (w *planStatusWriter) Patch(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+	w.client.plan.Add("patch status of %s", w.client.describe(obj))
+	return nil
+}
@@ -0,0 +1,54 @@
+// Package plan records the changes a reconciler would apply, so that they can be
+// published instead of applied when the reconciliation runs in dry-run mode.
+package plan
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Plan is the ordered list of changes of a dry-run reconciliation
+type Plan struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+// New returns an empty Plan
+func New() *Plan {
+	return &Plan{}
+}
+
+// Add records a change, duplicates are recorded once
+func (p *Plan) Add(format string, args ...any) {
+	change := fmt.Sprintf(format, args...)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.changes {
+		if c == change {
+			return
+		}
+	}
+	p.changes = append(p.changes, change)
+}
+
+// Changes returns the recorded changes in order
+func (p *Plan) Changes() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.changes...)
+}
+
+type planKey struct{}
+
+// NewContext returns a context that carries the Plan, the reconcilers record
+// their changes into it instead of applying them
+func NewContext(ctx context.Context, p *Plan) context.Context {
+	return context.WithValue(ctx, planKey{}, p)
+}
+
+// FromContext returns the Plan of the context, or nil when not in dry-run mode
+func FromContext(ctx context.Context) *Plan {
+	p, _ := ctx.Value(planKey{}).(*Plan)
+	return p
+}
@@ -0,0 +1,56 @@
+package plan
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPlan(t *testing.T) {
+	p := New()
+	assert.Nil(t, p.Changes())
+	p.Add("create IAM role %s", "ccs-v1")
+	p.Add("attach policy %s", "ReadOnlyAccess")
+	p.Add("create IAM role %s", "ccs-v1")
+	assert.Equal(t, []string{"create IAM role ccs-v1", "attach policy ReadOnlyAccess"}, p.Changes())
+
+	assert.Nil(t, FromContext(context.Background()))
+	assert.Same(t, p, FromContext(NewContext(context.Background(), p)))
+}
+
+func TestClient(t *testing.T) {
+	existing := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "dev"}}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(existing).Build()
+	p := New()
+	c := NewClient(k8sClient, p)
+	ctx := context.Background()
+
+	// reads are served by the client
+	sa := &corev1.ServiceAccount{}
+	assert.Nil(t, c.Get(ctx, client.ObjectKeyFromObject(existing), sa))
+
+	// writes are recorded
+	sa.Annotations = map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::12345678:role/ccs-v1"}
+	assert.Nil(t, c.Update(ctx, sa))
+	assert.Nil(t, c.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "dev"}}))
+	assert.Nil(t, c.Delete(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app-0", Namespace: "dev"}}))
+	assert.Nil(t, c.Status().Update(ctx, sa))
+	assert.Equal(t, []string{
+		"update ServiceAccount dev/app",
+		"create Secret dev/creds",
+		"delete Pod dev/app-0",
+		"update status of ServiceAccount dev/app",
+	}, p.Changes())
+
+	// and not applied
+	assert.Nil(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(existing), sa))
+	assert.Empty(t, sa.Annotations)
+	err := k8sClient.Get(ctx, client.ObjectKey{Name: "creds", Namespace: "dev"}, &corev1.Secret{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,107 @@
+package awsx
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// RoleChain returns the roles of role_arn in the order they are assumed, e.g. hub account then spoke account
+func (conf Config) RoleChain() []string {
+	roles := []string{}
+	for _, role := range strings.Split(conf.RoleArn, ",") {
+		role = strings.TrimSpace(role)
+		if role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// assumeRoleChain returns the credentials of the last role of the chain. Every role is assumed with the
+// credentials of the previous one, the first one with the base credentials, nil for the default chain.
+// The session tags and the source identity are set on the first role, and are kept along the chain.
+// The external id is the one of the last role, usually the role of another account.
+func assumeRoleChain(conf Config, roles []string, base *credentials.Credentials, newClient func(*credentials.Credentials) stscreds.AssumeRoler) (*credentials.Credentials, error) {
+	duration, err := parseSessionDuration(conf.SessionDuration)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := parseSessionTags(conf.SessionTags)
+	if err != nil {
+		return nil, err
+	}
+	creds := base
+	for i, role := range roles {
+		p := &stscreds.AssumeRoleProvider{
+			Client:          newClient(creds),
+			RoleARN:         role,
+			RoleSessionName: conf.Name,
+			Duration:        duration,
+		}
+		if i == 0 {
+			p.Tags = tags
+			if len(roles) > 1 {
+				for _, tag := range tags {
+					p.TransitiveTagKeys = append(p.TransitiveTagKeys, tag.Key)
+				}
+			}
+			if conf.SourceIdentity != "" {
+				p.SourceIdentity = aws.String(conf.SourceIdentity)
+			}
+		}
+		if i == len(roles)-1 && conf.ExternalID != "" {
+			p.ExternalID = aws.String(conf.ExternalID)
+		}
+		creds = credentials.NewCredentials(p)
+	}
+	return creds, nil
+}
+
+// parseSessionDuration parses a duration, e.g. 1h, or a number of seconds. Zero is the default duration.
+func parseSessionDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(s); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q - %w", SessionDurationName, s, err)
+	}
+	return duration, nil
+}
+
+// parseSessionTags parses the session tags, formatted as key1=value1,key2=value2
+func parseSessionTags(s string) ([]*sts.Tag, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid %s %q, expected key=value", SessionTagsName, pair)
+		}
+		m[key] = strings.TrimSpace(value)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tags := make([]*sts.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, &sts.Tag{Key: aws.String(k), Value: aws.String(m[k])})
+	}
+	return tags, nil
+}
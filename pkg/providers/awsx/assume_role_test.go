@@ -0,0 +1,132 @@
+package awsx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func assumeRoleOutput(key string) *sts.AssumeRoleOutput {
+	return &sts.AssumeRoleOutput{Credentials: &sts.Credentials{
+		AccessKeyId:     aws.String(key),
+		SecretAccessKey: aws.String(key + "-secret"),
+		SessionToken:    aws.String(key + "-token"),
+		Expiration:      aws.Time(time.Now().Add(time.Hour)),
+	}}
+}
+
+func TestAssumeRoleChain(t *testing.T) {
+	hub := "arn:aws:iam::111111111111:role/hub"
+	spoke := "arn:aws:iam::222222222222:role/spoke"
+	conf := NewConfig(map[string][]byte{
+		RoleArnName:         []byte(hub + ", " + spoke),
+		ExternalIDName:      []byte("external"),
+		SessionDurationName: []byte("30m"),
+		SessionTagsName:     []byte("team=platform,env=prod"),
+		SourceIdentityName:  []byte("identity-manager"),
+	})
+	conf.Name = "demo"
+	require.Equal(t, []string{hub, spoke}, conf.RoleChain())
+
+	stsClient := mocks.NewSTS(t)
+	stsClient.On("AssumeRole", mock.MatchedBy(func(input *sts.AssumeRoleInput) bool {
+		return aws.StringValue(input.RoleArn) == hub
+	})).Run(func(args mock.Arguments) {
+		input := args.Get(0).(*sts.AssumeRoleInput)
+		assert.Equal(t, "demo", aws.StringValue(input.RoleSessionName))
+		assert.Equal(t, int64(1800), aws.Int64Value(input.DurationSeconds))
+		assert.Equal(t, []*sts.Tag{
+			{Key: aws.String("env"), Value: aws.String("prod")},
+			{Key: aws.String("team"), Value: aws.String("platform")},
+		}, input.Tags)
+		assert.Equal(t, []*string{aws.String("env"), aws.String("team")}, input.TransitiveTagKeys)
+		assert.Equal(t, "identity-manager", aws.StringValue(input.SourceIdentity))
+		assert.Nil(t, input.ExternalId)
+	}).Return(assumeRoleOutput("hub"), nil).Once()
+	stsClient.On("AssumeRole", mock.MatchedBy(func(input *sts.AssumeRoleInput) bool {
+		return aws.StringValue(input.RoleArn) == spoke
+	})).Run(func(args mock.Arguments) {
+		input := args.Get(0).(*sts.AssumeRoleInput)
+		assert.Equal(t, int64(1800), aws.Int64Value(input.DurationSeconds))
+		assert.Equal(t, "external", aws.StringValue(input.ExternalId))
+		// the tags and the source identity are kept along the chain
+		assert.Nil(t, input.Tags)
+		assert.Nil(t, input.SourceIdentity)
+	}).Return(assumeRoleOutput("spoke"), nil).Once()
+
+	// every role is assumed with the credentials of the previous one
+	var previous []*credentials.Credentials
+	creds, err := assumeRoleChain(conf, conf.RoleChain(), nil, func(creds *credentials.Credentials) stscreds.AssumeRoler {
+		previous = append(previous, creds)
+		return stsClient
+	})
+	require.Nil(t, err)
+	require.Len(t, previous, 2)
+	assert.Nil(t, previous[0])
+
+	hubValue, err := previous[1].Get()
+	require.Nil(t, err)
+	assert.Equal(t, "hub", hubValue.AccessKeyID)
+	spokeValue, err := creds.Get()
+	require.Nil(t, err)
+	assert.Equal(t, "spoke", spokeValue.AccessKeyID)
+	assert.Equal(t, "spoke-token", spokeValue.SessionToken)
+}
+
+func TestAssumeRoleExternalID(t *testing.T) {
+	role := "arn:aws:iam::222222222222:role/vendor"
+	conf := NewConfig(map[string][]byte{
+		RoleArnName:         []byte(role),
+		ExternalIDName:      []byte("external"),
+		SessionDurationName: []byte("3600"),
+	})
+
+	stsClient := mocks.NewSTS(t)
+	stsClient.On("AssumeRole", mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(0).(*sts.AssumeRoleInput)
+		assert.Equal(t, role, aws.StringValue(input.RoleArn))
+		assert.Equal(t, "external", aws.StringValue(input.ExternalId))
+		assert.Equal(t, int64(3600), aws.Int64Value(input.DurationSeconds))
+		assert.Nil(t, input.TransitiveTagKeys)
+	}).Return(assumeRoleOutput("vendor"), nil).Once()
+
+	base := credentials.NewStaticCredentials("base", "base-secret", "")
+	var previous *credentials.Credentials
+	creds, err := assumeRoleChain(conf, conf.RoleChain(), base, func(creds *credentials.Credentials) stscreds.AssumeRoler {
+		previous = creds
+		return stsClient
+	})
+	require.Nil(t, err)
+	assert.Same(t, base, previous)
+	value, err := creds.Get()
+	require.Nil(t, err)
+	assert.Equal(t, "vendor", value.AccessKeyID)
+}
+
+func TestAssumeRoleInvalidSession(t *testing.T) {
+	testCases := []struct {
+		desc string
+		data map[string][]byte
+	}{
+		{
+			desc: "Invalid session duration",
+			data: map[string][]byte{RoleArnName: []byte("arn:aws:iam::111111111111:role/hub"), SessionDurationName: []byte("an hour")},
+		},
+		{
+			desc: "Invalid session tags",
+			data: map[string][]byte{RoleArnName: []byte("arn:aws:iam::111111111111:role/hub"), SessionTagsName: []byte("team")},
+		},
+	}
+	for _, testCase := range testCases {
+		_, err := NewSession(NewConfig(testCase.data))
+		assert.NotNil(t, err, testCase.desc)
+	}
+}
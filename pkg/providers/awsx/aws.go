@@ -1,15 +1,23 @@
 package awsx
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"os"
 
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
 	"github.com/invisibl-cloud/identity-manager/pkg/util"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/defaults"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
 )
 
 const (
@@ -20,10 +28,35 @@ const (
 	// SecretAccessKeyName - aws secret access key name
 	// #nosec
 	SecretAccessKeyName = "aws_secret_access_key"
-	// RoleArnName - role arn name
+	// RoleArnName - role arn name, or the comma separated roles of a chain
 	RoleArnName = "role_arn"
-	// ExternalIDName - external id name
+	// ExternalIDName - external id name of the last role
 	ExternalIDName = "external_id"
+	// OIDCProviderName - oidc provider arn or issuer url name
+	OIDCProviderName = "oidc_provider"
+	// ClusterNameName - eks cluster name
+	ClusterNameName = "cluster_name"
+	// CredentialsSourceName - source of the credentials, when not loaded from a secret
+	CredentialsSourceName = consts.CredentialsSourceKey
+	// CredentialsPathName - file of the Filesystem credentials source
+	CredentialsPathName = consts.CredentialsPathKey
+	// FileTypeName - type of the file of the Filesystem credentials source, shared_credentials by default
+	FileTypeName = "file_type"
+	// ProfileName - profile of the shared credentials file
+	ProfileName = "profile"
+	// SessionDurationName - duration of the assumed role sessions, e.g. 1h or 3600
+	SessionDurationName = "session_duration"
+	// SessionTagsName - tags of the assumed role sessions, key1=value1,key2=value2
+	SessionTagsName = "session_tags"
+	// SourceIdentityName - source identity of the assumed role sessions
+	SourceIdentityName = "source_identity"
+)
+
+const (
+	// FileTypeSharedCredentials - shared credentials file, the roles of role_arn are assumed with its credentials
+	FileTypeSharedCredentials = "shared_credentials"
+	// FileTypeWebIdentityToken - web identity token, the first role of role_arn is assumed with it
+	FileTypeWebIdentityToken = "web_identity_token"
 )
 
 // NewConfig expects the map of config data and returns
@@ -45,6 +78,33 @@ func NewConfig(m map[string][]byte) Config {
 	if val, ok := m[ExternalIDName]; ok {
 		cfg.ExternalID = string(val)
 	}
+	if val, ok := m[OIDCProviderName]; ok {
+		cfg.OIDCProvider = string(val)
+	}
+	if val, ok := m[ClusterNameName]; ok {
+		cfg.ClusterName = string(val)
+	}
+	if val, ok := m[CredentialsSourceName]; ok {
+		cfg.CredentialsSource = string(val)
+	}
+	if val, ok := m[CredentialsPathName]; ok {
+		cfg.CredentialsPath = string(val)
+	}
+	if val, ok := m[FileTypeName]; ok {
+		cfg.FileType = string(val)
+	}
+	if val, ok := m[ProfileName]; ok {
+		cfg.Profile = string(val)
+	}
+	if val, ok := m[SessionDurationName]; ok {
+		cfg.SessionDuration = string(val)
+	}
+	if val, ok := m[SessionTagsName]; ok {
+		cfg.SessionTags = string(val)
+	}
+	if val, ok := m[SourceIdentityName]; ok {
+		cfg.SourceIdentity = string(val)
+	}
 	return cfg
 }
 
@@ -58,28 +118,31 @@ func NewSession(conf Config) (*session.Session, error) {
 	} else {
 		cfg.Region = aws.String(util.GetEnvString(conf.Region, "AWS_REGION", "AWS_DEFAULT_REGION"))
 	}
-	// assume role.
-	if conf.RoleArn != "" {
-		cfgs1 := []*aws.Config{cfg}
-		if conf.AccessKeyID != "" && conf.SecretAccessKey != "" {
-			credsCfg := aws.NewConfig().WithCredentials(credentials.NewStaticCredentials(conf.AccessKeyID, conf.SecretAccessKey, conf.SessionToken))
-			cfgs1 = append(cfgs1, credsCfg)
-		}
-		sess1, err := session.NewSession(cfgs1...)
+	creds, assumed, err := sourceCredentials(conf, cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Credentials = creds
+	// assume the roles, but the first one when already assumed with the web identity token.
+	roles := conf.RoleChain()
+	if assumed {
+		roles = roles[1:]
+	}
+	if len(roles) > 0 {
+		sess1, err := session.NewSession(cfg)
 		if err != nil {
 			return nil, err
 		}
-		creds := stscreds.NewCredentials(sess1, conf.RoleArn, func(arp *stscreds.AssumeRoleProvider) {
-			arp.RoleSessionName = conf.Name
-			//arp.Duration = 60 * time.Minute
-			//arp.ExpiryWindow = 30 * time.Second
+		creds, err := assumeRoleChain(conf, roles, creds, func(creds *credentials.Credentials) stscreds.AssumeRoler {
+			if creds == nil {
+				return sts.New(sess1)
+			}
+			return sts.New(sess1, aws.NewConfig().WithCredentials(creds))
 		})
-		cfgs = append(cfgs, aws.NewConfig().WithCredentials(creds))
-	} else {
-		// static creds if any
-		if conf.AccessKeyID != "" && conf.SecretAccessKey != "" {
-			cfg.Credentials = credentials.NewStaticCredentials(conf.AccessKeyID, conf.SecretAccessKey, conf.SessionToken)
+		if err != nil {
+			return nil, err
 		}
+		cfgs = append(cfgs, aws.NewConfig().WithCredentials(creds))
 	}
 	sess, err := session.NewSession(cfgs...)
 	if err != nil {
@@ -88,6 +151,50 @@ func NewSession(conf Config) (*session.Session, error) {
 	return sess, nil
 }
 
+// sourceCredentials returns the credentials of the source, nil for the default credential chain.
+// assumed is true when the first role of the chain is assumed with the web identity token of the Filesystem source.
+func sourceCredentials(conf Config, cfg *aws.Config) (creds *credentials.Credentials, assumed bool, err error) {
+	switch v1alpha1.CredentialsSource(conf.CredentialsSource) {
+	case v1alpha1.CredentialsSourceEnvironment:
+		return credentials.NewEnvCredentials(), false, nil
+	case v1alpha1.CredentialsSourceInjectedIdentity:
+		// IRSA, the token is projected by the EKS pod identity webhook
+		if tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); tokenFile != "" {
+			sess, err := session.NewSession(cfg)
+			if err != nil {
+				return nil, false, err
+			}
+			return stscreds.NewWebIdentityCredentials(sess, os.Getenv("AWS_ROLE_ARN"), conf.Name, tokenFile), false, nil
+		}
+		// EKS Pod Identity or the instance profile
+		return credentials.NewCredentials(defaults.RemoteCredProvider(*defaults.Config(), defaults.Handlers())), false, nil
+	case v1alpha1.CredentialsSourceFilesystem:
+		if conf.CredentialsPath == "" {
+			return nil, false, fmt.Errorf("missing %s for %s credentials", CredentialsPathName, conf.CredentialsSource)
+		}
+		switch conf.FileType {
+		case "", FileTypeSharedCredentials:
+			return credentials.NewSharedCredentials(conf.CredentialsPath, conf.Profile), false, nil
+		case FileTypeWebIdentityToken:
+			roles := conf.RoleChain()
+			if len(roles) == 0 {
+				return nil, false, types.Errorf(types.ErrorInvalidSpec, "missing %s for the %s %s", RoleArnName, FileTypeWebIdentityToken, FileTypeName)
+			}
+			sess, err := session.NewSession(cfg)
+			if err != nil {
+				return nil, false, err
+			}
+			return stscreds.NewWebIdentityCredentials(sess, roles[0], conf.Name, conf.CredentialsPath), true, nil
+		}
+		return nil, false, types.Errorf(types.ErrorInvalidSpec, "invalid %s %s, either %s or %s", FileTypeName, conf.FileType, FileTypeSharedCredentials, FileTypeWebIdentityToken)
+	}
+	// static creds if any
+	if conf.AccessKeyID != "" && conf.SecretAccessKey != "" {
+		return credentials.NewStaticCredentials(conf.AccessKeyID, conf.SecretAccessKey, conf.SessionToken), false, nil
+	}
+	return nil, false, nil
+}
+
 // Config - simple aws session config
 type Config struct {
 	Name            string `json:"-" ini:"-"`
@@ -97,6 +204,17 @@ type Config struct {
 	SessionToken    string `json:"aws_session_token" ini:"aws_session_token"`
 	RoleArn         string `json:"role_arn" ini:"-"`
 	ExternalID      string `json:"external_id" ini:"-"`
+	OIDCProvider    string `json:"oidc_provider" ini:"-"`
+	ClusterName     string `json:"cluster_name" ini:"-"`
+	// source of the credentials, when not loaded from a secret
+	CredentialsSource string `json:"credentials_source" ini:"-"`
+	CredentialsPath   string `json:"credentials_path" ini:"-"`
+	FileType          string `json:"file_type" ini:"-"`
+	Profile           string `json:"profile" ini:"-"`
+	// sessions of the assumed roles
+	SessionDuration string `json:"session_duration" ini:"-"`
+	SessionTags     string `json:"session_tags" ini:"-"`
+	SourceIdentity  string `json:"source_identity" ini:"-"`
 }
 
 // CheckError - check aws error code.
@@ -112,12 +230,69 @@ func CheckError(err error, codes ...string) (bool, bool) {
 	return false, false
 }
 
+// errorCategories maps the aws error codes to the error categories
+var errorCategories = map[string]types.ErrorCategory{
+	"ExpiredToken":                  types.ErrorAuthFailed,
+	"ExpiredTokenException":         types.ErrorAuthFailed,
+	"IncompleteSignature":           types.ErrorAuthFailed,
+	"InvalidClientTokenId":          types.ErrorAuthFailed,
+	"InvalidIdentityToken":          types.ErrorAuthFailed,
+	"MissingAuthenticationToken":    types.ErrorAuthFailed,
+	"NoCredentialProviders":         types.ErrorAuthFailed,
+	"SignatureDoesNotMatch":         types.ErrorAuthFailed,
+	"UnrecognizedClientException":   types.ErrorAuthFailed,
+	"AccessDenied":                  types.ErrorPermissionDenied,
+	"AccessDeniedException":         types.ErrorPermissionDenied,
+	"UnauthorizedOperation":         types.ErrorPermissionDenied,
+	"Throttling":                    types.ErrorThrottled,
+	"ThrottlingException":           types.ErrorThrottled,
+	"RequestLimitExceeded":          types.ErrorThrottled,
+	"TooManyRequestsException":      types.ErrorThrottled,
+	"InvalidInput":                  types.ErrorInvalidSpec,
+	"InvalidParameterException":     types.ErrorInvalidSpec,
+	"InvalidParameterValue":         types.ErrorInvalidSpec,
+	"MalformedPolicyDocument":       types.ErrorInvalidSpec,
+	"ValidationError":               types.ErrorInvalidSpec,
+	"ValidationException":           types.ErrorInvalidSpec,
+	"NoSuchEntity":                  types.ErrorDependencyMissing,
+	"ResourceNotFoundException":     types.ErrorDependencyMissing,
+	"LimitExceeded":                 types.ErrorQuotaExceeded,
+	"LimitExceededException":        types.ErrorQuotaExceeded,
+	"ServiceQuotaExceededException": types.ErrorQuotaExceeded,
+	"ConcurrentModification":        types.ErrorConflict,
+	"DeleteConflict":                types.ErrorConflict,
+	"EntityAlreadyExists":           types.ErrorConflict,
+	"ResourceInUseException":        types.ErrorConflict,
+}
+
+// ToError categorizes the aws error and keeps its request id, other errors are returned as is
+func ToError(err error) error {
+	var e *types.Error
+	var aerr awserr.Error
+	if errors.As(err, &e) || !errors.As(err, &aerr) {
+		return err
+	}
+	e = &types.Error{Category: errorCategories[aerr.Code()], Message: aerr.Code() + ": " + aerr.Message(), Err: err}
+	var rerr awserr.RequestFailure
+	if errors.As(err, &rerr) {
+		if e.Category == "" {
+			e.Category = types.HTTPErrorCategory(rerr.StatusCode())
+		}
+		e.RequestID = rerr.RequestID()
+	}
+	return e
+}
+
 // Options of AWS
 type Options struct {
 	PermissionsBoundaryARN string
+	OIDCProvider           string
+	ClusterName            string
 }
 
 // BindFlags will parse the given flagset for aws arg flags.
 func (o *Options) BindFlags(fs *flag.FlagSet) {
 	flag.StringVar(&o.PermissionsBoundaryARN, "aws-permissions-boundary-arn", "", "The permissions boundary arn.")
+	flag.StringVar(&o.OIDCProvider, "aws-oidc-provider", "", "The OIDC provider arn or issuer url of the cluster. note: used to generate the assume role policy")
+	flag.StringVar(&o.ClusterName, "aws-cluster-name", "", "The EKS cluster name. note: used to render the <(clusterName) of the policies")
 }
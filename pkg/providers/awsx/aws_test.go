@@ -0,0 +1,165 @@
+package awsx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToError(t *testing.T) {
+	testCases := []struct {
+		desc              string
+		err               error
+		expectedCategory  types.ErrorCategory
+		expectedMessage   string
+		expectedRequestID string
+	}{
+		{
+			desc:              "Access denied keeps the request id",
+			err:               awserr.NewRequestFailure(awserr.New("AccessDenied", "not authorized to perform: iam:CreateRole", nil), 403, "abcd"),
+			expectedCategory:  types.ErrorPermissionDenied,
+			expectedMessage:   "AccessDenied: not authorized to perform: iam:CreateRole (request id: abcd)",
+			expectedRequestID: "abcd",
+		},
+		{
+			desc:             "Wrapped throttling",
+			err:              fmt.Errorf("creating role: %w", awserr.New("Throttling", "Rate exceeded", nil)),
+			expectedCategory: types.ErrorThrottled,
+			expectedMessage:  "Throttling: Rate exceeded",
+		},
+		{
+			desc:              "Unknown code categorized from the status code",
+			err:               awserr.NewRequestFailure(awserr.New("Unknown", "conflict", nil), 409, "efgh"),
+			expectedCategory:  types.ErrorConflict,
+			expectedMessage:   "Unknown: conflict (request id: efgh)",
+			expectedRequestID: "efgh",
+		},
+		{
+			desc:            "Not an aws error",
+			err:             errors.New("failed"),
+			expectedMessage: "failed",
+		},
+	}
+	for _, testCase := range testCases {
+		err := ToError(testCase.err)
+		assert.Equal(t, testCase.expectedCategory, types.Category(err), testCase.desc)
+		assert.Equal(t, testCase.expectedMessage, err.Error(), testCase.desc)
+		var e *types.Error
+		if errors.As(err, &e) {
+			assert.Equal(t, testCase.expectedRequestID, e.RequestID, testCase.desc)
+		}
+	}
+	assert.Nil(t, ToError(nil))
+}
+
+func TestNewSessionCredentialsSource(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	sharedFile := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(sharedFile, []byte("[default]\naws_access_key_id = file-key\naws_secret_access_key = file-secret\n"+
+		"[prod]\naws_access_key_id = prod-key\naws_secret_access_key = prod-secret\n"), 0600)
+	require.Nil(t, err)
+
+	testCases := []struct {
+		desc        string
+		data        map[string][]byte
+		expectedKey string
+	}{
+		{
+			desc:        "Static keys of the secret",
+			data:        map[string][]byte{AccessKeyIDName: []byte("secret-key"), SecretAccessKeyName: []byte("secret-secret")},
+			expectedKey: "secret-key",
+		},
+		{
+			desc: "Environment of the controller, the keys of the secret are ignored",
+			data: map[string][]byte{
+				CredentialsSourceName: []byte(v1alpha1.CredentialsSourceEnvironment),
+				AccessKeyIDName:       []byte("secret-key"),
+				SecretAccessKeyName:   []byte("secret-secret"),
+			},
+			expectedKey: "env-key",
+		},
+		{
+			desc: "Shared credentials file mounted into the controller",
+			data: map[string][]byte{
+				CredentialsSourceName: []byte(v1alpha1.CredentialsSourceFilesystem),
+				CredentialsPathName:   []byte(sharedFile),
+			},
+			expectedKey: "file-key",
+		},
+		{
+			desc: "Profile of the shared credentials file",
+			data: map[string][]byte{
+				CredentialsSourceName: []byte(v1alpha1.CredentialsSourceFilesystem),
+				CredentialsPathName:   []byte(sharedFile),
+				ProfileName:           []byte("prod"),
+			},
+			expectedKey: "prod-key",
+		},
+	}
+	for _, testCase := range testCases {
+		sess, err := NewSession(NewConfig(testCase.data))
+		require.Nil(t, err, testCase.desc)
+		value, err := sess.Config.Credentials.Get()
+		require.Nil(t, err, testCase.desc)
+		assert.Equal(t, testCase.expectedKey, value.AccessKeyID, testCase.desc)
+	}
+}
+
+func TestNewSessionFilesystemWithoutPath(t *testing.T) {
+	_, err := NewSession(NewConfig(map[string][]byte{CredentialsSourceName: []byte(v1alpha1.CredentialsSourceFilesystem)}))
+	assert.NotNil(t, err)
+}
+
+func TestSourceCredentialsFileType(t *testing.T) {
+	sharedFile := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(sharedFile, []byte("[default]\naws_access_key_id = file-key\naws_secret_access_key = file-secret\n"), 0600)
+	require.Nil(t, err)
+	roleArn := "arn:aws:iam::12345678:role/hub,arn:aws:iam::87654321:role/spoke"
+
+	// the roles are assumed with the keys of the shared credentials file
+	creds, assumed, err := sourceCredentials(NewConfig(map[string][]byte{
+		CredentialsSourceName: []byte(v1alpha1.CredentialsSourceFilesystem),
+		CredentialsPathName:   []byte(sharedFile),
+		RoleArnName:           []byte(roleArn),
+	}), aws.NewConfig())
+	require.Nil(t, err)
+	assert.False(t, assumed)
+	value, err := creds.Get()
+	require.Nil(t, err)
+	assert.Equal(t, "file-key", value.AccessKeyID)
+
+	// the first role is assumed with the web identity token
+	_, assumed, err = sourceCredentials(NewConfig(map[string][]byte{
+		CredentialsSourceName: []byte(v1alpha1.CredentialsSourceFilesystem),
+		CredentialsPathName:   []byte(sharedFile),
+		FileTypeName:          []byte(FileTypeWebIdentityToken),
+		RoleArnName:           []byte(roleArn),
+	}), aws.NewConfig())
+	require.Nil(t, err)
+	assert.True(t, assumed)
+
+	// the web identity token requires a role
+	_, _, err = sourceCredentials(NewConfig(map[string][]byte{
+		CredentialsSourceName: []byte(v1alpha1.CredentialsSourceFilesystem),
+		CredentialsPathName:   []byte(sharedFile),
+		FileTypeName:          []byte(FileTypeWebIdentityToken),
+	}), aws.NewConfig())
+	assert.Equal(t, types.ErrorInvalidSpec, types.Category(err))
+
+	_, _, err = sourceCredentials(NewConfig(map[string][]byte{
+		CredentialsSourceName: []byte(v1alpha1.CredentialsSourceFilesystem),
+		CredentialsPathName:   []byte(sharedFile),
+		FileTypeName:          []byte("token"),
+	}), aws.NewConfig())
+	assert.Equal(t, types.ErrorInvalidSpec, types.Category(err))
+}
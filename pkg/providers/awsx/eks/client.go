@@ -0,0 +1,259 @@
+package eks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eks"
+	v1alpha1 "github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// OwnerTagKey is the tag key of the access entries managed by identity-manager
+const OwnerTagKey = "identity-manager.io/awsauth"
+
+const defaultAccessScopeType = eks.AccessScopeTypeCluster
+
+// AccessEntry is the desired access entry of an IAM principal
+type AccessEntry struct {
+	PrincipalArn   string
+	Username       string
+	Groups         []string
+	AccessPolicies []v1alpha1.AccessPolicy
+}
+
+// Client manages the access entries of an EKS cluster owned by an AWSAuth
+type Client struct {
+	eks         awsx.EKS
+	clusterName string
+	owner       string
+}
+
+// New expects wrapped eks client, the cluster name and the owner of the access entries
+// and returns them by packing them together
+func New(eksClient awsx.EKS, clusterName string, owner string) *Client {
+	return &Client{
+		eks:         eksClient,
+		clusterName: clusterName,
+		owner:       owner,
+	}
+}
+
+// Sync creates, updates and deletes the access entries owned by the client.
+// Access entries not owned by the client are never modified,
+// desired entries colliding with them are skipped and returned as conflicts.
+// Desired entries rejected by EKS are skipped and returned as conflicts as well.
+func (c *Client) Sync(entries []*AccessEntry) ([]string, error) {
+	existing, err := c.describeAccessEntries()
+	if err != nil {
+		return nil, err
+	}
+	conflicts := []string{}
+	desired := map[string]bool{}
+	for _, entry := range entries {
+		desired[entry.PrincipalArn] = true
+		if reason := unsupported(entry); reason != "" {
+			conflicts = append(conflicts, skippedMessage(entry.PrincipalArn, reason))
+			continue
+		}
+		current, ok := existing[entry.PrincipalArn]
+		if !ok {
+			err = c.create(entry)
+		} else if owner := aws.StringValue(current.Tags[OwnerTagKey]); owner != c.owner {
+			conflicts = append(conflicts, conflictMessage(entry.PrincipalArn, owner))
+			continue
+		} else {
+			err = c.update(current, entry)
+		}
+		if err != nil {
+			// an invalid item does not block the next ones
+			err = awsx.ToError(err)
+			if types.Category(err) != types.ErrorInvalidSpec {
+				return nil, err
+			}
+			conflicts = append(conflicts, skippedMessage(entry.PrincipalArn, err.Error()))
+		}
+	}
+	for principalArn, current := range existing {
+		if desired[principalArn] || aws.StringValue(current.Tags[OwnerTagKey]) != c.owner {
+			continue
+		}
+		err = c.delete(principalArn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return conflicts, nil
+}
+
+// Delete deletes all the access entries owned by the client
+func (c *Client) Delete() error {
+	_, err := c.Sync(nil)
+	return err
+}
+
+func conflictMessage(principalArn string, owner string) string {
+	if owner == "" {
+		return fmt.Sprintf("access entry %s conflicts with an unmanaged entry", principalArn)
+	}
+	return fmt.Sprintf("access entry %s conflicts with an entry from %s", principalArn, owner)
+}
+
+// unsupported returns why EKS rejects the access entry of an aws-auth item, if it does: the system groups
+// are reserved and the username templates of aws-auth are not supported
+func unsupported(entry *AccessEntry) string {
+	for _, group := range entry.Groups {
+		if strings.HasPrefix(group, "system:") {
+			return fmt.Sprintf("group %s is reserved", group)
+		}
+	}
+	if strings.Contains(entry.Username, "{{") {
+		return fmt.Sprintf("username template %s is not supported", entry.Username)
+	}
+	return ""
+}
+
+func skippedMessage(principalArn string, reason string) string {
+	return fmt.Sprintf("access entry %s is skipped: %s", principalArn, reason)
+}
+
+func (c *Client) describeAccessEntries() (map[string]*eks.AccessEntry, error) {
+	principalArns := []*string{}
+	err := c.eks.ListAccessEntriesPages(&eks.ListAccessEntriesInput{
+		ClusterName: &c.clusterName,
+	}, func(page *eks.ListAccessEntriesOutput, lastPage bool) bool {
+		principalArns = append(principalArns, page.AccessEntries...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]*eks.AccessEntry{}
+	for _, principalArn := range principalArns {
+		out, err := c.eks.DescribeAccessEntry(&eks.DescribeAccessEntryInput{
+			ClusterName:  &c.clusterName,
+			PrincipalArn: principalArn,
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries[aws.StringValue(principalArn)] = out.AccessEntry
+	}
+	return entries, nil
+}
+
+func (c *Client) create(entry *AccessEntry) error {
+	_, err := c.eks.CreateAccessEntry(&eks.CreateAccessEntryInput{
+		ClusterName:      &c.clusterName,
+		PrincipalArn:     aws.String(entry.PrincipalArn),
+		Username:         aws.String(entry.Username),
+		KubernetesGroups: aws.StringSlice(entry.Groups),
+		Tags:             map[string]*string{OwnerTagKey: aws.String(c.owner)},
+	})
+	if err != nil {
+		return err
+	}
+	return c.syncAccessPolicies(entry)
+}
+
+func (c *Client) update(current *eks.AccessEntry, entry *AccessEntry) error {
+	if aws.StringValue(current.Username) != entry.Username ||
+		!equality.Semantic.DeepEqual(sorted(aws.StringValueSlice(current.KubernetesGroups)), sorted(entry.Groups)) {
+		_, err := c.eks.UpdateAccessEntry(&eks.UpdateAccessEntryInput{
+			ClusterName:      &c.clusterName,
+			PrincipalArn:     aws.String(entry.PrincipalArn),
+			Username:         aws.String(entry.Username),
+			KubernetesGroups: aws.StringSlice(entry.Groups),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return c.syncAccessPolicies(entry)
+}
+
+func (c *Client) delete(principalArn string) error {
+	// deleting the access entry also disassociates its access policies
+	_, err := c.eks.DeleteAccessEntry(&eks.DeleteAccessEntryInput{
+		ClusterName:  &c.clusterName,
+		PrincipalArn: aws.String(principalArn),
+	})
+	return err
+}
+
+func (c *Client) syncAccessPolicies(entry *AccessEntry) error {
+	associated := map[string]*eks.AccessScope{}
+	err := c.eks.ListAssociatedAccessPoliciesPages(&eks.ListAssociatedAccessPoliciesInput{
+		ClusterName:  &c.clusterName,
+		PrincipalArn: aws.String(entry.PrincipalArn),
+	}, func(page *eks.ListAssociatedAccessPoliciesOutput, lastPage bool) bool {
+		for _, p := range page.AssociatedAccessPolicies {
+			associated[aws.StringValue(p.PolicyArn)] = p.AccessScope
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	desired := map[string]bool{}
+	for _, policy := range entry.AccessPolicies {
+		desired[policy.PolicyArn] = true
+		scope := toAccessScope(policy)
+		if current, ok := associated[policy.PolicyArn]; ok && isSameAccessScope(current, scope) {
+			continue
+		}
+		// associating an already associated policy updates its access scope
+		_, err = c.eks.AssociateAccessPolicy(&eks.AssociateAccessPolicyInput{
+			ClusterName:  &c.clusterName,
+			PrincipalArn: aws.String(entry.PrincipalArn),
+			PolicyArn:    aws.String(policy.PolicyArn),
+			AccessScope:  scope,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for policyArn := range associated {
+		if desired[policyArn] {
+			continue
+		}
+		_, err = c.eks.DisassociateAccessPolicy(&eks.DisassociateAccessPolicyInput{
+			ClusterName:  &c.clusterName,
+			PrincipalArn: aws.String(entry.PrincipalArn),
+			PolicyArn:    aws.String(policyArn),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toAccessScope(policy v1alpha1.AccessPolicy) *eks.AccessScope {
+	scope := &eks.AccessScope{Type: aws.String(defaultAccessScopeType)}
+	if policy.AccessScopeType != "" {
+		scope.Type = aws.String(policy.AccessScopeType)
+	}
+	if aws.StringValue(scope.Type) == eks.AccessScopeTypeNamespace {
+		scope.Namespaces = aws.StringSlice(policy.Namespaces)
+	}
+	return scope
+}
+
+func isSameAccessScope(a *eks.AccessScope, b *eks.AccessScope) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return aws.StringValue(a.Type) == aws.StringValue(b.Type) &&
+		equality.Semantic.DeepEqual(sorted(aws.StringValueSlice(a.Namespaces)), sorted(aws.StringValueSlice(b.Namespaces)))
+}
+
+func sorted(list []string) []string {
+	out := append([]string{}, list...)
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,220 @@
+package eks
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/eks"
+	v1alpha1 "github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	clusterName = "dev"
+	owner       = "kube-system/aws-auth"
+	adminArn    = "arn:aws:iam::12345678:role/admin"
+	devArn      = "arn:aws:iam::12345678:role/dev"
+	nodeArn     = "arn:aws:iam::12345678:role/node"
+	removedArn  = "arn:aws:iam::12345678:role/removed"
+	viewPolicy  = "arn:aws:eks::aws:cluster-access-policy/AmazonEKSViewPolicy"
+	adminPolicy = "arn:aws:eks::aws:cluster-access-policy/AmazonEKSAdminPolicy"
+)
+
+func onListAccessEntries(eksClient *mocks.EKS, principalArns ...string) {
+	eksClient.On("ListAccessEntriesPages",
+		mock.MatchedBy(func(in *eks.ListAccessEntriesInput) bool {
+			return *in.ClusterName == clusterName
+		}),
+		mock.AnythingOfType("func(*eks.ListAccessEntriesOutput, bool) bool")).Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(1).(func(*eks.ListAccessEntriesOutput, bool) bool)
+		arg(&eks.ListAccessEntriesOutput{AccessEntries: aws.StringSlice(principalArns)}, true)
+	})
+}
+
+func onDescribeAccessEntry(eksClient *mocks.EKS, entry *eks.AccessEntry) {
+	eksClient.On("DescribeAccessEntry", &eks.DescribeAccessEntryInput{
+		ClusterName:  aws.String(clusterName),
+		PrincipalArn: entry.PrincipalArn,
+	}).Return(&eks.DescribeAccessEntryOutput{AccessEntry: entry}, nil)
+}
+
+func onListAssociatedAccessPolicies(eksClient *mocks.EKS, principalArn string, policies ...*eks.AssociatedAccessPolicy) {
+	eksClient.On("ListAssociatedAccessPoliciesPages",
+		mock.MatchedBy(func(in *eks.ListAssociatedAccessPoliciesInput) bool {
+			return *in.PrincipalArn == principalArn
+		}),
+		mock.AnythingOfType("func(*eks.ListAssociatedAccessPoliciesOutput, bool) bool")).Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(1).(func(*eks.ListAssociatedAccessPoliciesOutput, bool) bool)
+		arg(&eks.ListAssociatedAccessPoliciesOutput{AssociatedAccessPolicies: policies}, true)
+	})
+}
+
+func TestSync(t *testing.T) {
+	eksClient := &mocks.EKS{}
+	onListAccessEntries(eksClient, devArn, nodeArn, removedArn)
+	onDescribeAccessEntry(eksClient, &eks.AccessEntry{
+		PrincipalArn:     aws.String(devArn),
+		Username:         aws.String("dev"),
+		KubernetesGroups: aws.StringSlice([]string{"dev"}),
+		Tags:             map[string]*string{OwnerTagKey: aws.String(owner)},
+	})
+	onDescribeAccessEntry(eksClient, &eks.AccessEntry{
+		PrincipalArn: aws.String(nodeArn),
+		Username:     aws.String("system:node:{{EC2PrivateDNSName}}"),
+	})
+	onDescribeAccessEntry(eksClient, &eks.AccessEntry{
+		PrincipalArn: aws.String(removedArn),
+		Tags:         map[string]*string{OwnerTagKey: aws.String(owner)},
+	})
+
+	// new entry is created and its policies associated
+	eksClient.On("CreateAccessEntry", &eks.CreateAccessEntryInput{
+		ClusterName:      aws.String(clusterName),
+		PrincipalArn:     aws.String(adminArn),
+		Username:         aws.String("admin"),
+		KubernetesGroups: aws.StringSlice([]string{"admins"}),
+		Tags:             map[string]*string{OwnerTagKey: aws.String(owner)},
+	}).Return(&eks.CreateAccessEntryOutput{}, nil)
+	onListAssociatedAccessPolicies(eksClient, adminArn)
+	eksClient.On("AssociateAccessPolicy", &eks.AssociateAccessPolicyInput{
+		ClusterName:  aws.String(clusterName),
+		PrincipalArn: aws.String(adminArn),
+		PolicyArn:    aws.String(adminPolicy),
+		AccessScope:  &eks.AccessScope{Type: aws.String(eks.AccessScopeTypeCluster)},
+	}).Return(&eks.AssociateAccessPolicyOutput{}, nil)
+
+	// owned entry is updated and its policies synced
+	eksClient.On("UpdateAccessEntry", &eks.UpdateAccessEntryInput{
+		ClusterName:      aws.String(clusterName),
+		PrincipalArn:     aws.String(devArn),
+		Username:         aws.String("dev"),
+		KubernetesGroups: aws.StringSlice([]string{"view", "dev"}),
+	}).Return(&eks.UpdateAccessEntryOutput{}, nil)
+	onListAssociatedAccessPolicies(eksClient, devArn,
+		&eks.AssociatedAccessPolicy{PolicyArn: aws.String(adminPolicy), AccessScope: &eks.AccessScope{Type: aws.String(eks.AccessScopeTypeCluster)}},
+		&eks.AssociatedAccessPolicy{PolicyArn: aws.String(viewPolicy), AccessScope: &eks.AccessScope{Type: aws.String(eks.AccessScopeTypeNamespace), Namespaces: aws.StringSlice([]string{"dev"})}},
+	)
+	eksClient.On("DisassociateAccessPolicy", &eks.DisassociateAccessPolicyInput{
+		ClusterName:  aws.String(clusterName),
+		PrincipalArn: aws.String(devArn),
+		PolicyArn:    aws.String(adminPolicy),
+	}).Return(&eks.DisassociateAccessPolicyOutput{}, nil)
+
+	// owned entry that is no longer desired is deleted
+	eksClient.On("DeleteAccessEntry", &eks.DeleteAccessEntryInput{
+		ClusterName:  aws.String(clusterName),
+		PrincipalArn: aws.String(removedArn),
+	}).Return(&eks.DeleteAccessEntryOutput{}, nil)
+
+	client := New(eksClient, clusterName, owner)
+	conflicts, err := client.Sync([]*AccessEntry{
+		{
+			PrincipalArn:   adminArn,
+			Username:       "admin",
+			Groups:         []string{"admins"},
+			AccessPolicies: []v1alpha1.AccessPolicy{{PolicyArn: adminPolicy}},
+		},
+		{
+			PrincipalArn:   devArn,
+			Username:       "dev",
+			Groups:         []string{"view", "dev"},
+			AccessPolicies: []v1alpha1.AccessPolicy{{PolicyArn: viewPolicy, AccessScopeType: "namespace", Namespaces: []string{"dev"}}},
+		},
+		{
+			PrincipalArn: nodeArn,
+			Username:     "node",
+			Groups:       []string{"nodes"},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"access entry " + nodeArn + " conflicts with an unmanaged entry"}, conflicts)
+	eksClient.AssertExpectations(t)
+}
+
+func TestSyncSkipsRejectedEntries(t *testing.T) {
+	eksClient := mocks.NewEKS(t)
+	onListAccessEntries(eksClient)
+	// EKS rejects the first entry, the next ones are still created
+	eksClient.On("CreateAccessEntry", mock.MatchedBy(func(in *eks.CreateAccessEntryInput) bool {
+		return *in.PrincipalArn == devArn
+	})).Return(nil, awserr.New(eks.ErrCodeInvalidParameterException, "invalid username", nil)).Once()
+	eksClient.On("CreateAccessEntry", mock.MatchedBy(func(in *eks.CreateAccessEntryInput) bool {
+		return *in.PrincipalArn == adminArn
+	})).Return(&eks.CreateAccessEntryOutput{}, nil).Once()
+	onListAssociatedAccessPolicies(eksClient, adminArn)
+
+	client := New(eksClient, clusterName, owner)
+	conflicts, err := client.Sync([]*AccessEntry{
+		{PrincipalArn: devArn, Username: "dev:"},
+		{PrincipalArn: nodeArn, Username: "system:node:{{EC2PrivateDNSName}}", Groups: []string{"system:nodes"}},
+		{PrincipalArn: removedArn, Username: "{{SessionName}}"},
+		{PrincipalArn: adminArn, Username: "admin", Groups: []string{"admins"}},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"access entry " + devArn + " is skipped: InvalidParameterException: invalid username",
+		"access entry " + nodeArn + " is skipped: group system:nodes is reserved",
+		"access entry " + removedArn + " is skipped: username template {{SessionName}} is not supported",
+	}, conflicts)
+
+	// other errors fail the sync
+	eksClient.On("CreateAccessEntry", mock.Anything).Return(nil, awserr.New(eks.ErrCodeServerException, "internal error", nil)).Once()
+	_, err = client.Sync([]*AccessEntry{{PrincipalArn: devArn, Username: "dev"}})
+	assert.NotNil(t, err)
+}
+
+func TestDelete(t *testing.T) {
+	eksClient := &mocks.EKS{}
+	onListAccessEntries(eksClient, devArn, nodeArn)
+	onDescribeAccessEntry(eksClient, &eks.AccessEntry{
+		PrincipalArn: aws.String(devArn),
+		Tags:         map[string]*string{OwnerTagKey: aws.String(owner)},
+	})
+	onDescribeAccessEntry(eksClient, &eks.AccessEntry{
+		PrincipalArn: aws.String(nodeArn),
+		Tags:         map[string]*string{OwnerTagKey: aws.String("kube-system/other")},
+	})
+	eksClient.On("DeleteAccessEntry", &eks.DeleteAccessEntryInput{
+		ClusterName:  aws.String(clusterName),
+		PrincipalArn: aws.String(devArn),
+	}).Return(&eks.DeleteAccessEntryOutput{}, nil)
+
+	client := New(eksClient, clusterName, owner)
+	assert.Nil(t, client.Delete())
+	eksClient.AssertExpectations(t)
+}
+
+func TestIsSameAccessScope(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		a        *eks.AccessScope
+		policy   v1alpha1.AccessPolicy
+		expected bool
+	}{
+		{
+			desc:     "Default cluster scope",
+			a:        &eks.AccessScope{Type: aws.String(eks.AccessScopeTypeCluster)},
+			policy:   v1alpha1.AccessPolicy{PolicyArn: viewPolicy},
+			expected: true,
+		},
+		{
+			desc:     "Namespaces in a different order",
+			a:        &eks.AccessScope{Type: aws.String(eks.AccessScopeTypeNamespace), Namespaces: aws.StringSlice([]string{"b", "a"})},
+			policy:   v1alpha1.AccessPolicy{PolicyArn: viewPolicy, AccessScopeType: "namespace", Namespaces: []string{"a", "b"}},
+			expected: true,
+		},
+		{
+			desc:     "Scope type changed",
+			a:        &eks.AccessScope{Type: aws.String(eks.AccessScopeTypeCluster)},
+			policy:   v1alpha1.AccessPolicy{PolicyArn: viewPolicy, AccessScopeType: "namespace", Namespaces: []string{"a"}},
+			expected: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, isSameAccessScope(testCase.a, toAccessScope(testCase.policy)), testCase.desc)
+	}
+}
@@ -0,0 +1,134 @@
+package eks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx"
+)
+
+// PodIdentityOwnerTagKey is the tag key of the pod identity associations managed by identity-manager
+const PodIdentityOwnerTagKey = "identity-manager.io/workloadidentity"
+
+// PodIdentityAssociation is the desired pod identity association of a service account
+type PodIdentityAssociation struct {
+	Namespace      string
+	ServiceAccount string
+	RoleArn        string
+}
+
+// SyncPodIdentityAssociations creates or updates the desired pod identity associations
+// and deletes the previously managed ones (by ARN) which are no longer desired.
+// It returns the ARNs of the managed pod identity associations.
+func (c *Client) SyncPodIdentityAssociations(associations []*PodIdentityAssociation, managedArns []string) ([]string, error) {
+	arns := []string{}
+	desired := map[string]bool{}
+	for _, association := range associations {
+		associationArn, err := c.createOrUpdatePodIdentityAssociation(association)
+		if err != nil {
+			return nil, err
+		}
+		arns = append(arns, associationArn)
+		desired[associationArn] = true
+	}
+	for _, associationArn := range managedArns {
+		if desired[associationArn] {
+			continue
+		}
+		err := c.deletePodIdentityAssociation(associationArn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return arns, nil
+}
+
+// DeletePodIdentityAssociations deletes the managed pod identity associations (by ARN)
+func (c *Client) DeletePodIdentityAssociations(managedArns []string) error {
+	_, err := c.SyncPodIdentityAssociations(nil, managedArns)
+	return err
+}
+
+func (c *Client) createOrUpdatePodIdentityAssociation(association *PodIdentityAssociation) (string, error) {
+	summaries := []*eks.PodIdentityAssociationSummary{}
+	err := c.eks.ListPodIdentityAssociationsPages(&eks.ListPodIdentityAssociationsInput{
+		ClusterName:    &c.clusterName,
+		Namespace:      aws.String(association.Namespace),
+		ServiceAccount: aws.String(association.ServiceAccount),
+	}, func(page *eks.ListPodIdentityAssociationsOutput, lastPage bool) bool {
+		summaries = append(summaries, page.Associations...)
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(summaries) == 0 {
+		out, err := c.eks.CreatePodIdentityAssociation(&eks.CreatePodIdentityAssociationInput{
+			ClusterName:    &c.clusterName,
+			Namespace:      aws.String(association.Namespace),
+			ServiceAccount: aws.String(association.ServiceAccount),
+			RoleArn:        aws.String(association.RoleArn),
+			Tags:           map[string]*string{PodIdentityOwnerTagKey: aws.String(c.owner)},
+		})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(out.Association.AssociationArn), nil
+	}
+	// a service account has at most one association
+	out, err := c.eks.DescribePodIdentityAssociation(&eks.DescribePodIdentityAssociationInput{
+		ClusterName:   &c.clusterName,
+		AssociationId: summaries[0].AssociationId,
+	})
+	if err != nil {
+		return "", err
+	}
+	current := out.Association
+	if owner := aws.StringValue(current.Tags[PodIdentityOwnerTagKey]); owner != c.owner {
+		return "", fmt.Errorf("pod identity association of serviceaccount %s/%s is not managed by %s",
+			association.Namespace, association.ServiceAccount, c.owner)
+	}
+	if aws.StringValue(current.RoleArn) != association.RoleArn {
+		_, err = c.eks.UpdatePodIdentityAssociation(&eks.UpdatePodIdentityAssociationInput{
+			ClusterName:   &c.clusterName,
+			AssociationId: current.AssociationId,
+			RoleArn:       aws.String(association.RoleArn),
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return aws.StringValue(current.AssociationArn), nil
+}
+
+func (c *Client) deletePodIdentityAssociation(associationArn string) error {
+	clusterName, associationID, err := parsePodIdentityAssociationArn(associationArn)
+	if err != nil {
+		return err
+	}
+	_, err = c.eks.DeletePodIdentityAssociation(&eks.DeletePodIdentityAssociationInput{
+		ClusterName:   aws.String(clusterName),
+		AssociationId: aws.String(associationID),
+	})
+	if _, notFound := awsx.CheckError(err, eks.ErrCodeResourceNotFoundException); err != nil && !notFound {
+		return err
+	}
+	return nil
+}
+
+// parsePodIdentityAssociationArn returns the cluster name and the association id of
+// arn:aws:eks:<region>:<account>:podidentityassociation/<cluster>/<id>
+func parsePodIdentityAssociationArn(associationArn string) (string, string, error) {
+	parsed, err := arn.Parse(associationArn)
+	if err != nil {
+		return "", "", err
+	}
+	parts := strings.Split(parsed.Resource, "/")
+	if len(parts) != 3 || parts[0] != "podidentityassociation" {
+		return "", "", fmt.Errorf("invalid pod identity association arn: %s", associationArn)
+	}
+	return parts[1], parts[2], nil
+}
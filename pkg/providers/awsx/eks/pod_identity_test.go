@@ -0,0 +1,121 @@
+package eks
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	wiOwner        = "dev/ccs-v1"
+	roleArn        = "arn:aws:iam::12345678:role/ccs-v1"
+	associationArn = "arn:aws:eks:us-east-1:12345678:podidentityassociation/dev/a-1"
+)
+
+func onListPodIdentityAssociations(eksClient *mocks.EKS, namespace string, serviceAccount string, summaries ...*eks.PodIdentityAssociationSummary) {
+	eksClient.On("ListPodIdentityAssociationsPages",
+		mock.MatchedBy(func(in *eks.ListPodIdentityAssociationsInput) bool {
+			return *in.ClusterName == clusterName && *in.Namespace == namespace && *in.ServiceAccount == serviceAccount
+		}),
+		mock.AnythingOfType("func(*eks.ListPodIdentityAssociationsOutput, bool) bool")).Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(1).(func(*eks.ListPodIdentityAssociationsOutput, bool) bool)
+		arg(&eks.ListPodIdentityAssociationsOutput{Associations: summaries}, true)
+	})
+}
+
+func TestSyncPodIdentityAssociations(t *testing.T) {
+	testCases := []struct {
+		desc                  string
+		managedArns           []string
+		setupMockExpectations func(*mocks.EKS)
+		expectedArns          []string
+		expectedErr           string
+	}{
+		{
+			desc: "Create a new association",
+			setupMockExpectations: func(eksClient *mocks.EKS) {
+				onListPodIdentityAssociations(eksClient, "dev", "app")
+				eksClient.On("CreatePodIdentityAssociation", &eks.CreatePodIdentityAssociationInput{
+					ClusterName:    aws.String(clusterName),
+					Namespace:      aws.String("dev"),
+					ServiceAccount: aws.String("app"),
+					RoleArn:        aws.String(roleArn),
+					Tags:           map[string]*string{PodIdentityOwnerTagKey: aws.String(wiOwner)},
+				}).Return(&eks.CreatePodIdentityAssociationOutput{
+					Association: &eks.PodIdentityAssociation{AssociationArn: aws.String(associationArn)},
+				}, nil)
+			},
+			expectedArns: []string{associationArn},
+		},
+		{
+			desc:        "Update the role of a managed association and delete a stale one",
+			managedArns: []string{associationArn, "arn:aws:eks:us-east-1:12345678:podidentityassociation/old/a-2"},
+			setupMockExpectations: func(eksClient *mocks.EKS) {
+				onListPodIdentityAssociations(eksClient, "dev", "app", &eks.PodIdentityAssociationSummary{AssociationId: aws.String("a-1")})
+				eksClient.On("DescribePodIdentityAssociation", &eks.DescribePodIdentityAssociationInput{
+					ClusterName:   aws.String(clusterName),
+					AssociationId: aws.String("a-1"),
+				}).Return(&eks.DescribePodIdentityAssociationOutput{
+					Association: &eks.PodIdentityAssociation{
+						AssociationArn: aws.String(associationArn),
+						AssociationId:  aws.String("a-1"),
+						RoleArn:        aws.String("arn:aws:iam::12345678:role/old"),
+						Tags:           map[string]*string{PodIdentityOwnerTagKey: aws.String(wiOwner)},
+					},
+				}, nil)
+				eksClient.On("UpdatePodIdentityAssociation", &eks.UpdatePodIdentityAssociationInput{
+					ClusterName:   aws.String(clusterName),
+					AssociationId: aws.String("a-1"),
+					RoleArn:       aws.String(roleArn),
+				}).Return(&eks.UpdatePodIdentityAssociationOutput{}, nil)
+				eksClient.On("DeletePodIdentityAssociation", &eks.DeletePodIdentityAssociationInput{
+					ClusterName:   aws.String("old"),
+					AssociationId: aws.String("a-2"),
+				}).Return(nil, awserr.New(eks.ErrCodeResourceNotFoundException, "not found", nil))
+			},
+			expectedArns: []string{associationArn},
+		},
+		{
+			desc: "Association not managed by the owner",
+			setupMockExpectations: func(eksClient *mocks.EKS) {
+				onListPodIdentityAssociations(eksClient, "dev", "app", &eks.PodIdentityAssociationSummary{AssociationId: aws.String("a-1")})
+				eksClient.On("DescribePodIdentityAssociation", mock.Anything).Return(&eks.DescribePodIdentityAssociationOutput{
+					Association: &eks.PodIdentityAssociation{AssociationArn: aws.String(associationArn), RoleArn: aws.String(roleArn)},
+				}, nil)
+			},
+			expectedErr: "pod identity association of serviceaccount dev/app is not managed by dev/ccs-v1",
+		},
+	}
+
+	for _, testCase := range testCases {
+		eksClient := &mocks.EKS{}
+		testCase.setupMockExpectations(eksClient)
+
+		client := New(eksClient, clusterName, wiOwner)
+		arns, err := client.SyncPodIdentityAssociations([]*PodIdentityAssociation{
+			{Namespace: "dev", ServiceAccount: "app", RoleArn: roleArn},
+		}, testCase.managedArns)
+		if testCase.expectedErr != "" {
+			assert.EqualError(t, err, testCase.expectedErr, testCase.desc)
+			continue
+		}
+		assert.Nil(t, err, testCase.desc)
+		assert.Equal(t, testCase.expectedArns, arns, testCase.desc)
+		eksClient.AssertExpectations(t)
+	}
+}
+
+func TestParsePodIdentityAssociationArn(t *testing.T) {
+	clusterName, associationID, err := parsePodIdentityAssociationArn(associationArn)
+	assert.Nil(t, err)
+	assert.Equal(t, "dev", clusterName)
+	assert.Equal(t, "a-1", associationID)
+
+	_, _, err = parsePodIdentityAssociationArn("arn:aws:iam::12345678:role/ccs-v1")
+	assert.NotNil(t, err)
+}
@@ -10,10 +10,12 @@ import (
 	"strings"
 
 	v1alpha1 "github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+	"github.com/invisibl-cloud/identity-manager/pkg/drift"
 	"github.com/invisibl-cloud/identity-manager/pkg/options"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
 	"github.com/invisibl-cloud/identity-manager/pkg/util"
-	"k8s.io/apimachinery/pkg/api/equality"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
@@ -34,6 +36,17 @@ type Client struct {
 	role      *v1alpha1.WorkloadIdentity
 	options   *options.Options
 	accountID string
+	// oidcProvider is the arn or issuer url of the cluster's OIDC provider
+	oidcProvider string
+	// documents are the policy documents read from ConfigMaps and Secrets
+	documents *PolicyDocuments
+	// region and clusterName are used to render the policy templates
+	region      string
+	clusterName string
+	// drift records the changes made outside of the controller, which sync reverts
+	drift *drift.Report
+	// adopt allows to manage an existing role, which was not created by the controller
+	adopt bool
 }
 
 // New expects wrapped iam client, wrapped sts client, workload identity and
@@ -53,40 +66,68 @@ func New(iamClient awsx.IAM, stsClient awsx.STS, role *v1alpha1.WorkloadIdentity
 	}, nil
 }
 
-func (i *Client) internalRoleName() string {
-	return i.role.GetNamespace() + "-" + i.role.GetName()
-}
+// MaxRoleNameLength is the max length of an IAM role name
+const MaxRoleNameLength = 64
 
 func (i *Client) roleName() string {
-	roleName := i.role.Spec.Name
+	return TruncatedRoleName(i.role, i.options)
+}
+
+// TruncatedRoleName returns the IAM role name of a WorkloadIdentity as created by the controller,
+// truncated to MaxRoleNameLength
+func TruncatedRoleName(role *v1alpha1.WorkloadIdentity, options *options.Options) string {
+	roleName := RoleName(role, options)
+	// max 64 char
+	if len(roleName) > MaxRoleNameLength {
+		return roleName[:MaxRoleNameLength]
+	}
+	return roleName
+}
+
+// RoleName returns the untruncated IAM role name of a WorkloadIdentity, with the global prefix if any
+func RoleName(role *v1alpha1.WorkloadIdentity, options *options.Options) string {
+	roleName := role.Spec.Name
 	if roleName == "" {
-		roleName = i.internalRoleName()
+		roleName = role.GetNamespace() + "-" + role.GetName()
 	}
 	// global prefix
-	if i.options != nil && i.options.NamePrefix != "" {
-		if !strings.HasPrefix(roleName, i.options.NamePrefix) {
-			roleName = i.options.NamePrefix + roleName
+	if options != nil && options.NamePrefix != "" {
+		if !strings.HasPrefix(roleName, options.NamePrefix) {
+			roleName = options.NamePrefix + roleName
 		}
 	}
-	// max 64 char
-	if len(roleName) > 64 {
-		return roleName[:64]
-	}
 	return roleName
 }
 
-// CreateOrUpdate creates or updates the IAM roles
+// WithOIDCProvider sets the OIDC provider (arn or issuer url) used to generate the assume role policy
+func (i *Client) WithOIDCProvider(oidcProvider string) *Client {
+	i.oidcProvider = oidcProvider
+	return i
+}
+
+// WithDrift sets the report of the drift reverted by sync, nil when the drift is not reported
+func (i *Client) WithDrift(report *drift.Report) *Client {
+	i.drift = report
+	return i
+}
+
+// WithAdopt allows to manage an existing role of the same name, which was not created by the controller
+func (i *Client) WithAdopt(adopt bool) *Client {
+	i.adopt = adopt
+	return i
+}
+
+// CreateOrUpdate creates or updates the IAM roles, the policies are expected to be validated by ValidatePolicies
 func (i *Client) CreateOrUpdate(ctx context.Context) (*RoleStatus, error) {
-	prevRoleName := i.role.Status.Name
-	newRoleName := i.roleName()
-	// delete old role if name got changed
-	if prevRoleName != "" && prevRoleName != newRoleName {
-		if i.isExists(prevRoleName) {
-			err := i.delete(prevRoleName)
-			if err != nil {
-				return nil, err
-			}
-		}
+	if i.assumeRolePolicy() == "" {
+		return nil, fmt.Errorf("missing assumeRolePolicy: set it, enable podIdentity or configure an oidc provider for the serviceAccounts")
+	}
+	// imported roles keep the name of their arn
+	newRoleName := i.observedRoleName()
+	// roles are never renamed, the existing role is kept
+	err := types.CheckRename(i.role.Status.Name, newRoleName)
+	if err != nil {
+		return nil, err
 	}
 	status, err := i.createOrSync(newRoleName)
 	if err != nil {
@@ -97,7 +138,7 @@ func (i *Client) CreateOrUpdate(ctx context.Context) (*RoleStatus, error) {
 
 // Delete will be called by Finalizer that will delete the IAM role
 func (i *Client) Delete(ctx context.Context) error {
-	roleName := i.role.Status.Name
+	roleName := i.currentRoleName()
 	if roleName != "" && i.isExists(roleName) {
 		err := i.delete(roleName)
 		if err != nil {
@@ -109,12 +150,24 @@ func (i *Client) Delete(ctx context.Context) error {
 
 func (i *Client) createOrSync(roleName string) (*RoleStatus, error) {
 	if i.isExists(roleName) {
+		// roles which were not created by the controller are only managed once adopted
+		err := types.CheckExists(i.role.Status.Name, roleName, i.adopt)
+		if err != nil {
+			return nil, err
+		}
 		status, err := i.sync(roleName)
 		if err != nil {
 			return nil, err
 		}
 		return status, nil
 	}
+	// imported roles are managed but never created
+	if importID := i.role.GetAnnotations()[consts.ImportKey]; importID != "" {
+		return nil, types.Errorf(types.ErrorDependencyMissing, "imported IAM role %s not found", importID)
+	}
+	if i.role.Status.Name != "" {
+		i.drift.Add(drift.TypeRole, roleName, v1alpha1.DriftChangeRemoved)
+	}
 	status, err := i.create(roleName)
 	if err != nil {
 		return nil, err
@@ -122,13 +175,7 @@ func (i *Client) createOrSync(roleName string) (*RoleStatus, error) {
 	return status, nil
 }
 
-func (i *Client) getTags(awsRole *iam.Role) ([]*iam.Tag, bool) {
-	existingTags := map[string]string{}
-	if awsRole != nil {
-		for _, t := range awsRole.Tags {
-			existingTags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
-		}
-	}
+func (i *Client) getTags() []*iam.Tag {
 	tags := []*iam.Tag{}
 	tmap := map[string]string{}
 	tkeys := []string{}
@@ -148,10 +195,7 @@ func (i *Client) getTags(awsRole *iam.Role) ([]*iam.Tag, bool) {
 		}
 	}
 	if len(tkeys) == 0 {
-		if len(existingTags) == 0 {
-			return nil, false
-		}
-		return nil, true
+		return nil
 	}
 	sort.Strings(tkeys)
 	for _, k := range tkeys {
@@ -160,7 +204,7 @@ func (i *Client) getTags(awsRole *iam.Role) ([]*iam.Tag, bool) {
 			Value: aws.String(tmap[k]),
 		})
 	}
-	return tags, equality.Semantic.DeepEqual(existingTags, tmap)
+	return tags
 }
 
 // Create creates an IAM role in AWS, based on a spec
@@ -174,7 +218,7 @@ func (i *Client) create(roleName string) (*RoleStatus, error) {
 			input.PermissionsBoundary = aws.String(i.options.AWS.PermissionsBoundaryARN)
 		}
 	}
-	input.AssumeRolePolicyDocument = &i.role.Spec.AWS.AssumeRolePolicy // required
+	input.AssumeRolePolicyDocument = aws.String(i.assumeRolePolicy()) // required
 	if i.role.Spec.Description != "" {
 		input.Description = &i.role.Spec.Description
 	}
@@ -184,11 +228,15 @@ func (i *Client) create(roleName string) (*RoleStatus, error) {
 	if i.role.Spec.AWS.MaxSessionDuration > 0 {
 		input.MaxSessionDuration = &i.role.Spec.AWS.MaxSessionDuration
 	}
-	input.Tags, _ = i.getTags(nil)
+	input.Tags = i.getTags()
 	createRoleOutput, err := i.iam.CreateRole(input)
 	if err != nil {
 		return nil, err
 	}
+	// the role is recorded as soon as it is created, so that it is not mistaken on retry
+	// for an existing role when one of the next steps fails
+	i.role.Status.Name = aws.StringValue(createRoleOutput.Role.RoleName)
+	i.role.Status.ID = aws.StringValue(createRoleOutput.Role.Arn)
 	err = i.createInlinePolicies()
 	if err != nil {
 		return nil, err
@@ -197,9 +245,31 @@ func (i *Client) create(roleName string) (*RoleStatus, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = i.attachManagedPolicies(roleName)
+	if err != nil {
+		return nil, err
+	}
 	return &RoleStatus{Name: *createRoleOutput.Role.RoleName, ARN: *createRoleOutput.Role.Arn}, nil
 }
 
+// attachManagedPolicies creates the managed policies found in the spec and attaches them to a named IAM role
+func (i *Client) attachManagedPolicies(roleName string) error {
+	if len(i.role.Spec.AWS.ManagedPolicies) == 0 {
+		return nil
+	}
+	managedPolicyArns, _, err := i.syncManagedPolicies(roleName)
+	if err != nil {
+		return err
+	}
+	for _, policyArn := range managedPolicyArns {
+		err = i.attachPolicy(roleName, policyArn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Delete deletes an IAM role
 func (i *Client) delete(roleName string) error {
 	currentPolicies, err := i.listInlinePolicies(roleName)
@@ -229,6 +299,17 @@ func (i *Client) delete(roleName string) error {
 			return err
 		}
 	}
+	// delete the managed policies, now that they are detached
+	managedPolicies, err := i.listManagedPolicies(roleName)
+	if err != nil {
+		return err
+	}
+	for _, policy := range managedPolicies {
+		err = i.deleteManagedPolicy(aws.StringValue(policy.Arn))
+		if err != nil {
+			return err
+		}
+	}
 	_, err = i.iam.DeleteRole(&iam.DeleteRoleInput{
 		RoleName: &roleName,
 	})
@@ -252,11 +333,12 @@ func (i *Client) sync(roleName string) (*RoleStatus, error) {
 		return nil, err
 	}
 	existingAssumeRolePolicy = toCompactJSON(existingAssumeRolePolicy)
-	currentAssumeRolePolicy := toCompactJSON(i.role.Spec.AWS.AssumeRolePolicy)
+	currentAssumeRolePolicy := toCompactJSON(i.assumeRolePolicy())
 	if existingAssumeRolePolicy != currentAssumeRolePolicy {
+		i.drift.Add(drift.TypeAssumeRolePolicy, roleName, v1alpha1.DriftChangeModified)
 		_, err = i.iam.UpdateAssumeRolePolicy(&iam.UpdateAssumeRolePolicyInput{
 			RoleName:       &roleName,
-			PolicyDocument: &i.role.Spec.AWS.AssumeRolePolicy,
+			PolicyDocument: aws.String(i.assumeRolePolicy()),
 		})
 		if err != nil {
 			return nil, err
@@ -269,14 +351,29 @@ func (i *Client) sync(roleName string) (*RoleStatus, error) {
 		return nil, err
 	}
 
+	// sync managed policies
+	managedPolicyArns, stalePolicyArns, err := i.syncManagedPolicies(roleName)
+	if err != nil {
+		return nil, err
+	}
+
 	// sync policy arns
-	err = i.syncPolicyArns(roleName)
+	err = i.syncPolicyArns(roleName, managedPolicyArns)
 	if err != nil {
 		return nil, err
 	}
 
+	// delete stale managed policies, now that they are detached
+	for _, policyArn := range stalePolicyArns {
+		err = i.deleteManagedPolicy(policyArn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// sync max-session duration.
 	if i.role.Spec.AWS.MaxSessionDuration > 0 && aws.Int64Value(awsRole.MaxSessionDuration) != i.role.Spec.AWS.MaxSessionDuration {
+		i.drift.Add(drift.TypeMaxSessionDuration, roleName, v1alpha1.DriftChangeModified)
 		_, err = i.iam.UpdateRole(&iam.UpdateRoleInput{
 			RoleName:           &roleName,
 			MaxSessionDuration: &i.role.Spec.AWS.MaxSessionDuration,
@@ -288,6 +385,7 @@ func (i *Client) sync(roleName string) (*RoleStatus, error) {
 
 	// sync description
 	if i.role.Spec.Description != "" && aws.StringValue(awsRole.Description) != i.role.Spec.Description {
+		i.drift.Add(drift.TypeDescription, roleName, v1alpha1.DriftChangeModified)
 		_, err = i.iam.UpdateRoleDescription(&iam.UpdateRoleDescriptionInput{
 			Description: &i.role.Spec.Description,
 			RoleName:    &roleName,
@@ -312,9 +410,26 @@ func (i *Client) sync(roleName string) (*RoleStatus, error) {
 	return &RoleStatus{Name: roleName, ARN: aws.StringValue(awsRole.Arn)}, nil
 }
 
+// syncTags sets the tags which are missing or have another value, the other tags of the role are kept
 func (i *Client) syncTags(awsRole *iam.Role, roleName string) error {
-	tags, needsUpdate := i.getTags(awsRole)
-	if !needsUpdate {
+	existingTags := map[string]string{}
+	for _, t := range awsRole.Tags {
+		existingTags[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	tags := []*iam.Tag{}
+	for _, t := range i.getTags() {
+		value, ok := existingTags[aws.StringValue(t.Key)]
+		if ok && value == aws.StringValue(t.Value) {
+			continue
+		}
+		if ok {
+			i.drift.Add(drift.TypeTag, aws.StringValue(t.Key), v1alpha1.DriftChangeModified)
+		} else {
+			i.drift.Add(drift.TypeTag, aws.StringValue(t.Key), v1alpha1.DriftChangeRemoved)
+		}
+		tags = append(tags, t)
+	}
+	if len(tags) == 0 {
 		return nil
 	}
 	_, err := i.iam.TagRole(&iam.TagRoleInput{
@@ -344,6 +459,11 @@ func (i *Client) syncPermissionsBoundary(awsRole *iam.Role, roleName string) err
 		if !needsUpdate {
 			return nil
 		}
+		if existingPermissionBoundary == nil {
+			i.drift.Add(drift.TypePermissionsBoundary, roleName, v1alpha1.DriftChangeRemoved)
+		} else {
+			i.drift.Add(drift.TypePermissionsBoundary, roleName, v1alpha1.DriftChangeModified)
+		}
 		_, err := i.iam.PutRolePermissionsBoundary(&iam.PutRolePermissionsBoundaryInput{
 			RoleName:            &roleName,
 			PermissionsBoundary: &permissionsBoundaryARN,
@@ -357,6 +477,7 @@ func (i *Client) syncPermissionsBoundary(awsRole *iam.Role, roleName string) err
 	// if current permissions boundary is not present
 	// but existing role has permission boundary - so delete it.
 	if existingPermissionBoundary != nil {
+		i.drift.Add(drift.TypePermissionsBoundary, roleName, v1alpha1.DriftChangeAdded)
 		_, err := i.iam.DeleteRolePermissionsBoundary(&iam.DeleteRolePermissionsBoundaryInput{
 			RoleName: &roleName,
 		})
@@ -372,15 +493,18 @@ func (i *Client) syncInlinePolicies(roleName string) error {
 	if err != nil {
 		return err
 	}
-	inlinePolicyNames, inlinePolicyNameMapping := toInlinePolicyNames(i.role.Spec.AWS.InlinePolicies)
+	inlinePolicies := i.inlinePolicies()
+	inlinePolicyNames, inlinePolicyNameMapping := toInlinePolicyNames(inlinePolicies)
 	syncSteps := util.FindSyncSteps(existingInlinePolicyNames, inlinePolicyNames)
 	for _, policyName := range syncSteps.Add {
-		err = i.createInlinePolicy(roleName, policyName, i.role.Spec.AWS.InlinePolicies[inlinePolicyNameMapping[policyName]])
+		i.drift.Add(drift.TypeInlinePolicy, inlinePolicyNameMapping[policyName], v1alpha1.DriftChangeRemoved)
+		err = i.createInlinePolicy(roleName, policyName, inlinePolicies[inlinePolicyNameMapping[policyName]])
 		if err != nil {
 			return err
 		}
 	}
 	for _, policyName := range syncSteps.Delete {
+		i.drift.Add(drift.TypeInlinePolicy, policyName, v1alpha1.DriftChangeAdded)
 		err = i.deleteInlinePolicy(roleName, policyName)
 		if err != nil {
 			return err
@@ -389,19 +513,22 @@ func (i *Client) syncInlinePolicies(roleName string) error {
 	return nil
 }
 
-func (i *Client) syncPolicyArns(roleName string) error {
+func (i *Client) syncPolicyArns(roleName string, managedPolicyArns []string) error {
 	attachedPolicies, err := i.listAttachedPolicies(roleName)
 	if err != nil {
 		return err
 	}
-	syncSteps := util.FindSyncSteps(toArns(attachedPolicies), i.toArns(i.role.Spec.AWS.Policies))
+	desired := append(i.toArns(i.role.Spec.AWS.Policies), managedPolicyArns...)
+	syncSteps := util.FindSyncSteps(toArns(attachedPolicies), desired)
 	for _, policyArn := range syncSteps.Add {
+		i.drift.Add(drift.TypePolicy, policyArn, v1alpha1.DriftChangeRemoved)
 		err = i.attachPolicy(roleName, policyArn)
 		if err != nil {
 			return err
 		}
 	}
 	for _, policyArn := range syncSteps.Delete {
+		i.drift.Add(drift.TypePolicy, policyArn, v1alpha1.DriftChangeAdded)
 		err = i.detachPolicy(roleName, policyArn)
 		if err != nil {
 			return err
@@ -506,12 +633,15 @@ func (i *Client) detachPolicy(roleName string, policyArn string) error {
 
 // Creates inline polices defined in a spec and attaches it to a role
 func (i *Client) createInlinePolicies() error {
-	if i.role.Spec.AWS.InlinePolicies == nil || len(i.role.Spec.AWS.InlinePolicies) == 0 {
+	inlinePolicies := i.inlinePolicies()
+	if len(inlinePolicies) == 0 {
 		return nil
 	}
 	roleName := i.roleName()
-	for policyName, policy := range i.role.Spec.AWS.InlinePolicies {
-		err := i.createInlinePolicy(roleName, policyName, policy)
+	// named as synced, with the hash of their document
+	policyNames, policyNameMapping := toInlinePolicyNames(inlinePolicies)
+	for _, policyName := range policyNames {
+		err := i.createInlinePolicy(roleName, policyName, inlinePolicies[policyNameMapping[policyName]])
 		if err != nil {
 			return err
 		}
@@ -10,9 +10,13 @@ import (
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/sts"
 	v1alpha1 "github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+	"github.com/invisibl-cloud/identity-manager/pkg/drift"
 	"github.com/invisibl-cloud/identity-manager/pkg/options"
+	"github.com/invisibl-cloud/identity-manager/pkg/plan"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/mocks"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -75,6 +79,98 @@ func TestToInlinePolicyNames(t *testing.T) {
 	assert.Equal(t, expectedPolicies, m)
 }
 
+func TestAssumeRolePolicy(t *testing.T) {
+	serviceAccounts := []*v1alpha1.ServiceAccount{{Name: "app"}, {Name: "api", Namespace: "prod"}}
+	webIdentityPolicy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Federated":"arn:aws:iam::12345678:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/ABCD"},"Action":["sts:AssumeRoleWithWebIdentity"],"Condition":{"StringEquals":{"oidc.eks.us-east-1.amazonaws.com/id/ABCD:aud":"sts.amazonaws.com","oidc.eks.us-east-1.amazonaws.com/id/ABCD:sub":["system:serviceaccount:dev:app","system:serviceaccount:prod:api"]}}}]}`
+	testCases := []struct {
+		desc         string
+		spec         *v1alpha1.WorkloadIdentityAWS
+		oidcProvider string
+		expected     string
+	}{
+		{
+			desc:         "Assume role policy of the spec",
+			spec:         &v1alpha1.WorkloadIdentityAWS{AssumeRolePolicy: `{"Version":"2012-10-17"}`, PodIdentity: &v1alpha1.AWSPodIdentity{ClusterName: "dev"}},
+			oidcProvider: "https://oidc.eks.us-east-1.amazonaws.com/id/ABCD",
+			expected:     `{"Version":"2012-10-17"}`,
+		},
+		{
+			desc:     "Generated pod identity trust policy",
+			spec:     &v1alpha1.WorkloadIdentityAWS{PodIdentity: &v1alpha1.AWSPodIdentity{ClusterName: "dev"}, ServiceAccounts: serviceAccounts},
+			expected: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"pods.eks.amazonaws.com"},"Action":["sts:AssumeRole","sts:TagSession"]}]}`,
+		},
+		{
+			desc:         "Generated web identity trust policy from the issuer url",
+			spec:         &v1alpha1.WorkloadIdentityAWS{ServiceAccounts: serviceAccounts},
+			oidcProvider: "https://oidc.eks.us-east-1.amazonaws.com/id/ABCD",
+			expected:     webIdentityPolicy,
+		},
+		{
+			desc:         "Generated web identity trust policy from the provider arn",
+			spec:         &v1alpha1.WorkloadIdentityAWS{ServiceAccounts: serviceAccounts},
+			oidcProvider: "arn:aws:iam::12345678:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/ABCD",
+			expected:     webIdentityPolicy,
+		},
+		{
+			desc:         "Missing service accounts",
+			spec:         &v1alpha1.WorkloadIdentityAWS{},
+			oidcProvider: "https://oidc.eks.us-east-1.amazonaws.com/id/ABCD",
+			expected:     "",
+		},
+		{
+			desc:     "Missing assume role policy",
+			spec:     &v1alpha1.WorkloadIdentityAWS{ServiceAccounts: serviceAccounts},
+			expected: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		client := &Client{
+			role: &v1alpha1.WorkloadIdentity{
+				ObjectMeta: v1.ObjectMeta{Name: "app", Namespace: "dev"},
+				Spec:       v1alpha1.WorkloadIdentitySpec{AWS: testCase.spec},
+			},
+			accountID: "12345678",
+		}
+		client.WithOIDCProvider(testCase.oidcProvider)
+		assert.Equal(t, testCase.expected, client.assumeRolePolicy(), testCase.desc)
+	}
+}
+
+func TestIsAssumable(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		document string
+		expected bool
+	}{
+		{
+			desc:     "web identity",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Federated":"arn:aws:iam::12345678:oidc-provider/oidc"},"Action":"sts:AssumeRoleWithWebIdentity"}]}`,
+			expected: true,
+		},
+		{
+			desc:     "pod identity with a single statement",
+			document: `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":{"Service":"pods.eks.amazonaws.com"},"Action":["sts:AssumeRole","sts:TagSession"]}}`,
+			expected: true,
+		},
+		{
+			desc:     "denied",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":"*","Action":"sts:AssumeRole"}]}`,
+		},
+		{
+			desc:     "no assume role action",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"sts:TagSession"}]}`,
+		},
+		{
+			desc:     "invalid",
+			document: `{`,
+		},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, isAssumable(testCase.document), testCase.desc)
+	}
+}
+
 func TestIsArn(t *testing.T) {
 	testCases := []struct {
 		policy         string
@@ -354,6 +450,12 @@ func TestCreateOrUpdate(t *testing.T) {
 						return *in.RoleName == "ccs-v1"
 					}),
 					mock.AnythingOfType("func(*iam.ListAttachedRolePoliciesOutput, bool) bool")).Return(nil)
+
+				iamClient.On("ListPoliciesPages",
+					mock.MatchedBy(func(in *iam.ListPoliciesInput) bool {
+						return *in.PathPrefix == "/identity-manager/ccs-v1/"
+					}),
+					mock.AnythingOfType("func(*iam.ListPoliciesOutput, bool) bool")).Return(nil)
 			},
 			expectedRoleStatus: &RoleStatus{
 				Name: "ccs-v1",
@@ -378,6 +480,394 @@ func TestCreateOrUpdate(t *testing.T) {
 	}
 }
 
+func TestCreateOrUpdateRename(t *testing.T) {
+	iamClient := &mocks.IAM{}
+	stsClient := &mocks.STS{}
+	stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("12345678"),
+	}, nil)
+	role := &v1alpha1.WorkloadIdentity{
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Name:     "ccs-v2",
+			Provider: v1alpha1.ProviderAWS,
+			AWS: &v1alpha1.WorkloadIdentityAWS{
+				AssumeRolePolicy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`,
+			},
+		},
+		Status: v1alpha1.WorkloadIdentityStatus{
+			Name: "ccs-v1",
+			ID:   "arn:aws:iam::12345678:role/ccs-v1",
+		},
+	}
+	client, err := New(iamClient, stsClient, role, &options.Options{AWS: &awsx.Options{}})
+	assert.Nil(t, err)
+
+	// the existing role is neither deleted nor replaced
+	_, err = client.CreateOrUpdate(context.Background())
+	assert.ErrorIs(t, err, types.ErrRename)
+	iamClient.AssertNotCalled(t, "DeleteRole", mock.Anything)
+	iamClient.AssertNotCalled(t, "CreateRole", mock.Anything)
+}
+
+func TestCreateOrUpdateAdopt(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		adopt       bool
+		expectedErr error
+	}{
+		{
+			desc:        "An existing role is not managed",
+			expectedErr: types.ErrExists,
+		},
+		{
+			desc:  "An existing role is adopted and synced",
+			adopt: true,
+		},
+	}
+	for _, testCase := range testCases {
+		iamClient := &mocks.IAM{}
+		stsClient := &mocks.STS{}
+		stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+			Account: aws.String("12345678"),
+		}, nil)
+		iamClient.On("GetRole", &iam.GetRoleInput{RoleName: aws.String("ccs-v1")}).Return(&iam.GetRoleOutput{
+			Role: &iam.Role{
+				Arn:                      aws.String("arn:aws:iam::12345678:role/ccs-v1"),
+				AssumeRolePolicyDocument: aws.String(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`),
+			},
+		}, nil)
+		iamClient.On("ListRolePoliciesPages", mock.Anything, mock.Anything).Return(nil)
+		iamClient.On("ListPoliciesPages", mock.Anything, mock.Anything).Return(nil)
+		iamClient.On("ListAttachedRolePoliciesPages", mock.Anything, mock.Anything).Return(nil)
+		role := &v1alpha1.WorkloadIdentity{
+			Spec: v1alpha1.WorkloadIdentitySpec{
+				Name:     "ccs-v1",
+				Provider: v1alpha1.ProviderAWS,
+				AWS: &v1alpha1.WorkloadIdentityAWS{
+					AssumeRolePolicy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`,
+				},
+			},
+		}
+		client, err := New(iamClient, stsClient, role, &options.Options{AWS: &awsx.Options{}})
+		assert.Nil(t, err)
+
+		status, err := client.WithAdopt(testCase.adopt).CreateOrUpdate(context.Background())
+		if testCase.expectedErr != nil {
+			assert.ErrorIs(t, err, testCase.expectedErr, testCase.desc)
+			iamClient.AssertNotCalled(t, "ListRolePoliciesPages", mock.Anything, mock.Anything)
+			continue
+		}
+		assert.Nil(t, err, testCase.desc)
+		assert.Equal(t, &RoleStatus{Name: "ccs-v1", ARN: "arn:aws:iam::12345678:role/ccs-v1"}, status, testCase.desc)
+	}
+}
+
+func TestCreateOrUpdatePartialCreate(t *testing.T) {
+	iamClient := &mocks.IAM{}
+	stsClient := &mocks.STS{}
+	stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("12345678"),
+	}, nil)
+	assumeRolePolicy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+	// the role is created, but attaching its policy fails
+	iamClient.On("GetRole", &iam.GetRoleInput{RoleName: aws.String("ccs-v1")}).Return(nil, errors.New("role not found in AWS")).Once()
+	iamClient.On("CreateRole", mock.Anything).Return(&iam.CreateRoleOutput{
+		Role: &iam.Role{
+			Arn:      aws.String("arn:aws:iam::12345678:role/ccs-v1"),
+			RoleName: aws.String("ccs-v1"),
+		},
+	}, nil).Once()
+	iamClient.On("AttachRolePolicy", mock.Anything).Return(nil, errors.New("throttled")).Once()
+	role := &v1alpha1.WorkloadIdentity{
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Name:     "ccs-v1",
+			Provider: v1alpha1.ProviderAWS,
+			AWS: &v1alpha1.WorkloadIdentityAWS{
+				AssumeRolePolicy: assumeRolePolicy,
+				Policies:         []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			},
+		},
+	}
+	client, err := New(iamClient, stsClient, role, &options.Options{AWS: &awsx.Options{}})
+	assert.Nil(t, err)
+
+	_, err = client.CreateOrUpdate(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, "ccs-v1", role.Status.Name)
+	assert.Equal(t, "arn:aws:iam::12345678:role/ccs-v1", role.Status.ID)
+
+	// the retry syncs the role it created instead of asking to adopt it
+	iamClient.On("GetRole", &iam.GetRoleInput{RoleName: aws.String("ccs-v1")}).Return(&iam.GetRoleOutput{
+		Role: &iam.Role{
+			Arn:                      aws.String("arn:aws:iam::12345678:role/ccs-v1"),
+			AssumeRolePolicyDocument: aws.String(assumeRolePolicy),
+		},
+	}, nil)
+	iamClient.On("ListRolePoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("ListPoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("ListAttachedRolePoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("AttachRolePolicy", mock.Anything).Return(nil, nil).Once()
+	status, err := client.CreateOrUpdate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, &RoleStatus{Name: "ccs-v1", ARN: "arn:aws:iam::12345678:role/ccs-v1"}, status)
+	iamClient.AssertNumberOfCalls(t, "CreateRole", 1)
+	iamClient.AssertExpectations(t)
+}
+
+func TestCreateOrUpdateImport(t *testing.T) {
+	iamClient := &mocks.IAM{}
+	stsClient := &mocks.STS{}
+	stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("12345678"),
+	}, nil)
+	assumeRolePolicy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+	// the imported role is synced under the name of its arn, not the one of the spec
+	iamClient.On("GetRole", &iam.GetRoleInput{RoleName: aws.String("existing")}).Return(&iam.GetRoleOutput{
+		Role: &iam.Role{
+			Arn:                      aws.String("arn:aws:iam::12345678:role/existing"),
+			RoleName:                 aws.String("existing"),
+			AssumeRolePolicyDocument: aws.String(assumeRolePolicy),
+		},
+	}, nil)
+	iamClient.On("ListRolePoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("ListPoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("ListAttachedRolePoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("AttachRolePolicy", &iam.AttachRolePolicyInput{
+		PolicyArn: aws.String("arn:aws:iam::aws:policy/ReadOnlyAccess"),
+		RoleName:  aws.String("existing"),
+	}).Return(nil, nil).Once()
+	role := &v1alpha1.WorkloadIdentity{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{consts.ImportKey: "arn:aws:iam::12345678:role/existing"},
+		},
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Name:     "ccs-v1",
+			Provider: v1alpha1.ProviderAWS,
+			AWS: &v1alpha1.WorkloadIdentityAWS{
+				AssumeRolePolicy: assumeRolePolicy,
+				Policies:         []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			},
+		},
+	}
+	client, err := New(iamClient, stsClient, role, &options.Options{AWS: &awsx.Options{}})
+	assert.Nil(t, err)
+
+	// the reconciler only manages imported roles which are also adopted
+	status, err := client.WithAdopt(true).CreateOrUpdate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, &RoleStatus{Name: "existing", ARN: "arn:aws:iam::12345678:role/existing"}, status)
+	iamClient.AssertNotCalled(t, "CreateRole", mock.Anything)
+	iamClient.AssertExpectations(t)
+
+	// imported roles are never created
+	role.Annotations[consts.ImportKey] = "arn:aws:iam::12345678:role/missing"
+	iamClient.On("GetRole", &iam.GetRoleInput{RoleName: aws.String("missing")}).Return(nil, errors.New("role not found in AWS"))
+	_, err = client.CreateOrUpdate(context.Background())
+	assert.Equal(t, types.ErrorDependencyMissing, types.Category(err))
+	iamClient.AssertNotCalled(t, "CreateRole", mock.Anything)
+}
+
+func TestSyncDrift(t *testing.T) {
+	iamClient := &mocks.IAM{}
+	stsClient := &mocks.STS{}
+	stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("12345678"),
+	}, nil)
+	// the trust policy, the attached policies and a tag were changed outside of the controller
+	iamClient.On("GetRole", &iam.GetRoleInput{RoleName: aws.String("ccs-v1")}).Return(&iam.GetRoleOutput{
+		Role: &iam.Role{
+			Arn:                      aws.String("arn:aws:iam::12345678:role/ccs-v1"),
+			AssumeRolePolicyDocument: aws.String(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"sts:AssumeRole"}]}`),
+			Tags:                     []*iam.Tag{{Key: aws.String("team"), Value: aws.String("b")}, {Key: aws.String("other"), Value: aws.String("x")}},
+		},
+	}, nil)
+	iamClient.On("ListRolePoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("ListPoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("ListAttachedRolePoliciesPages", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(1).(func(*iam.ListAttachedRolePoliciesOutput, bool) bool)
+		arg(&iam.ListAttachedRolePoliciesOutput{AttachedPolicies: []*iam.AttachedPolicy{
+			{PolicyArn: aws.String("arn:aws:iam::aws:policy/AdministratorAccess")},
+		}}, true)
+	})
+	iamClient.On("UpdateAssumeRolePolicy", mock.Anything).Return(nil, nil)
+	iamClient.On("AttachRolePolicy", mock.Anything).Return(nil, nil)
+	iamClient.On("DetachRolePolicy", mock.Anything).Return(nil, nil)
+	iamClient.On("TagRole", &iam.TagRoleInput{
+		RoleName: aws.String("ccs-v1"),
+		Tags:     []*iam.Tag{{Key: aws.String("team"), Value: aws.String("a")}},
+	}).Return(nil, nil)
+	role := &v1alpha1.WorkloadIdentity{
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Name:     "ccs-v1",
+			Provider: v1alpha1.ProviderAWS,
+			Tags:     map[string]string{"team": "a"},
+			AWS: &v1alpha1.WorkloadIdentityAWS{
+				AssumeRolePolicy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`,
+				Policies:         []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			},
+		},
+		Status: v1alpha1.WorkloadIdentityStatus{
+			Name: "ccs-v1",
+			ID:   "arn:aws:iam::12345678:role/ccs-v1",
+		},
+	}
+	report := drift.New()
+	client, err := New(iamClient, stsClient, role, &options.Options{AWS: &awsx.Options{}})
+	assert.Nil(t, err)
+
+	_, err = client.WithDrift(report).CreateOrUpdate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []v1alpha1.Drift{
+		{Type: drift.TypeAssumeRolePolicy, Name: "ccs-v1", Change: v1alpha1.DriftChangeModified},
+		{Type: drift.TypePolicy, Name: "arn:aws:iam::aws:policy/ReadOnlyAccess", Change: v1alpha1.DriftChangeRemoved},
+		{Type: drift.TypePolicy, Name: "arn:aws:iam::aws:policy/AdministratorAccess", Change: v1alpha1.DriftChangeAdded},
+		{Type: drift.TypeTag, Name: "team", Change: v1alpha1.DriftChangeModified},
+	}, report.Items())
+	iamClient.AssertExpectations(t)
+}
+
+func TestCreateThenSyncInlinePolicies(t *testing.T) {
+	iamClient := &mocks.IAM{}
+	stsClient := &mocks.STS{}
+	stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("12345678"),
+	}, nil)
+	assumeRolePolicy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+	iamClient.On("GetRole", &iam.GetRoleInput{RoleName: aws.String("ccs-v1")}).Return(nil, errors.New("role not found in AWS")).Once()
+	iamClient.On("CreateRole", mock.Anything).Return(&iam.CreateRoleOutput{
+		Role: &iam.Role{
+			Arn:      aws.String("arn:aws:iam::12345678:role/ccs-v1"),
+			RoleName: aws.String("ccs-v1"),
+		},
+	}, nil).Once()
+	created := []string{}
+	iamClient.On("PutRolePolicy", mock.Anything).Return(nil, nil).Run(func(args mock.Arguments) {
+		created = append(created, aws.StringValue(args.Get(0).(*iam.PutRolePolicyInput).PolicyName))
+	}).Once()
+	role := &v1alpha1.WorkloadIdentity{
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Name:     "ccs-v1",
+			Provider: v1alpha1.ProviderAWS,
+			AWS: &v1alpha1.WorkloadIdentityAWS{
+				AssumeRolePolicy: assumeRolePolicy,
+				InlinePolicies: map[string]string{
+					"s3-read": `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`,
+				},
+			},
+		},
+	}
+	client, err := New(iamClient, stsClient, role, &options.Options{AWS: &awsx.Options{}})
+	assert.Nil(t, err)
+	_, err = client.CreateOrUpdate(context.Background())
+	assert.Nil(t, err)
+
+	// the inline policies are created with the names they are synced with
+	iamClient.On("GetRole", &iam.GetRoleInput{RoleName: aws.String("ccs-v1")}).Return(&iam.GetRoleOutput{
+		Role: &iam.Role{
+			Arn:                      aws.String("arn:aws:iam::12345678:role/ccs-v1"),
+			AssumeRolePolicyDocument: aws.String(assumeRolePolicy),
+		},
+	}, nil)
+	iamClient.On("ListRolePoliciesPages", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		arg := args.Get(1).(func(*iam.ListRolePoliciesOutput, bool) bool)
+		arg(&iam.ListRolePoliciesOutput{PolicyNames: aws.StringSlice(created)}, true)
+	})
+	iamClient.On("ListPoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("ListAttachedRolePoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	report := drift.New()
+	_, err = client.WithDrift(report).CreateOrUpdate(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, report.Items())
+	iamClient.AssertExpectations(t)
+}
+
+func TestCreateOrUpdateDryRun(t *testing.T) {
+	iamClient := &mocks.IAM{}
+	stsClient := &mocks.STS{}
+	stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("12345678"),
+	}, nil)
+	iamClient.On("GetRole", &iam.GetRoleInput{
+		RoleName: aws.String("ccs-v1"),
+	}).Return(nil, errors.New("role not found in AWS"))
+	role := &v1alpha1.WorkloadIdentity{
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Name:     "ccs-v1",
+			Provider: v1alpha1.ProviderAWS,
+			AWS: &v1alpha1.WorkloadIdentityAWS{
+				AssumeRolePolicy: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`,
+				InlinePolicies: map[string]string{
+					"s3-read": `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::bucket/*"}]}`,
+				},
+				Policies: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			},
+		},
+	}
+	p := plan.New()
+	client, err := New(awsx.NewPlanIAM(iamClient, p), stsClient, role, &options.Options{AWS: &awsx.Options{}})
+	assert.Nil(t, err)
+
+	// the changes are planned, nothing is applied
+	status, err := client.CreateOrUpdate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, &RoleStatus{Name: "ccs-v1", ARN: "arn:aws:iam::(planned):role/ccs-v1"}, status)
+	assert.Equal(t, []string{
+		"create IAM role ccs-v1",
+		"put inline policy s3-read-4af7b1b8fd69775b8b27420876e26ca6 of IAM role ccs-v1",
+		"attach policy arn:aws:iam::aws:policy/ReadOnlyAccess to IAM role ccs-v1",
+	}, p.Changes())
+	iamClient.AssertNotCalled(t, "CreateRole", mock.Anything)
+	iamClient.AssertNotCalled(t, "PutRolePolicy", mock.Anything)
+	iamClient.AssertNotCalled(t, "AttachRolePolicy", mock.Anything)
+}
+
+func TestObserve(t *testing.T) {
+	iamClient := &mocks.IAM{}
+	stsClient := &mocks.STS{}
+	stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("12345678"),
+	}, nil)
+	iamClient.On("GetRole", &iam.GetRoleInput{RoleName: aws.String("existing")}).Return(&iam.GetRoleOutput{
+		Role: &iam.Role{
+			RoleName: aws.String("existing"),
+			Arn:      aws.String("arn:aws:iam::12345678:role/existing"),
+		},
+	}, nil)
+	iamClient.On("ListRolePoliciesPages", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(func(*iam.ListRolePoliciesOutput, bool) bool)(&iam.ListRolePoliciesOutput{
+			PolicyNames: []*string{aws.String("s3"), aws.String("dynamodb")},
+		}, true)
+	})
+	iamClient.On("ListAttachedRolePoliciesPages", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(func(*iam.ListAttachedRolePoliciesOutput, bool) bool)(&iam.ListAttachedRolePoliciesOutput{
+			AttachedPolicies: []*iam.AttachedPolicy{{PolicyArn: aws.String("arn:aws:iam::aws:policy/ReadOnlyAccess")}},
+		}, true)
+	})
+	role := &v1alpha1.WorkloadIdentity{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{consts.ImportKey: "arn:aws:iam::12345678:role/existing"},
+		},
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Name:     "ccs-v1",
+			Provider: v1alpha1.ProviderAWS,
+			AWS:      &v1alpha1.WorkloadIdentityAWS{},
+		},
+	}
+	client, err := New(iamClient, stsClient, role, &options.Options{AWS: &awsx.Options{}})
+	assert.Nil(t, err)
+
+	status, observed, err := client.Observe()
+	assert.Nil(t, err)
+	assert.Equal(t, &RoleStatus{Name: "existing", ARN: "arn:aws:iam::12345678:role/existing"}, status)
+	assert.Equal(t, &v1alpha1.ObservedIdentity{
+		Policies:       []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+		InlinePolicies: []string{"dynamodb", "s3"},
+	}, observed)
+	iamClient.AssertNotCalled(t, "CreateRole", mock.Anything)
+	iamClient.AssertNotCalled(t, "UpdateAssumeRolePolicy", mock.Anything)
+	iamClient.AssertNotCalled(t, "PutRolePolicy", mock.Anything)
+}
+
 func TestDelete(t *testing.T) {
 	testCases := []struct {
 		desc                  string
@@ -459,6 +949,12 @@ func TestDelete(t *testing.T) {
 					}),
 					mock.AnythingOfType("func(*iam.ListAttachedRolePoliciesOutput, bool) bool")).Return(nil)
 
+				iamClient.On("ListPoliciesPages",
+					mock.MatchedBy(func(in *iam.ListPoliciesInput) bool {
+						return *in.PathPrefix == "/identity-manager/ccs-v2/"
+					}),
+					mock.AnythingOfType("func(*iam.ListPoliciesOutput, bool) bool")).Return(nil)
+
 				iamClient.On("DeleteRole", &iam.DeleteRoleInput{
 					RoleName: aws.String("ccs-v2"),
 				}).Return(&iam.DeleteRoleOutput{}, nil)
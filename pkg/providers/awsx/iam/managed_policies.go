@@ -0,0 +1,195 @@
+package iam
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/drift"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx"
+)
+
+// maxPolicyVersions is the IAM quota of versions per managed policy
+const maxPolicyVersions = 5
+
+// managedPolicyPath returns the path of the managed policies of a role.
+// The path scopes the policies to the role, so that stale ones can be found.
+func managedPolicyPath(roleName string) string {
+	return "/identity-manager/" + roleName + "/"
+}
+
+func managedPolicyName(name string) string {
+	// max length: 128
+	if len(name) > 128 {
+		return name[:128]
+	}
+	return name
+}
+
+// syncManagedPolicies creates or updates the managed policies of the spec and returns their arns,
+// along with the arns of the stale managed policies to be deleted once detached.
+func (i *Client) syncManagedPolicies(roleName string) ([]string, []string, error) {
+	existing, err := i.listManagedPolicies(roleName)
+	if err != nil {
+		return nil, nil, err
+	}
+	arns := []string{}
+	desired := map[string]bool{}
+	for name, document := range i.managedPolicies() {
+		policyName := managedPolicyName(name)
+		desired[policyName] = true
+		policy, ok := existing[policyName]
+		if !ok {
+			i.drift.Add(drift.TypeManagedPolicy, policyName, v1alpha1.DriftChangeRemoved)
+			policy, err = i.createManagedPolicy(roleName, policyName, document)
+			if err != nil {
+				return nil, nil, err
+			}
+		} else {
+			err = i.syncManagedPolicyVersion(policy, document)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		arns = append(arns, aws.StringValue(policy.Arn))
+	}
+	sort.Strings(arns)
+	stale := []string{}
+	for policyName, policy := range existing {
+		if !desired[policyName] {
+			stale = append(stale, aws.StringValue(policy.Arn))
+		}
+	}
+	return arns, stale, nil
+}
+
+func (i *Client) listManagedPolicies(roleName string) (map[string]*iam.Policy, error) {
+	policies := map[string]*iam.Policy{}
+	err := i.iam.ListPoliciesPages(&iam.ListPoliciesInput{
+		Scope:      aws.String(iam.PolicyScopeTypeLocal),
+		PathPrefix: aws.String(managedPolicyPath(roleName)),
+	}, func(page *iam.ListPoliciesOutput, lastPage bool) bool {
+		for _, policy := range page.Policies {
+			policies[aws.StringValue(policy.PolicyName)] = policy
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (i *Client) createManagedPolicy(roleName, policyName, document string) (*iam.Policy, error) {
+	tags := i.getTags()
+	out, err := i.iam.CreatePolicy(&iam.CreatePolicyInput{
+		PolicyName:     &policyName,
+		Path:           aws.String(managedPolicyPath(roleName)),
+		PolicyDocument: &document,
+		Tags:           tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Policy, nil
+}
+
+// syncManagedPolicyVersion creates a new default version of the policy if the document changed
+func (i *Client) syncManagedPolicyVersion(policy *iam.Policy, document string) error {
+	out, err := i.iam.GetPolicyVersion(&iam.GetPolicyVersionInput{
+		PolicyArn: policy.Arn,
+		VersionId: policy.DefaultVersionId,
+	})
+	if err != nil {
+		return err
+	}
+	existingDocument, err := url.PathUnescape(aws.StringValue(out.PolicyVersion.Document))
+	if err != nil {
+		return err
+	}
+	if toCompactJSON(existingDocument) == toCompactJSON(document) {
+		return nil
+	}
+	i.drift.Add(drift.TypeManagedPolicy, aws.StringValue(policy.PolicyName), v1alpha1.DriftChangeModified)
+	err = i.pruneManagedPolicyVersions(aws.StringValue(policy.Arn))
+	if err != nil {
+		return err
+	}
+	_, err = i.iam.CreatePolicyVersion(&iam.CreatePolicyVersionInput{
+		PolicyArn:      policy.Arn,
+		PolicyDocument: &document,
+		SetAsDefault:   aws.Bool(true),
+	})
+	return err
+}
+
+// pruneManagedPolicyVersions deletes the oldest non default version
+// when the policy reached the versions limit, to make room for a new one.
+func (i *Client) pruneManagedPolicyVersions(policyArn string) error {
+	versions, err := i.listManagedPolicyVersions(policyArn)
+	if err != nil {
+		return err
+	}
+	// the default version counts towards the limit too
+	if len(versions)+1 < maxPolicyVersions {
+		return nil
+	}
+	return i.deleteManagedPolicyVersion(policyArn, versions[0])
+}
+
+// listManagedPolicyVersions returns the non default versions, oldest first
+func (i *Client) listManagedPolicyVersions(policyArn string) ([]*iam.PolicyVersion, error) {
+	out, err := i.iam.ListPolicyVersions(&iam.ListPolicyVersionsInput{
+		PolicyArn: &policyArn,
+	})
+	if err != nil {
+		return nil, err
+	}
+	versions := []*iam.PolicyVersion{}
+	for _, version := range out.Versions {
+		if !aws.BoolValue(version.IsDefaultVersion) {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(a, b int) bool {
+		return aws.TimeValue(versions[a].CreateDate).Before(aws.TimeValue(versions[b].CreateDate))
+	})
+	return versions, nil
+}
+
+func (i *Client) deleteManagedPolicyVersion(policyArn string, version *iam.PolicyVersion) error {
+	_, err := i.iam.DeletePolicyVersion(&iam.DeletePolicyVersionInput{
+		PolicyArn: &policyArn,
+		VersionId: version.VersionId,
+	})
+	return err
+}
+
+// deleteManagedPolicy deletes a detached managed policy along with its versions
+func (i *Client) deleteManagedPolicy(policyArn string) error {
+	versions, err := i.listManagedPolicyVersions(policyArn)
+	if err != nil {
+		// check if already deleted
+		_, ok := awsx.CheckError(err, iam.ErrCodeNoSuchEntityException)
+		if ok {
+			return nil
+		}
+		return err
+	}
+	for _, version := range versions {
+		err = i.deleteManagedPolicyVersion(policyArn, version)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = i.iam.DeletePolicy(&iam.DeletePolicyInput{
+		PolicyArn: &policyArn,
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting managed policy %s: %w", policyArn, err)
+	}
+	return nil
+}
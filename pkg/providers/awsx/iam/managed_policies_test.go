@@ -0,0 +1,172 @@
+package iam
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	v1alpha1 "github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/options"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSyncManagedPolicies(t *testing.T) {
+	s3Read := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`
+	s3Write := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:PutObject","Resource":"*"}]}`
+	policyArn := func(name string) string {
+		return "arn:aws:iam::12345678:policy/identity-manager/app/" + name
+	}
+	listPolicies := func(iamClient *mocks.IAM, policies ...*iam.Policy) {
+		iamClient.On("ListPoliciesPages",
+			mock.MatchedBy(func(in *iam.ListPoliciesInput) bool {
+				return *in.PathPrefix == "/identity-manager/app/" && *in.Scope == iam.PolicyScopeTypeLocal
+			}),
+			mock.AnythingOfType("func(*iam.ListPoliciesOutput, bool) bool")).Return(nil).Run(func(args mock.Arguments) {
+			arg := args.Get(1).(func(*iam.ListPoliciesOutput, bool) bool)
+			arg(&iam.ListPoliciesOutput{Policies: policies}, true)
+		})
+	}
+	existingPolicy := func(iamClient *mocks.IAM, name, document string) *iam.Policy {
+		policy := &iam.Policy{
+			PolicyName:       aws.String(name),
+			Arn:              aws.String(policyArn(name)),
+			DefaultVersionId: aws.String("v1"),
+		}
+		iamClient.On("GetPolicyVersion", &iam.GetPolicyVersionInput{
+			PolicyArn: policy.Arn,
+			VersionId: aws.String("v1"),
+		}).Return(&iam.GetPolicyVersionOutput{
+			PolicyVersion: &iam.PolicyVersion{Document: aws.String(url.PathEscape(document))},
+		}, nil)
+		return policy
+	}
+	now := time.Now()
+
+	testCases := []struct {
+		desc                  string
+		managedPolicies       map[string]string
+		setupMockExpectations func(*mocks.IAM)
+		expectedArns          []string
+		expectedStale         []string
+	}{
+		{
+			desc:            "creates missing policies",
+			managedPolicies: map[string]string{"s3-read": s3Read},
+			setupMockExpectations: func(iamClient *mocks.IAM) {
+				listPolicies(iamClient)
+				iamClient.On("CreatePolicy", mock.MatchedBy(func(in *iam.CreatePolicyInput) bool {
+					return *in.PolicyName == "s3-read" && *in.Path == "/identity-manager/app/" && *in.PolicyDocument == s3Read
+				})).Return(&iam.CreatePolicyOutput{
+					Policy: &iam.Policy{Arn: aws.String(policyArn("s3-read"))},
+				}, nil)
+			},
+			expectedArns:  []string{policyArn("s3-read")},
+			expectedStale: []string{},
+		},
+		{
+			desc:            "keeps unchanged policies and returns stale ones",
+			managedPolicies: map[string]string{"s3-read": s3Read},
+			setupMockExpectations: func(iamClient *mocks.IAM) {
+				listPolicies(iamClient,
+					existingPolicy(iamClient, "s3-read", s3Read),
+					&iam.Policy{PolicyName: aws.String("s3-write"), Arn: aws.String(policyArn("s3-write"))},
+				)
+			},
+			expectedArns:  []string{policyArn("s3-read")},
+			expectedStale: []string{policyArn("s3-write")},
+		},
+		{
+			desc:            "creates a new version of changed policies, pruning the oldest one at the limit",
+			managedPolicies: map[string]string{"s3": s3Write},
+			setupMockExpectations: func(iamClient *mocks.IAM) {
+				listPolicies(iamClient, existingPolicy(iamClient, "s3", s3Read))
+				versions := []*iam.PolicyVersion{
+					{VersionId: aws.String("v1"), IsDefaultVersion: aws.Bool(true), CreateDate: aws.Time(now.Add(-5 * time.Hour))},
+				}
+				for ix, id := range []string{"v3", "v2", "v5", "v4"} {
+					versions = append(versions, &iam.PolicyVersion{
+						VersionId:  aws.String(id),
+						CreateDate: aws.Time(now.Add(-time.Duration(ix) * time.Hour)),
+					})
+				}
+				iamClient.On("ListPolicyVersions", &iam.ListPolicyVersionsInput{
+					PolicyArn: aws.String(policyArn("s3")),
+				}).Return(&iam.ListPolicyVersionsOutput{Versions: versions}, nil)
+				iamClient.On("DeletePolicyVersion", &iam.DeletePolicyVersionInput{
+					PolicyArn: aws.String(policyArn("s3")),
+					VersionId: aws.String("v4"),
+				}).Return(&iam.DeletePolicyVersionOutput{}, nil)
+				iamClient.On("CreatePolicyVersion", &iam.CreatePolicyVersionInput{
+					PolicyArn:      aws.String(policyArn("s3")),
+					PolicyDocument: aws.String(s3Write),
+					SetAsDefault:   aws.Bool(true),
+				}).Return(&iam.CreatePolicyVersionOutput{}, nil)
+			},
+			expectedArns:  []string{policyArn("s3")},
+			expectedStale: []string{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.desc, func(t *testing.T) {
+			iamClient := &mocks.IAM{}
+			stsClient := &mocks.STS{}
+			stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+				Account: aws.String("12345678"),
+			}, nil)
+			testCase.setupMockExpectations(iamClient)
+
+			role := &v1alpha1.WorkloadIdentity{
+				Spec: v1alpha1.WorkloadIdentitySpec{
+					Name:     "app",
+					Provider: v1alpha1.ProviderAWS,
+					AWS:      &v1alpha1.WorkloadIdentityAWS{ManagedPolicies: testCase.managedPolicies},
+				},
+			}
+			client, err := New(iamClient, stsClient, role, &options.Options{AWS: &awsx.Options{}})
+			assert.Nil(t, err)
+
+			arns, stale, err := client.syncManagedPolicies("app")
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expectedArns, arns)
+			assert.Equal(t, testCase.expectedStale, stale)
+			iamClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteManagedPolicy(t *testing.T) {
+	iamClient := &mocks.IAM{}
+	stsClient := &mocks.STS{}
+	stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("12345678"),
+	}, nil)
+	arn := aws.String("arn:aws:iam::12345678:policy/identity-manager/app/s3")
+	iamClient.On("ListPolicyVersions", &iam.ListPolicyVersionsInput{PolicyArn: arn}).Return(&iam.ListPolicyVersionsOutput{
+		Versions: []*iam.PolicyVersion{
+			{VersionId: aws.String("v1"), IsDefaultVersion: aws.Bool(false)},
+			{VersionId: aws.String("v2"), IsDefaultVersion: aws.Bool(true)},
+		},
+	}, nil)
+	iamClient.On("DeletePolicyVersion", &iam.DeletePolicyVersionInput{
+		PolicyArn: arn,
+		VersionId: aws.String("v1"),
+	}).Return(&iam.DeletePolicyVersionOutput{}, nil)
+	iamClient.On("DeletePolicy", &iam.DeletePolicyInput{PolicyArn: arn}).Return(&iam.DeletePolicyOutput{}, nil)
+
+	role := &v1alpha1.WorkloadIdentity{
+		Spec: v1alpha1.WorkloadIdentitySpec{Provider: v1alpha1.ProviderAWS, AWS: &v1alpha1.WorkloadIdentityAWS{}},
+	}
+	client, err := New(iamClient, stsClient, role, &options.Options{AWS: &awsx.Options{}})
+	assert.Nil(t, err)
+
+	err = client.deleteManagedPolicy(*arn)
+	assert.Nil(t, err)
+	iamClient.AssertExpectations(t)
+}
@@ -0,0 +1,82 @@
+package iam
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	v1alpha1 "github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+)
+
+// Observe reads the role and its policies, without mutating them
+func (i *Client) Observe() (*RoleStatus, *v1alpha1.ObservedIdentity, error) {
+	roleName := i.observedRoleName()
+	out, err := i.iam.GetRole(&iam.GetRoleInput{
+		RoleName: &roleName,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	inlinePolicies, err := i.listInlinePolicies(roleName)
+	if err != nil {
+		return nil, nil, err
+	}
+	attachedPolicies, err := i.listAttachedPolicies(roleName)
+	if err != nil {
+		return nil, nil, err
+	}
+	policies := toArns(attachedPolicies)
+	sort.Strings(inlinePolicies)
+	sort.Strings(policies)
+	status := &RoleStatus{Name: aws.StringValue(out.Role.RoleName), ARN: aws.StringValue(out.Role.Arn)}
+	return status, &v1alpha1.ObservedIdentity{Policies: policies, InlinePolicies: inlinePolicies}, nil
+}
+
+// Ready checks whether the role exists and its trust policy allows to assume it
+func (i *Client) Ready() error {
+	roleName := i.currentRoleName()
+	if roleName == "" {
+		return fmt.Errorf("role not created")
+	}
+	out, err := i.iam.GetRole(&iam.GetRoleInput{
+		RoleName: &roleName,
+	})
+	if err != nil {
+		return err
+	}
+	document, err := url.PathUnescape(aws.StringValue(out.Role.AssumeRolePolicyDocument))
+	if err != nil {
+		return err
+	}
+	if !isAssumable(document) {
+		return fmt.Errorf("the trust policy of role %s does not allow to assume it", roleName)
+	}
+	return nil
+}
+
+// Exists checks whether the role deleted by Delete still exists
+func (i *Client) Exists() bool {
+	roleName := i.currentRoleName()
+	return roleName != "" && i.isExists(roleName)
+}
+
+// currentRoleName returns the name of the imported role if any, or else the name the role was created with
+func (i *Client) currentRoleName() string {
+	if i.role.GetAnnotations()[consts.ImportKey] != "" {
+		return i.observedRoleName()
+	}
+	return i.role.Status.Name
+}
+
+// observedRoleName returns the name of the imported role arn if any, or else the name of the role
+func (i *Client) observedRoleName() string {
+	importID := i.role.GetAnnotations()[consts.ImportKey]
+	if ix := strings.LastIndex(importID, "/"); ix > -1 {
+		return importID[ix+1:]
+	}
+	return i.roleName()
+}
@@ -0,0 +1,243 @@
+package iam
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/invisibl-cloud/identity-manager/pkg/util"
+)
+
+// Size limits of the policies, whitespaces excluded
+const (
+	maxInlinePoliciesSize = 10240
+	maxManagedPolicySize  = 6144
+	maxTrustPolicySize    = 2048
+)
+
+var policyVersions = []string{"2012-10-17", "2008-10-17"}
+var principalTypes = []string{"AWS", "Service", "Federated", "CanonicalUser"}
+var actionPattern = regexp.MustCompile(`^(\*|[a-zA-Z0-9-]+:[a-zA-Z0-9*?]+)$`)
+
+// PolicyErrors are the problems found in the policies by the linter
+type PolicyErrors []string
+
+func (e PolicyErrors) Error() string {
+	return "invalid policies: " + strings.Join(e, "; ")
+}
+
+// ValidatePolicies lints the policies of the spec, without calling AWS.
+// It returns PolicyErrors if any of them is invalid.
+func (i *Client) ValidatePolicies() error {
+	errs := PolicyErrors{}
+	if assumeRolePolicy := i.assumeRolePolicy(); assumeRolePolicy != "" {
+		errs = append(errs, lintPolicy("assumeRolePolicy", assumeRolePolicy, true, maxTrustPolicySize)...)
+	}
+	inlinePolicies := i.inlinePolicies()
+	size := 0
+	for _, name := range sortedKeys(inlinePolicies) {
+		// the size limit applies to all the inline policies of the role together
+		errs = append(errs, lintPolicy(fmt.Sprintf("inlinePolicies[%s]", name), inlinePolicies[name], false, 0)...)
+		size += len(toCompactJSON(inlinePolicies[name]))
+	}
+	if size > maxInlinePoliciesSize {
+		errs = append(errs, fmt.Sprintf("inlinePolicies: size %d exceeds the limit of %d characters", size, maxInlinePoliciesSize))
+	}
+	managedPolicies := i.managedPolicies()
+	for _, name := range sortedKeys(managedPolicies) {
+		errs = append(errs, lintPolicy(fmt.Sprintf("managedPolicies[%s]", name), managedPolicies[name], false, maxManagedPolicySize)...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// lintPolicy checks the structure of a policy document.
+// Trust policies require a Principal, whereas identity policies require a Resource.
+func lintPolicy(name, document string, trust bool, maxSize int) []string {
+	errs := []string{}
+	addError := func(format string, args ...any) {
+		errs = append(errs, name+": "+fmt.Sprintf(format, args...))
+	}
+	policy := map[string]any{}
+	decoder := json.NewDecoder(bytes.NewBufferString(document))
+	decoder.UseNumber()
+	err := decoder.Decode(&policy)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			addError("invalid JSON at offset %d: %s", syntaxErr.Offset, syntaxErr.Error())
+		} else {
+			addError("invalid JSON: %s", err.Error())
+		}
+		return errs
+	}
+	if maxSize > 0 {
+		if size := len(toCompactJSON(document)); size > maxSize {
+			addError("size %d exceeds the limit of %d characters", size, maxSize)
+		}
+	}
+	for _, key := range sortedKeys(policy) {
+		if key != "Version" && key != "Id" && key != "Statement" {
+			addError("unknown element %s", key)
+		}
+	}
+	if version, ok := policy["Version"]; ok {
+		if v, isString := version.(string); !isString || !util.Contains(policyVersions, v) {
+			addError("invalid Version %v, expected one of %s", version, strings.Join(policyVersions, ", "))
+		}
+	}
+	var statements []any
+	switch statement := policy["Statement"].(type) {
+	case nil:
+		addError("missing Statement")
+	case map[string]any:
+		statements = []any{statement}
+	case []any:
+		if len(statement) == 0 {
+			addError("empty Statement")
+		}
+		statements = statement
+	default:
+		addError("Statement must be an object or a list of objects")
+	}
+	for ix, s := range statements {
+		statement, ok := s.(map[string]any)
+		if !ok {
+			addError("Statement[%d] must be an object", ix)
+			continue
+		}
+		for _, msg := range lintStatement(statement, trust) {
+			addError("Statement[%d]: %s", ix, msg)
+		}
+	}
+	return errs
+}
+
+func lintStatement(statement map[string]any, trust bool) []string {
+	errs := []string{}
+	switch effect := statement["Effect"]; effect {
+	case nil:
+		errs = append(errs, "missing Effect")
+	case "Allow", "Deny":
+	default:
+		errs = append(errs, fmt.Sprintf("invalid Effect %v, expected Allow or Deny", effect))
+	}
+	actions, msg := lintExclusive(statement, "Action", "NotAction")
+	if msg != "" {
+		errs = append(errs, msg)
+	}
+	for _, action := range actions {
+		if !actionPattern.MatchString(action) {
+			errs = append(errs, fmt.Sprintf("invalid action %q, expected service:action", action))
+		}
+	}
+	if trust {
+		_, hasPrincipal := statement["Principal"]
+		_, hasNotPrincipal := statement["NotPrincipal"]
+		if !hasPrincipal && !hasNotPrincipal {
+			errs = append(errs, "missing Principal")
+		}
+		if hasPrincipal {
+			errs = append(errs, lintPrincipal(statement["Principal"])...)
+		}
+		if _, ok := statement["Resource"]; ok {
+			errs = append(errs, "Resource is not allowed in a trust policy")
+		}
+	} else {
+		if _, ok := statement["Principal"]; ok {
+			errs = append(errs, "Principal is not allowed in an identity policy")
+		}
+		resources, msg := lintExclusive(statement, "Resource", "NotResource")
+		if msg != "" {
+			errs = append(errs, msg)
+		}
+		// wildcard admin: any action on any resource
+		_, hasAction := statement["Action"]
+		_, hasResource := statement["Resource"]
+		_, hasCondition := statement["Condition"]
+		if statement["Effect"] == "Allow" && hasAction && hasResource && !hasCondition &&
+			(util.Contains(actions, "*") || util.Contains(actions, "*:*")) && util.Contains(resources, "*") {
+			errs = append(errs, "grants full administrator access (Action * on Resource *)")
+		}
+	}
+	if condition, ok := statement["Condition"]; ok {
+		if _, isObject := condition.(map[string]any); !isObject {
+			errs = append(errs, "Condition must be an object")
+		}
+	}
+	return errs
+}
+
+// lintExclusive checks that exactly one of the elements is set, as a string or a list of strings
+func lintExclusive(statement map[string]any, key, notKey string) ([]string, string) {
+	value, hasKey := statement[key]
+	notValue, hasNotKey := statement[notKey]
+	switch {
+	case hasKey && hasNotKey:
+		return nil, fmt.Sprintf("only one of %s and %s can be set", key, notKey)
+	case hasKey:
+		return toStrings(key, value)
+	case hasNotKey:
+		return toStrings(notKey, notValue)
+	}
+	return nil, fmt.Sprintf("missing %s or %s", key, notKey)
+}
+
+func lintPrincipal(principal any) []string {
+	switch p := principal.(type) {
+	case string:
+		if p != "*" {
+			return []string{fmt.Sprintf("invalid Principal %q, expected * or an object", p)}
+		}
+		return nil
+	case map[string]any:
+		errs := []string{}
+		for _, key := range sortedKeys(p) {
+			if !util.Contains(principalTypes, key) {
+				errs = append(errs, fmt.Sprintf("invalid Principal type %s, expected one of %s", key, strings.Join(principalTypes, ", ")))
+				continue
+			}
+			if _, msg := toStrings("Principal."+key, p[key]); msg != "" {
+				errs = append(errs, msg)
+			}
+		}
+		return errs
+	}
+	return []string{"Principal must be * or an object"}
+}
+
+func toStrings(key string, value any) ([]string, string) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, ""
+	case []any:
+		if len(v) == 0 {
+			return nil, fmt.Sprintf("empty %s", key)
+		}
+		values := make([]string, len(v))
+		for ix, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Sprintf("%s must be a string or a list of strings", key)
+			}
+			values[ix] = s
+		}
+		return values, ""
+	}
+	return nil, fmt.Sprintf("%s must be a string or a list of strings", key)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,115 @@
+package iam
+
+import (
+	"strings"
+	"testing"
+
+	v1alpha1 "github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintPolicy(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		document string
+		trust    bool
+		maxSize  int
+		expected []string
+	}{
+		{
+			desc:     "valid identity policy",
+			document: `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:Get*","s3:ListBucket"],"Resource":"*"}]}`,
+			expected: []string{},
+		},
+		{
+			desc:     "valid trust policy",
+			document: `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Principal":{"Service":"pods.eks.amazonaws.com"},"Action":["sts:AssumeRole","sts:TagSession"]}}`,
+			trust:    true,
+			expected: []string{},
+		},
+		{
+			desc:     "invalid JSON",
+			document: `{"Version":"2012-10-17",}`,
+			expected: []string{"p: invalid JSON at offset 25: invalid character '}' looking for beginning of object key string"},
+		},
+		{
+			desc:     "invalid version and missing statement",
+			document: `{"Version":"2020-01-01","Statements":[]}`,
+			expected: []string{
+				"p: unknown element Statements",
+				"p: invalid Version 2020-01-01, expected one of 2012-10-17, 2008-10-17",
+				"p: missing Statement",
+			},
+		},
+		{
+			desc:     "invalid statement shape",
+			document: `{"Statement":[{"Effect":"allow","Action":"s3","NotAction":"s3:*","Principal":"*","Condition":[]}]}`,
+			expected: []string{
+				"p: Statement[0]: invalid Effect allow, expected Allow or Deny",
+				"p: Statement[0]: only one of Action and NotAction can be set",
+				"p: Statement[0]: Principal is not allowed in an identity policy",
+				"p: Statement[0]: missing Resource or NotResource",
+				"p: Statement[0]: Condition must be an object",
+			},
+		},
+		{
+			desc:     "invalid trust policy",
+			document: `{"Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Resource":"*"},{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"Role":"x","AWS":[]}}]}`,
+			trust:    true,
+			expected: []string{
+				"p: Statement[0]: missing Principal",
+				"p: Statement[0]: Resource is not allowed in a trust policy",
+				"p: Statement[1]: empty Principal.AWS",
+				"p: Statement[1]: invalid Principal type Role, expected one of AWS, Service, Federated, CanonicalUser",
+			},
+		},
+		{
+			desc:     "wildcard admin",
+			document: `{"Statement":[{"Effect":"Allow","Action":"*","Resource":["*"]}]}`,
+			expected: []string{"p: Statement[0]: grants full administrator access (Action * on Resource *)"},
+		},
+		{
+			desc:     "wildcard with condition",
+			document: `{"Statement":[{"Effect":"Allow","Action":"*","Resource":"*","Condition":{"Bool":{"aws:MultiFactorAuthPresent":"true"}}}]}`,
+			expected: []string{},
+		},
+		{
+			desc:     "size limit",
+			document: `{"Statement":[{"Effect":"Allow",  "Action":"s3:GetObject",  "Resource":"` + strings.Repeat("a", 40) + `"}]}`,
+			maxSize:  64,
+			expected: []string{"p: size 112 exceeds the limit of 64 characters"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.desc, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, lintPolicy("p", testCase.document, testCase.trust, testCase.maxSize))
+		})
+	}
+}
+
+func TestValidatePolicies(t *testing.T) {
+	client := &Client{
+		role: &v1alpha1.WorkloadIdentity{
+			Spec: v1alpha1.WorkloadIdentitySpec{
+				AWS: &v1alpha1.WorkloadIdentityAWS{
+					AssumeRolePolicy: `{"Statement":[{"Effect":"Allow","Action":"sts:AssumeRole","Principal":{"Service":"ec2.amazonaws.com"}}]}`,
+					InlinePolicies: map[string]string{
+						"big": `{"Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"` + strings.Repeat("a", maxInlinePoliciesSize) + `"}]}`,
+					},
+					ManagedPolicies: map[string]string{
+						"bad": `{"Statement":[]}`,
+					},
+				},
+			},
+		},
+	}
+	err := client.ValidatePolicies()
+	assert.Equal(t, PolicyErrors{
+		"inlinePolicies: size 10312 exceeds the limit of 10240 characters",
+		"managedPolicies[bad]: empty Statement",
+	}, err)
+
+	client.role.Spec.AWS.InlinePolicies = nil
+	client.role.Spec.AWS.ManagedPolicies = nil
+	assert.Nil(t, client.ValidatePolicies())
+}
@@ -0,0 +1,65 @@
+package iam
+
+import (
+	"github.com/valyala/fasttemplate"
+)
+
+// PolicyDocuments are the policy documents read from the ConfigMaps and Secrets referenced by the spec
+type PolicyDocuments struct {
+	AssumeRolePolicy string
+	InlinePolicies   map[string]string
+}
+
+// WithPolicyDocuments sets the policy documents read from the ConfigMaps and Secrets referenced by the spec
+func (i *Client) WithPolicyDocuments(documents *PolicyDocuments) *Client {
+	i.documents = documents
+	return i
+}
+
+// WithTemplateValues sets the region and the cluster name used to render the policy templates
+func (i *Client) WithTemplateValues(region, clusterName string) *Client {
+	i.region = region
+	i.clusterName = clusterName
+	return i
+}
+
+// templateData returns the values of the placeholders of the policy templates
+func (i *Client) templateData() map[string]any {
+	return map[string]any{
+		"accountID":   i.accountID,
+		"region":      i.region,
+		"namespace":   i.role.Namespace,
+		"name":        i.role.Name,
+		"roleName":    i.observedRoleName(),
+		"clusterName": i.clusterName,
+	}
+}
+
+// render replaces the placeholders of a policy template, keeping the unknown ones as is
+func (i *Client) render(document string) string {
+	return fasttemplate.ExecuteStringStd(document, "<(", ")", i.templateData())
+}
+
+// inlinePolicies returns the rendered inline policies, including the ones read from ConfigMaps and Secrets.
+// The policies of the spec take precedence.
+func (i *Client) inlinePolicies() map[string]string {
+	policies := map[string]string{}
+	if i.documents != nil {
+		for name, document := range i.documents.InlinePolicies {
+			policies[name] = i.render(document)
+		}
+	}
+	for name, document := range i.role.Spec.AWS.InlinePolicies {
+		policies[name] = i.render(document)
+	}
+	return policies
+}
+
+// managedPolicies returns the rendered managed policies
+func (i *Client) managedPolicies() map[string]string {
+	policies := map[string]string{}
+	for name, document := range i.role.Spec.AWS.ManagedPolicies {
+		policies[name] = i.render(document)
+	}
+	return policies
+}
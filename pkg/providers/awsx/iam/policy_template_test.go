@@ -0,0 +1,46 @@
+package iam
+
+import (
+	"testing"
+
+	v1alpha1 "github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPolicyTemplates(t *testing.T) {
+	client := &Client{
+		role: &v1alpha1.WorkloadIdentity{
+			ObjectMeta: v1.ObjectMeta{Name: "app", Namespace: "dev"},
+			Spec: v1alpha1.WorkloadIdentitySpec{
+				AWS: &v1alpha1.WorkloadIdentityAWS{
+					InlinePolicies: map[string]string{
+						"s3":  `{"Resource":"arn:aws:s3:::<(namespace)-<(name)/${aws:username}"}`,
+						"kms": `{"Resource":"arn:aws:kms:<(region):<(accountID):key/*"}`,
+					},
+					ManagedPolicies: map[string]string{
+						"eks": `{"Resource":"arn:aws:eks:<(region):<(accountID):cluster/<(clusterName)","Sid":"<(roleName)<(unknown)"}`,
+					},
+				},
+			},
+		},
+		accountID: "12345678",
+	}
+	client = client.WithTemplateValues("us-east-1", "prod").WithPolicyDocuments(&PolicyDocuments{
+		AssumeRolePolicy: `{"Principal":"arn:aws:iam::<(accountID):root"}`,
+		InlinePolicies: map[string]string{
+			"s3":  `{"Resource":"overridden by the spec"}`,
+			"sqs": `{"Resource":"arn:aws:sqs:<(region):<(accountID):<(namespace)-*"}`,
+		},
+	})
+
+	assert.Equal(t, map[string]string{
+		"s3":  `{"Resource":"arn:aws:s3:::dev-app/${aws:username}"}`,
+		"kms": `{"Resource":"arn:aws:kms:us-east-1:12345678:key/*"}`,
+		"sqs": `{"Resource":"arn:aws:sqs:us-east-1:12345678:dev-*"}`,
+	}, client.inlinePolicies())
+	assert.Equal(t, map[string]string{
+		"eks": `{"Resource":"arn:aws:eks:us-east-1:12345678:cluster/prod","Sid":"dev-app<(unknown)"}`,
+	}, client.managedPolicies())
+	assert.Equal(t, `{"Principal":"arn:aws:iam::12345678:root"}`, client.assumeRolePolicy())
+}
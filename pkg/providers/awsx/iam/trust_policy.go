@@ -0,0 +1,126 @@
+package iam
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/invisibl-cloud/identity-manager/pkg/util"
+)
+
+const (
+	oidcProviderArnSeparator = ":oidc-provider/"
+	podIdentityPrincipal     = "pods.eks.amazonaws.com"
+	webIdentityAudience      = "sts.amazonaws.com"
+)
+
+type trustPolicy struct {
+	Version   string                  `json:"Version"`
+	Statement []*trustPolicyStatement `json:"Statement"`
+}
+
+type trustPolicyStatement struct {
+	Effect    string                    `json:"Effect"`
+	Principal map[string]string         `json:"Principal"`
+	Action    []string                  `json:"Action"`
+	Condition map[string]map[string]any `json:"Condition,omitempty"`
+}
+
+// assumeRolePolicy returns the rendered assume role policy of the spec, or else the one read from
+// a ConfigMap or a Secret. When none is set, it is generated
+// for EKS Pod Identity if enabled, or else for IRSA from the OIDC provider and the service accounts.
+func (i *Client) assumeRolePolicy() string {
+	if i.role.Spec.AWS.AssumeRolePolicy != "" {
+		return i.render(i.role.Spec.AWS.AssumeRolePolicy)
+	}
+	if i.documents != nil && i.documents.AssumeRolePolicy != "" {
+		return i.render(i.documents.AssumeRolePolicy)
+	}
+	var statement *trustPolicyStatement
+	if i.role.Spec.AWS.PodIdentity != nil {
+		statement = podIdentityStatement()
+	} else if i.oidcProvider != "" && len(i.role.Spec.AWS.ServiceAccounts) > 0 {
+		statement = i.webIdentityStatement()
+	}
+	if statement == nil {
+		return ""
+	}
+	data, err := json.Marshal(&trustPolicy{Version: "2012-10-17", Statement: []*trustPolicyStatement{statement}})
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// isAssumable checks whether a statement of the trust policy allows an sts:AssumeRole action.
+// Action and Statement are either a value or a list in IAM policies.
+func isAssumable(document string) bool {
+	var policy struct {
+		Statement any `json:"Statement"`
+	}
+	if json.Unmarshal([]byte(document), &policy) != nil {
+		return false
+	}
+	statements, ok := policy.Statement.([]any)
+	if !ok {
+		statements = []any{policy.Statement}
+	}
+	for _, s := range statements {
+		statement, ok := s.(map[string]any)
+		if !ok || statement["Effect"] != "Allow" {
+			continue
+		}
+		actions, ok := statement["Action"].([]any)
+		if !ok {
+			actions = []any{statement["Action"]}
+		}
+		for _, a := range actions {
+			action, _ := a.(string)
+			if action == "*" || action == "sts:*" || strings.HasPrefix(action, "sts:AssumeRole") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func podIdentityStatement() *trustPolicyStatement {
+	return &trustPolicyStatement{
+		Effect:    "Allow",
+		Principal: map[string]string{"Service": podIdentityPrincipal},
+		Action:    []string{"sts:AssumeRole", "sts:TagSession"},
+	}
+}
+
+func (i *Client) webIdentityStatement() *trustPolicyStatement {
+	providerArn, issuer := i.oidcProviderArnAndIssuer()
+	subjects := []string{}
+	for _, sa := range i.role.Spec.AWS.ServiceAccounts {
+		subjects = append(subjects, fmt.Sprintf("system:serviceaccount:%s:%s",
+			util.DefaultString(sa.Namespace, i.role.Namespace), util.DefaultString(sa.Name, i.role.Name)))
+	}
+	// stable policy, so that it is only updated when the service accounts change
+	sort.Strings(subjects)
+	return &trustPolicyStatement{
+		Effect:    "Allow",
+		Principal: map[string]string{"Federated": providerArn},
+		Action:    []string{"sts:AssumeRoleWithWebIdentity"},
+		Condition: map[string]map[string]any{
+			"StringEquals": {
+				issuer + ":aud": webIdentityAudience,
+				issuer + ":sub": subjects,
+			},
+		},
+	}
+}
+
+// oidcProviderArnAndIssuer returns the arn and the issuer (without scheme) of the OIDC provider,
+// which is configured either by its arn or by its issuer url.
+func (i *Client) oidcProviderArnAndIssuer() (string, string) {
+	if ix := strings.Index(i.oidcProvider, oidcProviderArnSeparator); ix > -1 {
+		return i.oidcProvider, i.oidcProvider[ix+len(oidcProviderArnSeparator):]
+	}
+	issuer := strings.TrimPrefix(i.oidcProvider, "https://")
+	return fmt.Sprintf("arn:aws:iam::%s%s%s", i.accountID, oidcProviderArnSeparator, issuer), issuer
+}
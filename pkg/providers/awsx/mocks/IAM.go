@@ -38,6 +38,58 @@ func (_m *IAM) AttachRolePolicy(_a0 *iam.AttachRolePolicyInput) (*iam.AttachRole
 	return r0, r1
 }
 
+// CreatePolicy provides a mock function with given fields: _a0
+func (_m *IAM) CreatePolicy(_a0 *iam.CreatePolicyInput) (*iam.CreatePolicyOutput, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *iam.CreatePolicyOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*iam.CreatePolicyInput) (*iam.CreatePolicyOutput, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(*iam.CreatePolicyInput) *iam.CreatePolicyOutput); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*iam.CreatePolicyOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*iam.CreatePolicyInput) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreatePolicyVersion provides a mock function with given fields: _a0
+func (_m *IAM) CreatePolicyVersion(_a0 *iam.CreatePolicyVersionInput) (*iam.CreatePolicyVersionOutput, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *iam.CreatePolicyVersionOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*iam.CreatePolicyVersionInput) (*iam.CreatePolicyVersionOutput, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(*iam.CreatePolicyVersionInput) *iam.CreatePolicyVersionOutput); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*iam.CreatePolicyVersionOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*iam.CreatePolicyVersionInput) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateRole provides a mock function with given fields: _a0
 func (_m *IAM) CreateRole(_a0 *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
 	ret := _m.Called(_a0)
@@ -64,6 +116,58 @@ func (_m *IAM) CreateRole(_a0 *iam.CreateRoleInput) (*iam.CreateRoleOutput, erro
 	return r0, r1
 }
 
+// DeletePolicy provides a mock function with given fields: _a0
+func (_m *IAM) DeletePolicy(_a0 *iam.DeletePolicyInput) (*iam.DeletePolicyOutput, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *iam.DeletePolicyOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*iam.DeletePolicyInput) (*iam.DeletePolicyOutput, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(*iam.DeletePolicyInput) *iam.DeletePolicyOutput); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*iam.DeletePolicyOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*iam.DeletePolicyInput) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeletePolicyVersion provides a mock function with given fields: _a0
+func (_m *IAM) DeletePolicyVersion(_a0 *iam.DeletePolicyVersionInput) (*iam.DeletePolicyVersionOutput, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *iam.DeletePolicyVersionOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*iam.DeletePolicyVersionInput) (*iam.DeletePolicyVersionOutput, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(*iam.DeletePolicyVersionInput) *iam.DeletePolicyVersionOutput); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*iam.DeletePolicyVersionOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*iam.DeletePolicyVersionInput) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteRole provides a mock function with given fields: _a0
 func (_m *IAM) DeleteRole(_a0 *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
 	ret := _m.Called(_a0)
@@ -168,6 +272,32 @@ func (_m *IAM) DetachRolePolicy(_a0 *iam.DetachRolePolicyInput) (*iam.DetachRole
 	return r0, r1
 }
 
+// GetPolicyVersion provides a mock function with given fields: _a0
+func (_m *IAM) GetPolicyVersion(_a0 *iam.GetPolicyVersionInput) (*iam.GetPolicyVersionOutput, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *iam.GetPolicyVersionOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*iam.GetPolicyVersionInput) (*iam.GetPolicyVersionOutput, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(*iam.GetPolicyVersionInput) *iam.GetPolicyVersionOutput); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*iam.GetPolicyVersionOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*iam.GetPolicyVersionInput) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetRole provides a mock function with given fields: _a0
 func (_m *IAM) GetRole(_a0 *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
 	ret := _m.Called(_a0)
@@ -208,6 +338,46 @@ func (_m *IAM) ListAttachedRolePoliciesPages(_a0 *iam.ListAttachedRolePoliciesIn
 	return r0
 }
 
+// ListPoliciesPages provides a mock function with given fields: _a0, _a1
+func (_m *IAM) ListPoliciesPages(_a0 *iam.ListPoliciesInput, _a1 func(*iam.ListPoliciesOutput, bool) bool) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*iam.ListPoliciesInput, func(*iam.ListPoliciesOutput, bool) bool) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ListPolicyVersions provides a mock function with given fields: _a0
+func (_m *IAM) ListPolicyVersions(_a0 *iam.ListPolicyVersionsInput) (*iam.ListPolicyVersionsOutput, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *iam.ListPolicyVersionsOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*iam.ListPolicyVersionsInput) (*iam.ListPolicyVersionsOutput, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(*iam.ListPolicyVersionsInput) *iam.ListPolicyVersionsOutput); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*iam.ListPolicyVersionsOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*iam.ListPolicyVersionsInput) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListRolePoliciesPages provides a mock function with given fields: _a0, _a1
 func (_m *IAM) ListRolePoliciesPages(_a0 *iam.ListRolePoliciesInput, _a1 func(*iam.ListRolePoliciesOutput, bool) bool) error {
 	ret := _m.Called(_a0, _a1)
@@ -12,6 +12,32 @@ type STS struct {
 	mock.Mock
 }
 
+// AssumeRole provides a mock function with given fields: _a0
+func (_m *STS) AssumeRole(_a0 *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	ret := _m.Called(_a0)
+
+	var r0 *sts.AssumeRoleOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(*sts.AssumeRoleInput) *sts.AssumeRoleOutput); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*sts.AssumeRoleOutput)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*sts.AssumeRoleInput) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetCallerIdentity provides a mock function with given fields: _a0
 func (_m *STS) GetCallerIdentity(_a0 *sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
 	ret := _m.Called(_a0)
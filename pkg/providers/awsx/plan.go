@@ -0,0 +1,179 @@
+package awsx
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/invisibl-cloud/identity-manager/pkg/plan"
+)
+
+// plannedAccountID replaces the account id of the arns of the resources which would be created
+const plannedAccountID = "(planned)"
+
+// NewPlanIAM returns an IAM which reads from c and records every
+// mutating call into the Plan instead of sending it to AWS
+func NewPlanIAM(c IAM, p *plan.Plan) IAM {
+	return &planIAM{IAM: c, plan: p}
+}
+
+type planIAM struct {
+	IAM
+	plan *plan.Plan
+}
+
+func (c *planIAM) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	c.plan.Add("create IAM role %s", aws.StringValue(input.RoleName))
+	path := aws.StringValue(input.Path)
+	if path == "" {
+		path = "/"
+	}
+	return &iam.CreateRoleOutput{Role: &iam.Role{
+		RoleName: input.RoleName,
+		Path:     aws.String(path),
+		Arn:      aws.String(fmt.Sprintf("arn:aws:iam::%s:role%s%s", plannedAccountID, path, aws.StringValue(input.RoleName))),
+	}}, nil
+}
+
+func (c *planIAM) DeleteRole(input *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
+	c.plan.Add("delete IAM role %s", aws.StringValue(input.RoleName))
+	return &iam.DeleteRoleOutput{}, nil
+}
+
+func (c *planIAM) UpdateRole(input *iam.UpdateRoleInput) (*iam.UpdateRoleOutput, error) {
+	c.plan.Add("update IAM role %s", aws.StringValue(input.RoleName))
+	return &iam.UpdateRoleOutput{}, nil
+}
+
+func (c *planIAM) UpdateRoleDescription(input *iam.UpdateRoleDescriptionInput) (*iam.UpdateRoleDescriptionOutput, error) {
+	c.plan.Add("update description of IAM role %s", aws.StringValue(input.RoleName))
+	return &iam.UpdateRoleDescriptionOutput{}, nil
+}
+
+func (c *planIAM) DeleteRolePolicy(input *iam.DeleteRolePolicyInput) (*iam.DeleteRolePolicyOutput, error) {
+	c.plan.Add("delete inline policy %s of IAM role %s", aws.StringValue(input.PolicyName), aws.StringValue(input.RoleName))
+	return &iam.DeleteRolePolicyOutput{}, nil
+}
+
+func (c *planIAM) DetachRolePolicy(input *iam.DetachRolePolicyInput) (*iam.DetachRolePolicyOutput, error) {
+	c.plan.Add("detach policy %s from IAM role %s", aws.StringValue(input.PolicyArn), aws.StringValue(input.RoleName))
+	return &iam.DetachRolePolicyOutput{}, nil
+}
+
+func (c *planIAM) UpdateAssumeRolePolicy(input *iam.UpdateAssumeRolePolicyInput) (*iam.UpdateAssumeRolePolicyOutput, error) {
+	c.plan.Add("update assume role policy of IAM role %s", aws.StringValue(input.RoleName))
+	return &iam.UpdateAssumeRolePolicyOutput{}, nil
+}
+
+func (c *planIAM) AttachRolePolicy(input *iam.AttachRolePolicyInput) (*iam.AttachRolePolicyOutput, error) {
+	c.plan.Add("attach policy %s to IAM role %s", aws.StringValue(input.PolicyArn), aws.StringValue(input.RoleName))
+	return &iam.AttachRolePolicyOutput{}, nil
+}
+
+func (c *planIAM) PutRolePolicy(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	c.plan.Add("put inline policy %s of IAM role %s", aws.StringValue(input.PolicyName), aws.StringValue(input.RoleName))
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func (c *planIAM) PutRolePermissionsBoundary(input *iam.PutRolePermissionsBoundaryInput) (*iam.PutRolePermissionsBoundaryOutput, error) {
+	c.plan.Add("put permissions boundary %s of IAM role %s", aws.StringValue(input.PermissionsBoundary), aws.StringValue(input.RoleName))
+	return &iam.PutRolePermissionsBoundaryOutput{}, nil
+}
+
+func (c *planIAM) DeleteRolePermissionsBoundary(input *iam.DeleteRolePermissionsBoundaryInput) (*iam.DeleteRolePermissionsBoundaryOutput, error) {
+	c.plan.Add("delete permissions boundary of IAM role %s", aws.StringValue(input.RoleName))
+	return &iam.DeleteRolePermissionsBoundaryOutput{}, nil
+}
+
+func (c *planIAM) TagRole(input *iam.TagRoleInput) (*iam.TagRoleOutput, error) {
+	c.plan.Add("tag IAM role %s", aws.StringValue(input.RoleName))
+	return &iam.TagRoleOutput{}, nil
+}
+
+func (c *planIAM) CreatePolicy(input *iam.CreatePolicyInput) (*iam.CreatePolicyOutput, error) {
+	c.plan.Add("create IAM policy %s", aws.StringValue(input.PolicyName))
+	path := aws.StringValue(input.Path)
+	if path == "" {
+		path = "/"
+	}
+	return &iam.CreatePolicyOutput{Policy: &iam.Policy{
+		PolicyName: input.PolicyName,
+		Path:       aws.String(path),
+		Arn:        aws.String(fmt.Sprintf("arn:aws:iam::%s:policy%s%s", plannedAccountID, path, aws.StringValue(input.PolicyName))),
+	}}, nil
+}
+
+func (c *planIAM) DeletePolicy(input *iam.DeletePolicyInput) (*iam.DeletePolicyOutput, error) {
+	c.plan.Add("delete IAM policy %s", aws.StringValue(input.PolicyArn))
+	return &iam.DeletePolicyOutput{}, nil
+}
+
+func (c *planIAM) CreatePolicyVersion(input *iam.CreatePolicyVersionInput) (*iam.CreatePolicyVersionOutput, error) {
+	c.plan.Add("create default version of IAM policy %s", aws.StringValue(input.PolicyArn))
+	return &iam.CreatePolicyVersionOutput{}, nil
+}
+
+func (c *planIAM) DeletePolicyVersion(input *iam.DeletePolicyVersionInput) (*iam.DeletePolicyVersionOutput, error) {
+	c.plan.Add("delete version %s of IAM policy %s", aws.StringValue(input.VersionId), aws.StringValue(input.PolicyArn))
+	return &iam.DeletePolicyVersionOutput{}, nil
+}
+
+// NewPlanEKS returns an EKS which reads from c and records every
+// mutating call into the Plan instead of sending it to AWS
+func NewPlanEKS(c EKS, p *plan.Plan) EKS {
+	return &planEKS{EKS: c, plan: p}
+}
+
+type planEKS struct {
+	EKS
+	plan *plan.Plan
+}
+
+func (c *planEKS) CreateAccessEntry(input *eks.CreateAccessEntryInput) (*eks.CreateAccessEntryOutput, error) {
+	c.plan.Add("create access entry %s in cluster %s", aws.StringValue(input.PrincipalArn), aws.StringValue(input.ClusterName))
+	return &eks.CreateAccessEntryOutput{}, nil
+}
+
+func (c *planEKS) UpdateAccessEntry(input *eks.UpdateAccessEntryInput) (*eks.UpdateAccessEntryOutput, error) {
+	c.plan.Add("update access entry %s in cluster %s", aws.StringValue(input.PrincipalArn), aws.StringValue(input.ClusterName))
+	return &eks.UpdateAccessEntryOutput{}, nil
+}
+
+func (c *planEKS) DeleteAccessEntry(input *eks.DeleteAccessEntryInput) (*eks.DeleteAccessEntryOutput, error) {
+	c.plan.Add("delete access entry %s in cluster %s", aws.StringValue(input.PrincipalArn), aws.StringValue(input.ClusterName))
+	return &eks.DeleteAccessEntryOutput{}, nil
+}
+
+func (c *planEKS) AssociateAccessPolicy(input *eks.AssociateAccessPolicyInput) (*eks.AssociateAccessPolicyOutput, error) {
+	c.plan.Add("associate access policy %s to access entry %s in cluster %s",
+		aws.StringValue(input.PolicyArn), aws.StringValue(input.PrincipalArn), aws.StringValue(input.ClusterName))
+	return &eks.AssociateAccessPolicyOutput{}, nil
+}
+
+func (c *planEKS) DisassociateAccessPolicy(input *eks.DisassociateAccessPolicyInput) (*eks.DisassociateAccessPolicyOutput, error) {
+	c.plan.Add("disassociate access policy %s from access entry %s in cluster %s",
+		aws.StringValue(input.PolicyArn), aws.StringValue(input.PrincipalArn), aws.StringValue(input.ClusterName))
+	return &eks.DisassociateAccessPolicyOutput{}, nil
+}
+
+func (c *planEKS) CreatePodIdentityAssociation(input *eks.CreatePodIdentityAssociationInput) (*eks.CreatePodIdentityAssociationOutput, error) {
+	c.plan.Add("create pod identity association for %s/%s in cluster %s",
+		aws.StringValue(input.Namespace), aws.StringValue(input.ServiceAccount), aws.StringValue(input.ClusterName))
+	return &eks.CreatePodIdentityAssociationOutput{Association: &eks.PodIdentityAssociation{
+		ClusterName:    input.ClusterName,
+		Namespace:      input.Namespace,
+		ServiceAccount: input.ServiceAccount,
+		RoleArn:        input.RoleArn,
+	}}, nil
+}
+
+func (c *planEKS) UpdatePodIdentityAssociation(input *eks.UpdatePodIdentityAssociationInput) (*eks.UpdatePodIdentityAssociationOutput, error) {
+	c.plan.Add("update pod identity association %s in cluster %s", aws.StringValue(input.AssociationId), aws.StringValue(input.ClusterName))
+	return &eks.UpdatePodIdentityAssociationOutput{}, nil
+}
+
+func (c *planEKS) DeletePodIdentityAssociation(input *eks.DeletePodIdentityAssociationInput) (*eks.DeletePodIdentityAssociationOutput, error) {
+	c.plan.Add("delete pod identity association %s in cluster %s", aws.StringValue(input.AssociationId), aws.StringValue(input.ClusterName))
+	return &eks.DeletePodIdentityAssociationOutput{}, nil
+}
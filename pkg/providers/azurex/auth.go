@@ -2,10 +2,16 @@ package azurex
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"os"
 
 	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/util"
 )
 
 // GetEnvironment returns azure.Environment based on Config's Environment
@@ -18,6 +24,18 @@ func (c *Config) GetEnvironment() (azure.Environment, error) {
 
 // GetAuthorizer returns the autorest.Authorizer based on the available settings
 func (c *Config) GetAuthorizer() (autorest.Authorizer, error) {
+	switch v1alpha1.CredentialsSource(c.CredentialsSource) {
+	case v1alpha1.CredentialsSourceInjectedIdentity:
+		// the workload identity of the controller, else its managed identity
+		if c.FederatedTokenFile != "" {
+			return c.GetFederatedToken()
+		}
+		return c.GetMSI().Authorizer()
+	case v1alpha1.CredentialsSourceFilesystem:
+		// the client certificate mounted into the controller
+		config := auth.NewClientCertificateConfig(c.CredentialsPath, c.CertificatePassword, c.ClientID, c.TenantID)
+		return config.Authorizer()
+	}
 
 	//1. Client Credentials
 	if c, e := c.GetClientCredentials(); e == nil {
@@ -29,12 +47,17 @@ func (c *Config) GetAuthorizer() (autorest.Authorizer, error) {
 		return c.Authorizer()
 	}
 
-	//3. Username Password
+	//3. Federated Token
+	if c.FederatedTokenFile != "" {
+		return c.GetFederatedToken()
+	}
+
+	//4. Username Password
 	if c, e := c.GetUsernamePassword(); e == nil {
 		return c.Authorizer()
 	}
 
-	// 4. MSI
+	// 5. MSI
 	return c.GetMSI().Authorizer()
 }
 
@@ -86,6 +109,44 @@ func (c *Config) GetUsernamePassword() (*auth.UsernamePasswordConfig, error) {
 	return &config, nil
 }
 
+// GetFederatedToken creates an authorizer which exchanges the federated token of the workload identity,
+// e.g. the projected service account token, for a token of the client ID.
+func (c *Config) GetFederatedToken() (autorest.Authorizer, error) {
+	if c.ClientID == "" || c.TenantID == "" {
+		return nil, errors.New("missing client ID or tenant ID for federated token")
+	}
+	env, err := c.GetEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	oauthConfig, err := adal.NewOAuthConfig(util.DefaultString(c.AuthorityHost, env.ActiveDirectoryEndpoint), c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	secret := &federatedTokenSecret{path: c.FederatedTokenFile}
+	spt, err := adal.NewServicePrincipalTokenWithSecret(*oauthConfig, c.ClientID, env.ResourceManagerEndpoint, secret)
+	if err != nil {
+		return nil, err
+	}
+	return autorest.NewBearerAuthorizer(spt), nil
+}
+
+// federatedTokenSecret is the federated token of a file, read on every refresh as the token is rotated
+type federatedTokenSecret struct {
+	path string
+}
+
+// SetAuthenticationValues sets the federated token as the client assertion
+func (s *federatedTokenSecret) SetAuthenticationValues(_ *adal.ServicePrincipalToken, v *url.Values) error {
+	token, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("error reading federated token - %w", err)
+	}
+	v.Set("client_assertion", string(token))
+	v.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	return nil
+}
+
 // GetMSI creates a MSI config object from the available client ID.
 func (c *Config) GetMSI() *auth.MSIConfig {
 	config := auth.NewMSIConfig()
@@ -0,0 +1,73 @@
+package azurex
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAuthorizerFederatedToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "azure-identity-token")
+	testCases := []struct {
+		desc      string
+		config    map[string]any
+		federated bool
+		expectErr bool
+	}{
+		{
+			desc:      "The federated token of the properties",
+			config:    map[string]any{"tenantId": "tenant", "clientId": "client", "federatedTokenFile": path},
+			federated: true,
+		},
+		{
+			desc:      "The federated token of the injected identity",
+			config:    map[string]any{"tenantId": "tenant", "clientId": "client", "federatedTokenFile": path, "credentials_source": "InjectedIdentity"},
+			federated: true,
+		},
+		{
+			desc:   "The client secret takes precedence over the federated token",
+			config: map[string]any{"tenantId": "tenant", "clientId": "client", "clientSecret": "secret", "federatedTokenFile": path},
+		},
+		{
+			desc:      "The federated token requires a client ID",
+			config:    map[string]any{"tenantId": "tenant", "federatedTokenFile": path},
+			expectErr: true,
+		},
+	}
+	for _, testCase := range testCases {
+		c, err := New(WithConfigMap(testCase.config))
+		if testCase.expectErr {
+			assert.NotNil(t, err, testCase.desc)
+			continue
+		}
+		require.Nil(t, err, testCase.desc)
+		if !testCase.federated {
+			continue
+		}
+		authorizer, ok := c.GetAuthorizer().(*autorest.BearerAuthorizer)
+		require.True(t, ok, testCase.desc)
+		assert.NotNil(t, authorizer.TokenProvider(), testCase.desc)
+	}
+}
+
+func TestFederatedTokenSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "azure-identity-token")
+	secret := &federatedTokenSecret{path: path}
+
+	v := url.Values{}
+	assert.NotNil(t, secret.SetAuthenticationValues(nil, &v))
+
+	// the token is read on every refresh as it is rotated
+	for _, token := range []string{"first", "rotated"} {
+		require.Nil(t, os.WriteFile(path, []byte(token), 0o600))
+		v := url.Values{}
+		require.Nil(t, secret.SetAuthenticationValues(nil, &v))
+		assert.Equal(t, token, v.Get("client_assertion"))
+		assert.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", v.Get("client_assertion_type"))
+	}
+}
@@ -2,12 +2,15 @@ package azurex
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
 
 	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
 	"github.com/Azure/go-autorest/autorest/azure"
 	"github.com/gofrs/uuid"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
 	"github.com/invisibl-cloud/identity-manager/pkg/util"
 )
 
@@ -58,6 +61,9 @@ func WithEnv() Option {
 		x.config.ClientID = util.DefaultString(os.Getenv("AZURE_CLIENT_ID"), x.config.ClientID)
 		x.config.ClientSecret = util.DefaultString(os.Getenv("AZURE_CLIENT_SECRET"), x.config.ClientSecret)
 		x.config.Environment = util.DefaultString(os.Getenv("AZURE_ENVIRONMENT"), x.config.Environment)
+		// workload identity
+		x.config.FederatedTokenFile = util.DefaultString(os.Getenv("AZURE_FEDERATED_TOKEN_FILE"), x.config.FederatedTokenFile)
+		x.config.AuthorityHost = util.DefaultString(os.Getenv("AZURE_AUTHORITY_HOST"), x.config.AuthorityHost)
 		// custom config
 		x.config.Location = util.DefaultString(os.Getenv("AZURE_LOCATION"), x.config.Location)
 		x.config.ResourceGroup = util.DefaultString(os.Getenv("AZURE_RESOURCE_GROUP"), x.config.ResourceGroup)
@@ -93,6 +99,24 @@ func WithConfig(c *Config) Option {
 	}
 }
 
+// WithAuthorizer expects an authorizer, used
+// instead of the one of the config, and returns Option
+func WithAuthorizer(authorizer autorest.Authorizer) Option {
+	return func(x *Client) error {
+		x.authorizer = authorizer
+		return nil
+	}
+}
+
+// WithSender expects the sender of the
+// requests of the clients and returns Option
+func WithSender(sender autorest.Sender) Option {
+	return func(x *Client) error {
+		x.sender = sender
+		return nil
+	}
+}
+
 // New initializes Client with multiple Option
 func New(opts ...Option) (*Client, error) {
 	c := &Client{}
@@ -101,6 +125,9 @@ func New(opts ...Option) (*Client, error) {
 			return nil, err
 		}
 	}
+	if c.authorizer != nil {
+		return c, nil
+	}
 	authorizer, err := c.config.GetAuthorizer()
 	if err != nil {
 		return nil, err
@@ -113,6 +140,7 @@ func New(opts ...Option) (*Client, error) {
 type Client struct {
 	config     *Config
 	authorizer autorest.Authorizer
+	sender     autorest.Sender
 }
 
 // GetAuthorizer returns client's authorizer
@@ -120,6 +148,15 @@ func (x *Client) GetAuthorizer() autorest.Authorizer {
 	return x.authorizer
 }
 
+// Configure sets the authorizer, the sender and the user agent of an autorest client
+func (x *Client) Configure(c *autorest.Client) error {
+	c.Authorizer = x.authorizer
+	if x.sender != nil {
+		c.Sender = x.sender
+	}
+	return c.AddToUserAgent(UserAgent)
+}
+
 // GetConfig returns client's config
 func (x *Client) GetConfig() *Config {
 	return x.config
@@ -142,6 +179,12 @@ type Config struct {
 	Certificate         string `json:"certificate" yaml:"certificate"`
 	CertificatePath     string `json:"certificatePath" yaml:"certificatePath"`
 	CertificatePassword string `json:"certificatePassword" yaml:"certificatePassword"`
+	// Auth4, workload identity federation
+	FederatedTokenFile string `json:"federatedTokenFile" yaml:"federatedTokenFile"`
+	AuthorityHost      string `json:"authorityHost" yaml:"authorityHost"`
+	// Auth5, source of the credentials when not loaded from a secret
+	CredentialsSource string `json:"credentials_source" yaml:"credentials_source"`
+	CredentialsPath   string `json:"credentials_path" yaml:"credentials_path"`
 	// Defaults
 	ResourceGroup string `json:"resourceGroup" yaml:"resourceGroup"`
 	Location      string `json:"location" yaml:"location"`
@@ -199,6 +242,58 @@ func ToStringPtr(s string) *string {
 	return &s
 }
 
+// errorCategories maps the azure error codes to the error categories
+var errorCategories = map[string]types.ErrorCategory{
+	"AuthenticationFailed":          types.ErrorAuthFailed,
+	"ExpiredAuthenticationToken":    types.ErrorAuthFailed,
+	"InvalidAuthenticationToken":    types.ErrorAuthFailed,
+	"AuthorizationFailed":           types.ErrorPermissionDenied,
+	"LinkedAuthorizationFailed":     types.ErrorPermissionDenied,
+	"TooManyRequests":               types.ErrorThrottled,
+	"SubscriptionRequestsThrottled": types.ErrorThrottled,
+	"BadRequest":                    types.ErrorInvalidSpec,
+	"InvalidRequestContent":         types.ErrorInvalidSpec,
+	"InvalidResourceName":           types.ErrorInvalidSpec,
+	"PrincipalNotFound":             types.ErrorDependencyMissing,
+	"ResourceGroupNotFound":         types.ErrorDependencyMissing,
+	"RoleDefinitionDoesNotExist":    types.ErrorDependencyMissing,
+	"QuotaExceeded":                 types.ErrorQuotaExceeded,
+	"RoleAssignmentLimitExceeded":   types.ErrorQuotaExceeded,
+	"RoleDefinitionLimitExceeded":   types.ErrorQuotaExceeded,
+	"Conflict":                      types.ErrorConflict,
+	"RoleAssignmentExists":          types.ErrorConflict,
+}
+
+// ToError categorizes the azure error and keeps its request id, other errors are returned as is
+func ToError(err error) error {
+	var e *types.Error
+	var terr adal.TokenRefreshError
+	var derr autorest.DetailedError
+	switch {
+	case errors.As(err, &e):
+		return err
+	case errors.As(err, &terr):
+		return types.NewError(types.ErrorAuthFailed, err)
+	case !errors.As(err, &derr):
+		return err
+	}
+	e = types.NewError("", err)
+	var rerr *azure.RequestError
+	if errors.As(err, &rerr) {
+		if rerr.ServiceError != nil {
+			e.Category = errorCategories[rerr.ServiceError.Code]
+		}
+		e.RequestID = rerr.RequestID
+	}
+	if statusCode, ok := derr.StatusCode.(int); ok && e.Category == "" {
+		e.Category = types.HTTPErrorCategory(statusCode)
+	}
+	if e.RequestID == "" && derr.Response != nil {
+		e.RequestID = azure.ExtractRequestID(derr.Response)
+	}
+	return e
+}
+
 // IsNotFound returns a value indicating whether the given error represents that the resource was not found.
 func IsNotFound(err error) bool {
 	detailedError, ok := err.(autorest.DetailedError)
@@ -10,6 +10,7 @@ import (
 
 	"github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-01-01-preview/authorization"
 	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/invisibl-cloud/identity-manager/pkg/plan"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/azurex"
 )
 
@@ -20,6 +21,7 @@ type Client struct {
 	*azurex.Client
 	resourceGroup string
 	location      string
+	plan          *plan.Plan
 }
 
 // New returns the RBAC client
@@ -31,10 +33,15 @@ func New(p *azurex.Client) *Client {
 	}
 }
 
+// WithPlan records the changes into the plan instead of applying them, when p is not nil
+func (c *Client) WithPlan(p *plan.Plan) *Client {
+	c.plan = p
+	return c
+}
+
 func getRoleDefinitionsClient(p *azurex.Client) (authorization.RoleDefinitionsClient, error) {
 	c := authorization.NewRoleDefinitionsClient(p.GetConfig().SubscriptionID)
-	c.Authorizer = p.GetAuthorizer()
-	err := c.AddToUserAgent(azurex.UserAgent)
+	err := p.Configure(&c.Client)
 	if err != nil {
 		return authorization.RoleDefinitionsClient{}, err
 	}
@@ -43,8 +50,7 @@ func getRoleDefinitionsClient(p *azurex.Client) (authorization.RoleDefinitionsCl
 
 func getRoleAssignmentsClient(p *azurex.Client) (authorization.RoleAssignmentsClient, error) {
 	c := authorization.NewRoleAssignmentsClient(p.GetConfig().SubscriptionID)
-	c.Authorizer = p.GetAuthorizer()
-	err := c.AddToUserAgent(azurex.UserAgent)
+	err := p.Configure(&c.Client)
 	if err != nil {
 		return authorization.RoleAssignmentsClient{}, err
 	}
@@ -61,6 +67,10 @@ func (c Client) CreateOrUpdateRoleDefinition(ctx context.Context, id string, sco
 	if err != nil {
 		return err
 	}
+	if c.plan != nil {
+		c.plan.Add("create or update role definition %s on %s", to.String(prop.RoleName), scope)
+		return nil
+	}
 	_, err = rdc.CreateOrUpdate(ctx, scope, id, authorization.RoleDefinition{
 		RoleDefinitionProperties: &prop,
 	})
@@ -77,12 +87,20 @@ func (c Client) DeleteRoleDefinition(ctx context.Context, scope, id string) erro
 	if err != nil {
 		return err
 	}
+	if c.plan != nil {
+		c.plan.Add("delete role definition %s on %s", id, scope)
+		return nil
+	}
 	_, err = rdc.Delete(ctx, scope, id)
 	return err
 }
 
 // ListRoleAssignments gets all role assignments for the principal
 func (c Client) ListRoleAssignments(ctx context.Context, principalID string) ([]*authorization.RoleAssignment, error) {
+	// a planned identity has no principal yet
+	if principalID == "" {
+		return nil, nil
+	}
 	rac, err := getRoleAssignmentsClient(c.Client)
 	if err != nil {
 		return nil, err
@@ -138,6 +156,10 @@ func (c Client) DeleteRoleAssignment(ctx context.Context, id string) error {
 		return err
 	}
 
+	if c.plan != nil {
+		c.plan.Add("delete role assignment %s", id)
+		return nil
+	}
 	_, err = rac.DeleteByID(ctx, id)
 	return err
 }
@@ -153,6 +175,10 @@ func (c Client) CreateRoleAssignment(ctx context.Context, id string, principalID
 		return "", err
 	}
 
+	if c.plan != nil {
+		c.plan.Add("create role assignment %s of role %s on %s", id, roleDefinitionID, scope)
+		return "", nil
+	}
 	p := authorization.RoleAssignmentCreateParameters{
 		RoleAssignmentProperties: &authorization.RoleAssignmentProperties{
 			RoleDefinitionID: azurex.ToStringPtr(roleDefinitionID),
@@ -2,8 +2,10 @@ package msi
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/msi/mgmt/msi"
+	"github.com/invisibl-cloud/identity-manager/pkg/plan"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/azurex"
 )
 
@@ -12,6 +14,7 @@ type Client struct {
 	*azurex.Client
 	resourceGroup string
 	location      string
+	plan          *plan.Plan
 }
 
 // New expects *azurex.Client and returns *msi.Client
@@ -23,10 +26,15 @@ func New(p *azurex.Client) *Client {
 	}
 }
 
+// WithPlan records the changes into the plan instead of applying them, when p is not nil
+func (c *Client) WithPlan(p *plan.Plan) *Client {
+	c.plan = p
+	return c
+}
+
 func getUserAssignedIdentitiesClient(p *azurex.Client) (msi.UserAssignedIdentitiesClient, error) {
 	c := msi.NewUserAssignedIdentitiesClient(p.GetConfig().SubscriptionID)
-	c.Authorizer = p.GetAuthorizer()
-	err := c.AddToUserAgent(azurex.UserAgent)
+	err := p.Configure(&c.Client)
 	if err != nil {
 		return msi.UserAssignedIdentitiesClient{}, err
 	}
@@ -53,6 +61,10 @@ func (c *Client) CreateOrUpdate(ctx context.Context, resourceName string, tags m
 		return nil, err
 	}
 
+	if c.plan != nil {
+		return c.planCreateOrUpdate(ctx, uai, resourceName)
+	}
+
 	id, err := uai.CreateOrUpdate(ctx, c.resourceGroup, resourceName, msi.Identity{
 		Location: &c.location,
 		Tags:     tags,
@@ -60,7 +72,31 @@ func (c *Client) CreateOrUpdate(ctx context.Context, resourceName string, tags m
 	if err != nil {
 		return nil, err
 	}
+	return c.toIdentity(id), nil
+}
 
+// planCreateOrUpdate returns the existing identity, or else records its creation and returns
+// an identity without principal and client ids
+func (c *Client) planCreateOrUpdate(ctx context.Context, uai msi.UserAssignedIdentitiesClient, resourceName string) (*Identity, error) {
+	id, err := uai.Get(ctx, c.resourceGroup, resourceName)
+	if err == nil {
+		return c.toIdentity(id), nil
+	}
+	if !azurex.IsNotFound(err) {
+		return nil, err
+	}
+	c.plan.Add("create managed identity %s in resource group %s", resourceName, c.resourceGroup)
+	return &Identity{
+		Name: resourceName,
+		ID: fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ManagedIdentity/userAssignedIdentities/%s",
+			c.Client.GetConfig().SubscriptionID, c.resourceGroup, resourceName),
+		Location:       c.location,
+		ResourceGroup:  c.resourceGroup,
+		SubscriptionID: c.Client.GetConfig().SubscriptionID,
+	}, nil
+}
+
+func (c *Client) toIdentity(id msi.Identity) *Identity {
 	return &Identity{
 		Name:           azurex.ToString(id.Name),
 		ID:             azurex.ToString(id.ID),
@@ -71,7 +107,57 @@ func (c *Client) CreateOrUpdate(ctx context.Context, resourceName string, tags m
 		Location:       c.location,
 		ResourceGroup:  c.resourceGroup,
 		SubscriptionID: c.Client.GetConfig().SubscriptionID,
-	}, nil
+	}
+}
+
+// Get returns the identity
+func (c *Client) Get(ctx context.Context, resourceName string) (*Identity, error) {
+	uai, err := getUserAssignedIdentitiesClient(c.Client)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uai.Get(ctx, c.resourceGroup, resourceName)
+	if err != nil {
+		return nil, err
+	}
+	return c.toIdentity(id), nil
+}
+
+// List returns the identities of the resource group
+func (c *Client) List(ctx context.Context) ([]*Identity, error) {
+	uai, err := getUserAssignedIdentitiesClient(c.Client)
+	if err != nil {
+		return nil, err
+	}
+	it, err := uai.ListByResourceGroupComplete(ctx, c.resourceGroup)
+	if err != nil {
+		return nil, err
+	}
+	ids := []*Identity{}
+	for it.NotDone() {
+		ids = append(ids, c.toIdentity(it.Value()))
+		err = it.NextWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// Exists checks whether the identity exists
+func (c *Client) Exists(ctx context.Context, resourceName string) (bool, error) {
+	uai, err := getUserAssignedIdentitiesClient(c.Client)
+	if err != nil {
+		return false, err
+	}
+	_, err = uai.Get(ctx, c.resourceGroup, resourceName)
+	if err != nil {
+		if azurex.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
 }
 
 // EnsureDelete ensures deletion of the identity
@@ -88,6 +174,10 @@ func (c *Client) EnsureDelete(ctx context.Context, resourceName string) (bool, e
 		}
 		return false, err
 	}
+	if c.plan != nil {
+		c.plan.Add("delete managed identity %s in resource group %s", resourceName, c.resourceGroup)
+		return true, nil
+	}
 	_, err = uai.Delete(ctx, c.resourceGroup, resourceName)
 	if err != nil {
 		return false, err
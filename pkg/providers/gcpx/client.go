@@ -3,19 +3,38 @@ package gcpx
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"strings"
 
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
 	"github.com/invisibl-cloud/identity-manager/pkg/util"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/googleapi"
+	"google.golang.org/api/impersonate"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// MaxAccountIDLength is the max length of a service account id
+const MaxAccountIDLength = 30
+
+// ServiceAccountName returns the service account id of a WorkloadIdentity, names longer than
+// MaxAccountIDLength are shortened with the uid of the WorkloadIdentity
+func ServiceAccountName(res *v1alpha1.WorkloadIdentity) string {
+	name := util.DefaultString(res.Spec.Name, res.Name)
+	uid := strings.ReplaceAll(string(res.UID), "-", "")
+	if len(name) > MaxAccountIDLength && len(uid) >= MaxAccountIDLength {
+		name = name[0:20] + uid[20:30]
+	}
+	return name
+}
+
 // Option for Client
 type Option func(*Client) error
 
@@ -72,6 +91,14 @@ func WithConfig(c *Config) Option {
 	}
 }
 
+// WithClientOptions is an option to add client options to the clients of the services, e.g. their endpoint
+func WithClientOptions(opts ...option.ClientOption) Option {
+	return func(x *Client) error {
+		x.clientOptions = append(x.clientOptions, opts...)
+		return nil
+	}
+}
+
 // WithEnv is an option to configure Client Config via env variables
 func WithEnv() Option {
 	return WithEnvPrefix("")
@@ -88,6 +115,7 @@ func WithEnvPrefix(prefix string) Option {
 		x.config.Location = util.GetEnvString(x.config.Location, "GOOGLE_REGION", "GCLOUD_REGION", "CLOUDSDK_COMPUTE_REGION")
 		x.config.Zone = util.GetEnvString(x.config.Zone, "GOOGLE_ZONE", "GCLOUD_ZONE", "CLOUDSDK_COMPUTE_ZONE")
 		x.config.CredentialsFile = util.GetEnvString(x.config.CredentialsFile, "GOOGLE_APPLICATION_CREDENTIALS")
+		x.config.ImpersonateServiceAccount = util.GetEnvString(x.config.ImpersonateServiceAccount, "GOOGLE_IMPERSONATE_SERVICE_ACCOUNT", "CLOUDSDK_AUTH_IMPERSONATE_SERVICE_ACCOUNT")
 		return nil
 	}
 }
@@ -113,9 +141,40 @@ func New(opts ...Option) (*Client, error) {
 		}
 	}
 
-	credsJSON := c.config.Credentials
-	if c.config.CredentialsFile != "" {
-		dcreds, err := os.ReadFile(c.config.CredentialsFile)
+	// the impersonated service account is granted the scopes, its caller impersonates it
+	baseScopes := scopes
+	if c.config.ImpersonateServiceAccount != "" {
+		baseScopes = []string{scopeCloudPlatform}
+	}
+	creds, err := c.baseCredentials(baseScopes)
+	if err != nil {
+		return nil, err
+	}
+	if c.config.ImpersonateServiceAccount != "" {
+		creds, err = c.impersonatedCredentials(context.Background(), creds, scopes, option.WithTokenSource(creds.TokenSource))
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.credentials = creds
+
+	return c, nil
+}
+
+// baseCredentials returns the credentials of the source, of the key or of the default credentials
+func (x *Client) baseCredentials(scopes []string) (*google.Credentials, error) {
+	switch v1alpha1.CredentialsSource(x.config.CredentialsSource) {
+	case v1alpha1.CredentialsSourceInjectedIdentity:
+		// the GKE workload identity of the controller, from the metadata server
+		return &google.Credentials{ProjectID: x.config.Project, TokenSource: google.ComputeTokenSource("", scopes...)}, nil
+	case v1alpha1.CredentialsSourceFilesystem:
+		// the key or the external account configuration mounted into the controller
+		x.config.CredentialsFile = x.config.CredentialsPath
+	}
+
+	credsJSON := x.config.Credentials
+	if x.config.CredentialsFile != "" {
+		dcreds, err := os.ReadFile(x.config.CredentialsFile)
 		if err != nil {
 			return nil, err
 		}
@@ -131,15 +190,34 @@ func New(opts ...Option) (*Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error getting credentials - %w", err)
 	}
-	c.credentials = creds
+	return creds, nil
+}
 
-	return c, nil
+// impersonatedCredentials returns the short-lived credentials of the impersonated service account,
+// through the delegates chain, refreshed with the base credentials
+func (x *Client) impersonatedCredentials(ctx context.Context, base *google.Credentials, scopes []string, opts ...option.ClientOption) (*google.Credentials, error) {
+	config := impersonate.CredentialsConfig{
+		TargetPrincipal: x.config.ImpersonateServiceAccount,
+		Scopes:          scopes,
+	}
+	for _, delegate := range strings.Split(x.config.Delegates, ",") {
+		delegate = strings.TrimSpace(delegate)
+		if delegate != "" {
+			config.Delegates = append(config.Delegates, delegate)
+		}
+	}
+	ts, err := impersonate.CredentialsTokenSource(ctx, config, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error impersonating service account %s - %w", x.config.ImpersonateServiceAccount, err)
+	}
+	return &google.Credentials{ProjectID: util.DefaultString(x.config.Project, base.ProjectID), TokenSource: ts}, nil
 }
 
 // Client holds gcp client
 type Client struct {
-	config      *Config
-	credentials *google.Credentials
+	config        *Config
+	credentials   *google.Credentials
+	clientOptions []option.ClientOption
 }
 
 // GetCredentials returns google credentials
@@ -147,6 +225,11 @@ func (x *Client) GetCredentials() *google.Credentials {
 	return x.credentials
 }
 
+// GetClientOptions returns the options of the clients of the services, with the credentials
+func (x *Client) GetClientOptions() []option.ClientOption {
+	return append([]option.ClientOption{option.WithCredentials(x.credentials)}, x.clientOptions...)
+}
+
 // GetTokenOption returns creds as ClientOption
 func (x *Client) GetTokenOption(ctx context.Context) option.ClientOption {
 	return option.WithHTTPClient(oauth2.NewClient(ctx, x.credentials.TokenSource))
@@ -167,6 +250,62 @@ type Config struct {
 	Endpoints       map[string]string `json:"endpoints" yaml:"endpoints"`
 	CredentialsFile string            `json:"credentials_file" yaml:"credentials_file"`
 	Credentials     string            `json:"credentials" yaml:"credentials"`
+	// source of the credentials, when not loaded from a secret
+	CredentialsSource string `json:"credentials_source" yaml:"credentials_source"`
+	CredentialsPath   string `json:"credentials_path" yaml:"credentials_path"`
+	// service account impersonated with the credentials, through the comma separated delegates
+	ImpersonateServiceAccount string `json:"impersonate_service_account" yaml:"impersonate_service_account"`
+	Delegates                 string `json:"delegates" yaml:"delegates"`
+}
+
+// ToError categorizes the gcp error and keeps its request id, other errors are returned as is
+func ToError(err error) error {
+	var e *types.Error
+	var serr interface{ GRPCStatus() *status.Status }
+	var gerr *googleapi.Error
+	switch {
+	case errors.As(err, &e):
+		return err
+	case errors.As(err, &serr):
+		st := serr.GRPCStatus()
+		e = types.NewError(grpcErrorCategory(st), err)
+		for _, detail := range st.Details() {
+			// errdetails.RequestInfo
+			if info, ok := detail.(interface{ GetRequestId() string }); ok {
+				e.RequestID = info.GetRequestId()
+			}
+		}
+		return e
+	case errors.As(err, &gerr):
+		category := types.HTTPErrorCategory(gerr.Code)
+		if gerr.Code == http.StatusTooManyRequests && strings.Contains(gerr.Message, "quota") {
+			category = types.ErrorQuotaExceeded
+		}
+		return types.NewError(category, err)
+	}
+	return err
+}
+
+func grpcErrorCategory(st *status.Status) types.ErrorCategory {
+	switch st.Code() {
+	case codes.Unauthenticated:
+		return types.ErrorAuthFailed
+	case codes.PermissionDenied:
+		return types.ErrorPermissionDenied
+	case codes.ResourceExhausted:
+		// rate limits and quotas are both exhausted resources
+		if strings.Contains(strings.ToLower(st.Message()), "quota") {
+			return types.ErrorQuotaExceeded
+		}
+		return types.ErrorThrottled
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return types.ErrorInvalidSpec
+	case codes.NotFound:
+		return types.ErrorDependencyMissing
+	case codes.AlreadyExists, codes.Aborted:
+		return types.ErrorConflict
+	}
+	return ""
 }
 
 // IsNotFound returns true if err is IsNotFound
@@ -0,0 +1,58 @@
+package gcpx
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestImpersonatedCredentials(t *testing.T) {
+	target := "admin@customer-project.iam.gserviceaccount.com"
+	var requests []*http.Request
+	var bodies []map[string]any
+	httpClient := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body := map[string]any{}
+		require.Nil(t, json.NewDecoder(req.Body).Decode(&body))
+		requests = append(requests, req)
+		bodies = append(bodies, body)
+		resp := `{"accessToken":"impersonated","expireTime":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(resp))}, nil
+	})}
+
+	x := &Client{config: &Config{
+		ImpersonateServiceAccount: target,
+		Delegates:                 "hub@controller-project.iam.gserviceaccount.com, ",
+	}}
+	base := &google.Credentials{ProjectID: "controller-project", TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "base"})}
+	creds, err := x.impersonatedCredentials(context.Background(), base, []string{scopeCloudPlatform}, option.WithHTTPClient(httpClient))
+	require.Nil(t, err)
+	// the project of the base credentials is the default
+	assert.Equal(t, "controller-project", creds.ProjectID)
+
+	token, err := creds.TokenSource.Token()
+	require.Nil(t, err)
+	assert.Equal(t, "impersonated", token.AccessToken)
+	// the token is reused until it expires
+	_, err = creds.TokenSource.Token()
+	require.Nil(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/v1/projects/-/serviceAccounts/"+target+":generateAccessToken", requests[0].URL.Path)
+	assert.Equal(t, []any{"projects/-/serviceAccounts/hub@controller-project.iam.gserviceaccount.com"}, bodies[0]["delegates"])
+	assert.Equal(t, []any{scopeCloudPlatform}, bodies[0]["scope"])
+}
@@ -3,6 +3,7 @@ package iam
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"cloud.google.com/go/iam"
 	iamadminv1 "cloud.google.com/go/iam/admin/apiv1"
@@ -10,16 +11,22 @@ import (
 	"cloud.google.com/go/iam/apiv1/iampb"
 	resourcemanager "cloud.google.com/go/resourcemanager/apiv3"
 	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/drift"
+	"github.com/invisibl-cloud/identity-manager/pkg/plan"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/gcpx"
 	"github.com/invisibl-cloud/identity-manager/pkg/util"
-	"google.golang.org/api/option"
 )
 
+// workloadIdentityUserRole allows kubernetes service accounts to impersonate the service account
+const workloadIdentityUserRole = "roles/iam.workloadIdentityUser"
+
 // Client for IAM
 type Client struct {
 	Client   *gcpx.Client
 	location string
 	project  string
+	plan     *plan.Plan
+	drift    *drift.Report
 }
 
 // New creates new iam client
@@ -31,12 +38,114 @@ func New(p *gcpx.Client) *Client {
 	}
 }
 
-// EnsureServiceAccountWithRoles makes sure SA is created or updated for desired state
+// WithPlan records the changes into the plan instead of applying them, when p is not nil
+func (x *Client) WithPlan(p *plan.Plan) *Client {
+	x.plan = p
+	return x
+}
+
+// WithDrift sets the report of the drift reverted while syncing, nil when the drift is not reported
+func (x *Client) WithDrift(report *drift.Report) *Client {
+	x.drift = report
+	return x
+}
+
+// ServiceAccountExists checks whether the service account exists
+func (x *Client) ServiceAccountExists(ctx context.Context, name string) (bool, error) {
+	iamSvc, err := iamadminv1.NewIamClient(ctx, x.Client.GetClientOptions()...)
+	if err != nil {
+		return false, fmt.Errorf("error creating iam admin client - %w", err)
+	}
+	accountID := fmt.Sprintf("%s@%s.iam.gserviceaccount.com", name, x.project)
+	_, err = iamSvc.GetServiceAccount(ctx, &adminpb.GetServiceAccountRequest{
+		Name: fmt.Sprintf("projects/%s/serviceAccounts/%s", x.project, accountID),
+	})
+	if err != nil {
+		if gcpx.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error getting sa %s - %w", accountID, err)
+	}
+	return true, nil
+}
+
+// CheckWorkloadIdentityUser checks whether the service account exists, and the first kubernetes service account
+// has the workloadIdentityUser role on it, as granted by EnsureServiceAccountWithRoles
+func (x *Client) CheckWorkloadIdentityUser(ctx context.Context, name string, ns string, sas []*v1alpha1.ServiceAccount) error {
+	iamSvc, err := iamadminv1.NewIamClient(ctx, x.Client.GetClientOptions()...)
+	if err != nil {
+		return fmt.Errorf("error creating iam admin client - %w", err)
+	}
+	accountID := fmt.Sprintf("%s@%s.iam.gserviceaccount.com", name, x.project)
+	policy, err := iamSvc.GetIamPolicy(ctx, &iampb.GetIamPolicyRequest{
+		Resource: fmt.Sprintf("projects/%s/serviceAccounts/%s", x.project, accountID),
+	})
+	if err != nil {
+		if gcpx.IsNotFound(err) {
+			return fmt.Errorf("sa %s not found", accountID)
+		}
+		return fmt.Errorf("error getting sa iam policy %s - %w", accountID, err)
+	}
+	if len(sas) == 0 {
+		return nil
+	}
+	member := x.workloadIdentityMember(ns, sas[0])
+	if !policy.HasRole(member, workloadIdentityUserRole) {
+		return fmt.Errorf("%s does not have %s on sa %s", member, workloadIdentityUserRole, accountID)
+	}
+	return nil
+}
+
+// workloadIdentityMember returns the workload identity member of the kubernetes service account
+func (x *Client) workloadIdentityMember(ns string, sa *v1alpha1.ServiceAccount) string {
+	return fmt.Sprintf("serviceAccount:%s.svc.id.goog[%s/%s]", x.project, util.DefaultString(sa.Namespace, ns), sa.Name)
+}
+
+// ObserveServiceAccount reads the service account and returns its email and its roles on the scope, the project when empty
+func (x *Client) ObserveServiceAccount(ctx context.Context, name string, scope string) (string, []string, error) {
+	iamSvc, err := iamadminv1.NewIamClient(ctx, x.Client.GetClientOptions()...)
+	if err != nil {
+		return "", nil, fmt.Errorf("error creating iam admin client - %w", err)
+	}
+	accountID := fmt.Sprintf("%s@%s.iam.gserviceaccount.com", name, x.project)
+	obj, err := iamSvc.GetServiceAccount(ctx, &adminpb.GetServiceAccountRequest{
+		Name: fmt.Sprintf("projects/%s/serviceAccounts/%s", x.project, accountID),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting sa %s - %w", accountID, err)
+	}
+	projClient, err := resourcemanager.NewProjectsClient(ctx, x.Client.GetClientOptions()...)
+	if err != nil {
+		return "", nil, fmt.Errorf("error creating new projects client %s - %w", accountID, err)
+	}
+	if scope == "" {
+		scope = "projects/" + x.project
+	}
+	policy, err := projClient.GetIamPolicy(ctx, &iampb.GetIamPolicyRequest{
+		Resource: scope,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting project iam policy %s - %w", accountID, err)
+	}
+	px := &iam.Policy{InternalProto: policy}
+	member := fmt.Sprintf("serviceAccount:%s", obj.Email)
+	roles := []string{}
+	for _, role := range px.Roles() {
+		if px.HasRole(member, role) {
+			roles = append(roles, string(role))
+		}
+	}
+	sort.Strings(roles)
+	return obj.Email, roles, nil
+}
+
+// EnsureServiceAccountWithRoles makes sure SA is created or updated for desired state.
+// It returns the account id once the SA exists, even when its roles could not be granted.
 func (x *Client) EnsureServiceAccountWithRoles(ctx context.Context, name string, ns string, sas []*v1alpha1.ServiceAccount, displayName string, desc string, roles []string, scope string) (string, error) {
 	accountID := fmt.Sprintf("%s@%s.iam.gserviceaccount.com", name, x.project)
 	err := x.createOrUpdateServiceAccount(ctx, accountID, name, ns, sas, displayName, desc)
 	if err != nil {
-		return accountID, err
+		return "", err
 	}
 	err = x.ensureServiceAccountRoles(ctx, accountID, roles, scope)
 	if err != nil {
@@ -47,7 +156,7 @@ func (x *Client) EnsureServiceAccountWithRoles(ctx context.Context, name string,
 
 func (x *Client) createOrUpdateServiceAccount(ctx context.Context, accountID string, name string, ns string, sas []*v1alpha1.ServiceAccount, displayName string, desc string) error {
 	rname := fmt.Sprintf("projects/%s/serviceAccounts/%s", x.project, accountID)
-	iamSvc, err := iamadminv1.NewIamClient(ctx, option.WithCredentials(x.Client.GetCredentials()))
+	iamSvc, err := iamadminv1.NewIamClient(ctx, x.Client.GetClientOptions()...)
 	if err != nil {
 		return fmt.Errorf("error creating iam admin client - %w", err)
 	}
@@ -61,6 +170,10 @@ func (x *Client) createOrUpdateServiceAccount(ctx context.Context, accountID str
 			return fmt.Errorf("error getting sa %s - %w", accountID, err)
 		}
 	}
+	if isNotFound && x.plan != nil {
+		x.plan.Add("create service account %s", accountID)
+		return nil
+	}
 	if isNotFound {
 		// accountId: must be 6-30 characters long, and match the regular expression `[a-z]([-a-z0-9]*[a-z0-9]
 		_, err = iamSvc.CreateServiceAccount(ctx, &adminpb.CreateServiceAccountRequest{
@@ -78,13 +191,18 @@ func (x *Client) createOrUpdateServiceAccount(ctx context.Context, accountID str
 	}
 	// update
 	if obj.DisplayName != displayName || obj.Description != desc {
-		_, err = iamSvc.UpdateServiceAccount(ctx, &adminpb.ServiceAccount{
-			Name:        rname,
-			DisplayName: displayName,
-			Description: desc,
-		})
-		if err != nil {
-			return fmt.Errorf("error updating sa %s - %w", accountID, err)
+		x.drift.Add(drift.TypeServiceAccount, accountID, v1alpha1.DriftChangeModified)
+		if x.plan != nil {
+			x.plan.Add("update service account %s", accountID)
+		} else {
+			_, err = iamSvc.UpdateServiceAccount(ctx, &adminpb.ServiceAccount{
+				Name:        rname,
+				DisplayName: displayName,
+				Description: desc,
+			})
+			if err != nil {
+				return fmt.Errorf("error updating sa %s - %w", accountID, err)
+			}
 		}
 	}
 	// TODO: better.
@@ -97,9 +215,7 @@ func (x *Client) createOrUpdateServiceAccount(ctx context.Context, accountID str
 	if err != nil {
 		return fmt.Errorf("error getting sa iam policy %s - %w", accountID, err)
 	}
-	if x.ensurePolicy(ctx, policy.InternalProto,
-		fmt.Sprintf("serviceAccount:%s.svc.id.goog[%s/%s]", x.project, util.DefaultString(sas[0].Namespace, ns), sas[0].Name),
-		[]string{"roles/iam.workloadIdentityUser"}) {
+	if x.ensurePolicy(ctx, rname, policy.InternalProto, x.workloadIdentityMember(ns, sas[0]), []string{workloadIdentityUserRole}) {
 		_, err = iamSvc.SetIamPolicy(ctx, &iamadminv1.SetIamPolicyRequest{
 			Resource: rname,
 			Policy:   policy,
@@ -112,7 +228,7 @@ func (x *Client) createOrUpdateServiceAccount(ctx context.Context, accountID str
 }
 
 func (x *Client) ensureServiceAccountRoles(ctx context.Context, saName string, roles []string, scope string) error {
-	projClient, err := resourcemanager.NewProjectsClient(ctx, option.WithCredentials(x.Client.GetCredentials()))
+	projClient, err := resourcemanager.NewProjectsClient(ctx, x.Client.GetClientOptions()...)
 	if err != nil {
 		return fmt.Errorf("error creating new projects client %s - %w", saName, err)
 	}
@@ -125,7 +241,7 @@ func (x *Client) ensureServiceAccountRoles(ctx context.Context, saName string, r
 	if err != nil {
 		return fmt.Errorf("error getting project iam policy %s - %w", saName, err)
 	}
-	if x.ensurePolicy(ctx, policy, fmt.Sprintf("serviceAccount:%s", saName), roles) {
+	if x.ensurePolicy(ctx, scope, policy, fmt.Sprintf("serviceAccount:%s", saName), roles) {
 		_, err = projClient.SetIamPolicy(ctx, &iampb.SetIamPolicyRequest{
 			Resource: scope,
 			Policy:   policy,
@@ -137,7 +253,9 @@ func (x *Client) ensureServiceAccountRoles(ctx context.Context, saName string, r
 	return nil
 }
 
-func (x *Client) ensurePolicy(ctx context.Context, policy *iampb.Policy, member string, roles []string) bool {
+// ensurePolicy updates the bindings of the member in the policy of the resource, and returns
+// whether the policy must be set. In dry-run mode, the binding changes are recorded instead.
+func (x *Client) ensurePolicy(ctx context.Context, resource string, policy *iampb.Policy, member string, roles []string) bool {
 	px := &iam.Policy{InternalProto: policy}
 	existingRoles := []string{}
 	for _, role := range px.Roles() {
@@ -146,6 +264,21 @@ func (x *Client) ensurePolicy(ctx context.Context, policy *iampb.Policy, member
 		}
 	}
 	d := util.FindSyncSteps(existingRoles, roles)
+	for _, role := range d.Add {
+		x.drift.Add(drift.TypeBinding, role+" on "+resource, v1alpha1.DriftChangeRemoved)
+	}
+	for _, role := range d.Delete {
+		x.drift.Add(drift.TypeBinding, role+" on "+resource, v1alpha1.DriftChangeAdded)
+	}
+	if x.plan != nil {
+		for _, role := range d.Add {
+			x.plan.Add("grant %s to %s on %s", role, member, resource)
+		}
+		for _, role := range d.Delete {
+			x.plan.Add("revoke %s from %s on %s", role, member, resource)
+		}
+		return false
+	}
 	for _, role := range d.Add {
 		px.Add(member, iam.RoleName(role))
 	}
@@ -161,10 +294,14 @@ func (x *Client) DeleteServiceAccount(ctx context.Context, accountID string) err
 		return nil
 	}
 	rname := fmt.Sprintf("projects/%s/serviceAccounts/%s", x.project, accountID)
-	iamSvc, err := iamadminv1.NewIamClient(ctx, option.WithCredentials(x.Client.GetCredentials()))
+	iamSvc, err := iamadminv1.NewIamClient(ctx, x.Client.GetClientOptions()...)
 	if err != nil {
 		return err
 	}
+	if x.plan != nil {
+		x.plan.Add("delete service account %s", accountID)
+		return nil
+	}
 	err = iamSvc.DeleteServiceAccount(ctx, &adminpb.DeleteServiceAccountRequest{
 		Name: rname,
 	})
@@ -174,5 +311,5 @@ func (x *Client) DeleteServiceAccount(ctx context.Context, accountID string) err
 		}
 		return fmt.Errorf("error deleting sa %s - %w", accountID, err)
 	}
-	return fmt.Errorf("deleting %s", accountID)
+	return nil
 }
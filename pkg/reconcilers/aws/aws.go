@@ -3,19 +3,23 @@ package aws
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eks"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/sts"
 
 	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
 	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+	"github.com/invisibl-cloud/identity-manager/pkg/drift"
 	"github.com/invisibl-cloud/identity-manager/pkg/options"
+	"github.com/invisibl-cloud/identity-manager/pkg/plan"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx"
+	eksc "github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/eks"
 	iamc "github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/iam"
 	"github.com/invisibl-cloud/identity-manager/pkg/reconcilers"
+	imtypes "github.com/invisibl-cloud/identity-manager/pkg/types"
 	"github.com/invisibl-cloud/identity-manager/pkg/util"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -45,6 +49,7 @@ type RoleReconciler struct {
 	res     *v1alpha1.WorkloadIdentity
 	// internal
 	iamClient *iamc.Client
+	eksClient *eksc.Client
 }
 
 // NewReconciler expectes reconciler base and workload identity resource
@@ -70,46 +75,101 @@ func (r *RoleReconciler) Prepare(ctx context.Context) error {
 		return err
 	}
 
-	iamC := iam.New(sess)
+	var iamC awsx.IAM = iam.New(sess)
+	var eksC awsx.EKS = eks.New(sess)
 	stsC := sts.New(sess)
+	// in dry-run mode, the changes are recorded into the plan instead of being applied
+	if p := plan.FromContext(ctx); p != nil {
+		iamC = awsx.NewPlanIAM(iamC, p)
+		eksC = awsx.NewPlanEKS(eksC, p)
+		r.Client = plan.NewClient(r.Client, p)
+	}
 
 	iamClient, err := iamc.New(iamC, stsC, r.res, r.options)
 	if err != nil {
 		return err
 	}
-	r.iamClient = iamClient
+	documents, err := r.getPolicyDocuments(ctx)
+	if err != nil {
+		return err
+	}
+	region, clusterName, oidcProvider := aws.StringValue(sess.Config.Region), r.clusterName(conf), r.oidcProvider(conf)
+	// changes of the documents, the template values or the options are not drift
+	err = drift.FromContext(ctx).SetInputs(documents, region, clusterName, oidcProvider, r.options)
+	if err != nil {
+		return err
+	}
+	r.iamClient = iamClient.WithOIDCProvider(oidcProvider).
+		WithDrift(drift.FromContext(ctx)).
+		WithAdopt(reconcilers.IsAdopt(r.res)).
+		WithPolicyDocuments(documents).
+		WithTemplateValues(region, clusterName)
+
+	if r.needsEKSClient() {
+		clusterName := ""
+		if r.res.Spec.AWS.PodIdentity != nil {
+			clusterName = r.res.Spec.AWS.PodIdentity.ClusterName
+		}
+		r.eksClient = eksc.New(eksC, clusterName, r.res.Namespace+"/"+r.res.Name)
+	}
 	return nil
 }
 
+// getConfig loads the credentials, the name prefix and the tags of the provider config apply to the options
 func (r *RoleReconciler) getConfig(ctx context.Context) (conf awsx.Config, err error) {
-	creds := r.res.Spec.Credentials
-	if creds == nil {
-		return
-	}
-	switch creds.Source {
-	case v1alpha1.CredentialsSourceSecret:
-		if creds.SecretRef == nil {
-			err = fmt.Errorf("missing secretRef for credentials")
-			return
-		}
-		secret := &corev1.Secret{}
-		secret.Name = util.DefaultString(creds.SecretRef.Name, r.res.Name)
-		secret.Namespace = util.DefaultString(creds.SecretRef.Namespace, r.res.Namespace)
-		err = r.Get(ctx, client.ObjectKeyFromObject(secret), secret)
-		if err != nil {
-			return
-		}
-		for k, v := range creds.Properties {
-			secret.Data[k] = []byte(v)
-		}
-		conf = awsx.NewConfig(secret.Data)
+	creds, err := reconcilers.LoadCredentials(ctx, r.Client, r.res, r.options)
+	if err != nil {
 		return
 	}
+	r.options = creds.Options(r.options)
+	conf = awsx.NewConfig(creds.Bytes())
 	return
 }
 
+// ValidateCredentials checks that the credentials authenticate, with the caller identity
+func ValidateCredentials(ctx context.Context, creds *reconcilers.Credentials) error {
+	sess, err := awsx.NewSession(awsx.NewConfig(creds.Bytes()))
+	if err != nil {
+		return err
+	}
+	_, err = sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	return awsx.ToError(err)
+}
+
+// oidcProvider returns the OIDC provider of the credentials, or else of the manager options
+func (r *RoleReconciler) oidcProvider(conf awsx.Config) string {
+	if conf.OIDCProvider != "" || r.options == nil || r.options.AWS == nil {
+		return conf.OIDCProvider
+	}
+	return r.options.AWS.OIDCProvider
+}
+
+// clusterName returns the EKS cluster name of the pod identity, the credentials or else of the manager options
+func (r *RoleReconciler) clusterName(conf awsx.Config) string {
+	if r.res.Spec.AWS.PodIdentity != nil && r.res.Spec.AWS.PodIdentity.ClusterName != "" {
+		return r.res.Spec.AWS.PodIdentity.ClusterName
+	}
+	if conf.ClusterName != "" || r.options == nil || r.options.AWS == nil {
+		return conf.ClusterName
+	}
+	return r.options.AWS.ClusterName
+}
+
 // Reconcile performs Reconcilation
 func (r *RoleReconciler) Reconcile(ctx context.Context) error {
+	return r.normalizeError(ctx, r.reconcile(ctx))
+}
+
+func (r *RoleReconciler) reconcile(ctx context.Context) error {
+	// the role is only read, the kubernetes resources are still reconciled
+	if reconcilers.IsObserveOnly(r.res) {
+		err := r.doIAMRoleObserve()
+		if err != nil {
+			return err
+		}
+		return r.doKubernetesActions(ctx)
+	}
+
 	// reconcile IAM Role
 	err := r.doIAMRoleReconcile(ctx)
 	if err != nil {
@@ -124,31 +184,32 @@ func (r *RoleReconciler) Reconcile(ctx context.Context) error {
 	return r.doActions(ctx)
 }
 
+// normalizeError categorizes the aws errors, their request id is kept for support tickets
 func (r *RoleReconciler) normalizeError(ctx context.Context, err error) error {
-	if aerr, ok := err.(awserr.Error); ok {
-		msg := aerr.Error()
-		if strings.HasPrefix(msg, "AccessDenied") {
-			r.base.Log(ctx).Info("aws error1", "err", err)
-			return fmt.Errorf("AccessDenied")
-		}
-		ix := strings.Index(msg, "request id:")
-		if ix > -1 {
-			r.base.Log(ctx).Info("aws error2", "err", err)
-			return fmt.Errorf(msg[0:ix])
-		}
+	err = awsx.ToError(err)
+	if category := imtypes.Category(err); category != "" {
+		r.base.Log(ctx).Info("aws error", "category", category, "err", err)
 	}
 	return err
 }
 
 func (r *RoleReconciler) doIAMRoleReconcile(ctx context.Context) error {
+	// imported roles are only recorded, they are managed once adopted
 	importID := r.res.GetAnnotations()[consts.ImportKey]
-	if importID != "" {
+	if importID != "" && !reconcilers.IsAdopt(r.res) {
 		r.res.Status.ID = importID
 		return nil
 	}
+	// lint the policies before any call to AWS
+	err := r.iamClient.ValidatePolicies()
+	if err != nil {
+		r.res.Status.SetConditions(v1alpha1.PolicyInvalid(err.Error()))
+		return imtypes.NewError(imtypes.ErrorInvalidSpec, err)
+	}
+	r.res.Status.SetConditions(v1alpha1.PolicyValid())
 	status, err := r.iamClient.CreateOrUpdate(ctx)
 	if err != nil {
-		return r.normalizeError(ctx, err)
+		return err
 	}
 	if status != nil && status.Name != "" && status.ARN != "" &&
 		(r.res.Status.Name != status.Name || r.res.Status.ID != status.ARN) {
@@ -158,20 +219,71 @@ func (r *RoleReconciler) doIAMRoleReconcile(ctx context.Context) error {
 	return nil
 }
 
+func (r *RoleReconciler) doIAMRoleObserve() error {
+	status, observed, err := r.iamClient.Observe()
+	if err != nil {
+		return err
+	}
+	r.res.Status.ID = status.ARN
+	r.res.Status.Name = status.Name
+	r.res.Status.Observed = observed
+	return nil
+}
+
 // Finalize is the implementation of Finalizer
 func (r *RoleReconciler) Finalize(ctx context.Context) error {
-	importID := r.res.GetAnnotations()[consts.ImportKey]
-	if importID != "" {
+	return r.normalizeError(ctx, r.finalize(ctx))
+}
+
+func (r *RoleReconciler) finalize(ctx context.Context) error {
+	// the cluster access and the pod identity associations are owned by the controller,
+	// they are revoked even when the role is retained
+	err := r.deleteClusterAccess(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = r.deletePodIdentityAssociations(ctx)
+	if err != nil {
+		return err
+	}
+	// observed and imported roles are left untouched
+	if reconcilers.IsRetained(r.res) {
 		return nil
 	}
-	err := r.iamClient.Delete(ctx)
+	err = r.iamClient.Delete(ctx)
 	if err != nil {
-		return r.normalizeError(ctx, err)
+		return err
+	}
+	// iam is eventually consistent, wait for the role to be gone
+	if r.iamClient.Exists() {
+		return imtypes.ErrDeleting
 	}
 	return nil
 }
 
+// Ready implements ReadyReconciler interface
+func (r *RoleReconciler) Ready(ctx context.Context) error {
+	err := r.iamClient.Ready()
+	if err != nil {
+		return r.normalizeError(ctx, err)
+	}
+	// pod identity replaces the role annotation of the service accounts
+	if r.res.Spec.AWS.PodIdentity != nil {
+		return nil
+	}
+	return reconcilers.CheckServiceAccounts(ctx, r.Client, r.res, r.res.Spec.AWS.ServiceAccounts, serviceAccountAnnotationKey, r.res.Status.ID)
+}
+
 func (r *RoleReconciler) doActions(ctx context.Context) error {
+	// reconcile pod identity associations
+	err := r.doPodIdentityReconcile(ctx)
+	if err != nil {
+		return err
+	}
+	return r.doKubernetesActions(ctx)
+}
+
+func (r *RoleReconciler) doKubernetesActions(ctx context.Context) error {
 	// reconcile serviceaccount
 	for _, sa := range r.res.Spec.AWS.ServiceAccounts {
 		_, err := r.doServiceAccountReconcile(ctx, sa)
@@ -187,7 +299,9 @@ func (r *RoleReconciler) doActions(ctx context.Context) error {
 			return err
 		}
 	}
-	return nil
+
+	// reconcile cluster access
+	return r.doClusterAccessReconcile(ctx)
 }
 
 func (r *RoleReconciler) doServiceAccountReconcile(ctx context.Context, saSpec *v1alpha1.ServiceAccount) (ctrl.Result, error) {
@@ -245,6 +359,18 @@ func (r *RoleReconciler) doServiceAccountReconcile(ctx context.Context, saSpec *
 		existingSA.Annotations = make(map[string]string)
 	}
 
+	// pod identity replaces IRSA, so the role annotation is removed
+	if r.res.Spec.AWS.PodIdentity != nil {
+		if existingSA.Annotations[serviceAccountAnnotationKey] == arn {
+			delete(existingSA.Annotations, serviceAccountAnnotationKey)
+			err = r.Update(ctx, existingSA)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
 	if existingSA.Annotations[serviceAccountAnnotationKey] != arn {
 		if len(existingSA.Annotations) == 0 {
 			existingSA.Annotations = map[string]string{}
@@ -272,13 +398,17 @@ func (r *RoleReconciler) restartPods(ctx context.Context, p *v1alpha1.PodSelecto
 		count := 0
 		for _, c := range pod.Spec.Containers {
 			for _, env := range c.Env {
-				if env.Value == arn && env.Name == "AWS_ROLE_ARN" {
+				if r.res.Spec.AWS.PodIdentity != nil {
+					if env.Name == podIdentityEnvKey {
+						count++
+					}
+				} else if env.Value == arn && env.Name == "AWS_ROLE_ARN" {
 					count++
 				}
 			}
 		}
 
-		// if there are no containers with with aws role arn env, delete the pod
+		// if there are no containers with with aws role arn (or pod identity) env, delete the pod
 		// TODO: support rolling restart instead of delete
 		if count == 0 {
 			// ignore error
@@ -298,6 +428,9 @@ func (r *RoleReconciler) newServiceAccount(saName string, saNamespace string) (*
 			Annotations: map[string]string{serviceAccountAnnotationKey: r.res.Status.ID},
 		},
 	}
+	if r.res.Spec.AWS.PodIdentity != nil {
+		sa.Annotations = nil
+	}
 	// Set AwsRole instance as the owner and controller (for gc)
 	err := ctrl.SetControllerReference(r.res, sa, r.scheme)
 	if err != nil {
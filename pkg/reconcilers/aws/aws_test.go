@@ -5,16 +5,21 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eks"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
 	"github.com/invisibl-cloud/identity-manager/pkg/options"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx"
+	eksc "github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/eks"
 	iamx "github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/iam"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/mocks"
+	"github.com/invisibl-cloud/identity-manager/pkg/reconcilers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -25,7 +30,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 )
 
@@ -413,6 +420,240 @@ func TestGetConfig(t *testing.T) {
 	assert.Equal(t, awsSecretAccessKey, conf.SecretAccessKey)
 }
 
+func TestClusterAccessReconcile(t *testing.T) {
+	wi := &v1alpha1.WorkloadIdentity{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ccs-v1",
+			Namespace: "dev",
+		},
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Provider: v1alpha1.ProviderAWS,
+			AWS: &v1alpha1.WorkloadIdentityAWS{
+				ClusterAccess: &v1alpha1.AWSClusterAccess{
+					Groups: []string{"dev"},
+				},
+			},
+		},
+		Status: v1alpha1.WorkloadIdentityStatus{
+			ID:   "arn:aws:iam::12345678:role/ccs-v1",
+			Name: "ccs-v1",
+		},
+	}
+	k8sClient := fake.NewClientBuilder().Build()
+	awsRec := &RoleReconciler{
+		Client: k8sClient,
+		res:    wi,
+	}
+
+	err := awsRec.doClusterAccessReconcile(context.Background())
+	require.Nil(t, err)
+
+	cm := &corev1.ConfigMap{}
+	err = k8sClient.Get(context.Background(), types.NamespacedName{Namespace: "kube-system", Name: "workloadidentity-dev-ccs-v1"}, cm)
+	require.Nil(t, err)
+	assert.Equal(t, "aws-auth", cm.Labels[consts.AWSAuthNameKey])
+	assert.Equal(t, "dev/ccs-v1", cm.Annotations[workloadIdentityAnnotationKey])
+	assert.Equal(t, "- groups:\n  - dev\n  rolearn: arn:aws:iam::12345678:role/ccs-v1\n  username: dev:ccs-v1:{{SessionName}}\n", cm.Data["mapRoles"])
+
+	// moving to another AWSAuth namespace removes the old configmap
+	wi.Spec.AWS.ClusterAccess.AWSAuthNamespace = "eks"
+	wi.Spec.AWS.ClusterAccess.Username = "<(roleName)"
+	err = awsRec.doClusterAccessReconcile(context.Background())
+	require.Nil(t, err)
+
+	l := &corev1.ConfigMapList{}
+	require.Nil(t, k8sClient.List(context.Background(), l))
+	require.Len(t, l.Items, 1)
+	assert.Equal(t, "eks", l.Items[0].Namespace)
+	assert.Contains(t, l.Items[0].Data["mapRoles"], "username: ccs-v1\n")
+
+	// removing cluster access removes the configmap
+	wi.Spec.AWS.ClusterAccess = nil
+	err = awsRec.doClusterAccessReconcile(context.Background())
+	require.Nil(t, err)
+	require.Nil(t, k8sClient.List(context.Background(), l))
+	assert.Empty(t, l.Items)
+}
+
+func TestClusterAccessLongName(t *testing.T) {
+	name := strings.Repeat("a", 100)
+	wi := &v1alpha1.WorkloadIdentity{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "dev"},
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Provider: v1alpha1.ProviderAWS,
+			AWS:      &v1alpha1.WorkloadIdentityAWS{ClusterAccess: &v1alpha1.AWSClusterAccess{Groups: []string{"dev"}}},
+		},
+		Status: v1alpha1.WorkloadIdentityStatus{ID: "arn:aws:iam::12345678:role/dev-" + name},
+	}
+	// a WorkloadIdentity of the same name in another namespace
+	other := wi.DeepCopy()
+	other.Namespace = "prod"
+	k8sClient := fake.NewClientBuilder().Build()
+	for _, res := range []*v1alpha1.WorkloadIdentity{wi, other} {
+		err := (&RoleReconciler{Client: k8sClient, res: res}).doClusterAccessReconcile(context.Background())
+		require.Nil(t, err)
+	}
+
+	l := &corev1.ConfigMapList{}
+	require.Nil(t, k8sClient.List(context.Background(), l))
+	require.Len(t, l.Items, 2)
+	for _, item := range l.Items {
+		assert.Empty(t, validation.IsValidLabelValue(item.Labels[roleLabelKey]))
+	}
+
+	// only the configmap of the finalized WorkloadIdentity is deleted
+	err := (&RoleReconciler{Client: k8sClient, res: wi}).deleteClusterAccess(context.Background(), nil)
+	require.Nil(t, err)
+	require.Nil(t, k8sClient.List(context.Background(), l))
+	require.Len(t, l.Items, 1)
+	assert.Equal(t, "prod/"+name, l.Items[0].Annotations[workloadIdentityAnnotationKey])
+}
+
+func TestFinalizeRetained(t *testing.T) {
+	associationArn := "arn:aws:eks:us-east-1:12345678:podidentityassociation/dev-cluster/a-1234"
+	wi := &v1alpha1.WorkloadIdentity{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ccs-v1",
+			Namespace:   "dev",
+			Annotations: map[string]string{consts.ImportKey: "arn:aws:iam::12345678:role/ccs-v1"},
+		},
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Provider: v1alpha1.ProviderAWS,
+			AWS:      &v1alpha1.WorkloadIdentityAWS{PodIdentity: &v1alpha1.AWSPodIdentity{ClusterName: "dev-cluster"}},
+		},
+		Status: v1alpha1.WorkloadIdentityStatus{
+			ID:   "arn:aws:iam::12345678:role/ccs-v1",
+			Name: "ccs-v1",
+			ExternalResources: []v1alpha1.ExternalResource{
+				{ID: associationArn, Type: externalResourceTypePodIdentityAssociation},
+			},
+		},
+	}
+	eksClient := mocks.NewEKS(t)
+	eksClient.On("DeletePodIdentityAssociation", &eks.DeletePodIdentityAssociationInput{
+		ClusterName:   aws.String("dev-cluster"),
+		AssociationId: aws.String("a-1234"),
+	}).Return(&eks.DeletePodIdentityAssociationOutput{}, nil).Once()
+	iamClient := mocks.NewIAM(t)
+	stsClient := &mocks.STS{}
+	stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("12345678"),
+	}, nil)
+	iamC, err := iamx.New(iamClient, stsClient, wi, &options.Options{AWS: &awsx.Options{}})
+	require.Nil(t, err)
+	awsRec := &RoleReconciler{
+		Client:    fake.NewClientBuilder().Build(),
+		res:       wi,
+		iamClient: iamC,
+		eksClient: eksc.New(eksClient, "dev-cluster", "dev/ccs-v1"),
+	}
+
+	// the imported role is kept, the associations created by the controller are deleted
+	err = awsRec.finalize(context.Background())
+	require.Nil(t, err)
+	assert.Empty(t, wi.Status.ExternalResources)
+	iamClient.AssertNotCalled(t, "DeleteRole", mock.Anything)
+}
+
+func TestIAMRoleReconcileImport(t *testing.T) {
+	assumeRolePolicy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+	wi := &v1alpha1.WorkloadIdentity{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "ccs-v1",
+			Namespace:   "dev",
+			Annotations: map[string]string{consts.ImportKey: "arn:aws:iam::12345678:role/existing"},
+		},
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Provider: v1alpha1.ProviderAWS,
+			AWS: &v1alpha1.WorkloadIdentityAWS{
+				AssumeRolePolicy: assumeRolePolicy,
+				Policies:         []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			},
+		},
+	}
+	// no IAM call is expected, imported roles are only recorded
+	iamClient := mocks.NewIAM(t)
+	stsClient := &mocks.STS{}
+	stsClient.On("GetCallerIdentity", &sts.GetCallerIdentityInput{}).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("12345678"),
+	}, nil)
+	iamC, err := iamx.New(iamClient, stsClient, wi, &options.Options{AWS: &awsx.Options{}})
+	require.Nil(t, err)
+	awsRec := &RoleReconciler{
+		res:       wi,
+		iamClient: iamC.WithAdopt(reconcilers.IsAdopt(wi)),
+	}
+	err = awsRec.doIAMRoleReconcile(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, "arn:aws:iam::12345678:role/existing", wi.Status.ID)
+	assert.Empty(t, wi.Status.Name)
+
+	// adopted as well, the role of the arn is synced
+	wi.Annotations[consts.AdoptKey] = "true"
+	iamClient.On("GetRole", &iam.GetRoleInput{RoleName: aws.String("existing")}).Return(&iam.GetRoleOutput{
+		Role: &iam.Role{
+			Arn:                      aws.String("arn:aws:iam::12345678:role/existing"),
+			RoleName:                 aws.String("existing"),
+			AssumeRolePolicyDocument: aws.String(assumeRolePolicy),
+		},
+	}, nil)
+	iamClient.On("ListRolePoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("ListPoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("ListAttachedRolePoliciesPages", mock.Anything, mock.Anything).Return(nil)
+	iamClient.On("AttachRolePolicy", &iam.AttachRolePolicyInput{
+		PolicyArn: aws.String("arn:aws:iam::aws:policy/ReadOnlyAccess"),
+		RoleName:  aws.String("existing"),
+	}).Return(nil, nil).Once()
+	awsRec.iamClient = iamC.WithAdopt(reconcilers.IsAdopt(wi))
+	err = awsRec.doIAMRoleReconcile(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, "existing", wi.Status.Name)
+}
+
+func TestGetPolicyDocuments(t *testing.T) {
+	wi := &v1alpha1.WorkloadIdentity{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ccs-v1",
+			Namespace: "dev",
+		},
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Provider: v1alpha1.ProviderAWS,
+			AWS: &v1alpha1.WorkloadIdentityAWS{
+				AssumeRolePolicyFrom: &v1alpha1.PolicyValueFrom{
+					ConfigMapKeyRef: &v1alpha1.KeyRef{Name: "policies", Key: "trust"},
+				},
+				InlinePoliciesFrom: map[string]v1alpha1.PolicyValueFrom{
+					"s3": {SecretKeyRef: &v1alpha1.KeyRef{Name: "policies", Key: "s3"}},
+				},
+			},
+		},
+	}
+	k8sClient := fake.NewClientBuilder().WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "policies", Namespace: "dev"},
+			Data:       map[string]string{"trust": `{"Version":"2012-10-17"}`},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "policies", Namespace: "dev"},
+			Data:       map[string][]byte{"s3": []byte(`{"Statement":[]}`)},
+		},
+	).Build()
+	awsRec := &RoleReconciler{
+		Client: k8sClient,
+		res:    wi,
+	}
+
+	documents, err := awsRec.getPolicyDocuments(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, `{"Version":"2012-10-17"}`, documents.AssumeRolePolicy)
+	assert.Equal(t, map[string]string{"s3": `{"Statement":[]}`}, documents.InlinePolicies)
+
+	// missing keys are reported
+	wi.Spec.AWS.InlinePoliciesFrom["s3"] = v1alpha1.PolicyValueFrom{SecretKeyRef: &v1alpha1.KeyRef{Name: "policies", Key: "missing"}}
+	_, err = awsRec.getPolicyDocuments(context.Background())
+	assert.EqualError(t, err, "error reading inlinePoliciesFrom s3: missing key missing in secret policies")
+}
+
 func newNamespace(k8sClient client.Client, saNamespace string) error {
 	sa := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
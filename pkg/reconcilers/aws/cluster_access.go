@@ -0,0 +1,122 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+	"github.com/invisibl-cloud/identity-manager/pkg/util"
+	"github.com/valyala/fasttemplate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultClusterAccessUsername = "<(namespace):<(name):{{SessionName}}"
+
+// workloadIdentityAnnotationKey is the annotation of the namespace/name of the WorkloadIdentity of a source configmap
+const workloadIdentityAnnotationKey = "identity-manager.io/workloadidentity"
+
+// clusterAccessMapRole is the mapRoles item written to the source configmap of AWSAuth
+type clusterAccessMapRole struct {
+	RoleArn  string   `json:"rolearn"`
+	Username string   `json:"username"`
+	Groups   []string `json:"groups"`
+}
+
+// doClusterAccessReconcile writes the mapRoles entry of the role into a source configmap
+// labelled with the target AWSAuth, which merges it into the aws-auth configmap.
+func (r *RoleReconciler) doClusterAccessReconcile(ctx context.Context) error {
+	access := r.res.Spec.AWS.ClusterAccess
+	if access == nil {
+		// cluster access got removed from spec
+		return r.deleteClusterAccess(ctx, nil)
+	}
+	data, err := yaml.Marshal([]*clusterAccessMapRole{r.clusterAccessMapRole(access)})
+	if err != nil {
+		return err
+	}
+	cm := r.clusterAccessConfigMap()
+	// target AWSAuth namespace might have changed
+	err = r.deleteClusterAccess(ctx, cm)
+	if err != nil {
+		return err
+	}
+	_, err = ctrl.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		labels := cm.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[consts.AWSAuthNameKey] = util.DefaultString(access.AWSAuthName, "aws-auth")
+		labels[managedByLabelKey] = managedByValueKey
+		// label values are limited to 63 characters, the namespace/name is hashed
+		labels[roleLabelKey] = util.MD5(r.res.Namespace + "/" + r.res.Name)
+		cm.SetLabels(labels)
+		annotations := cm.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[workloadIdentityAnnotationKey] = r.res.Namespace + "/" + r.res.Name
+		cm.SetAnnotations(annotations)
+		cm.Data = map[string]string{"mapRoles": string(data)}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error while creating or updating cluster access configmap: %w", err)
+	}
+	return nil
+}
+
+func (r *RoleReconciler) clusterAccessMapRole(access *v1alpha1.AWSClusterAccess) *clusterAccessMapRole {
+	username := util.DefaultString(access.Username, defaultClusterAccessUsername)
+	return &clusterAccessMapRole{
+		RoleArn: r.res.Status.ID,
+		Username: fasttemplate.ExecuteString(username, "<(", ")", map[string]any{
+			"name":      r.res.Name,
+			"namespace": r.res.Namespace,
+			"roleName":  r.res.Status.Name,
+		}),
+		Groups: access.Groups,
+	}
+}
+
+// deleteClusterAccess deletes the source configmaps of the WorkloadIdentity except keep,
+// so that AWSAuth removes their entries. They are matched by name, which is unique per WorkloadIdentity.
+func (r *RoleReconciler) deleteClusterAccess(ctx context.Context, keep *corev1.ConfigMap) error {
+	l := &corev1.ConfigMapList{}
+	err := r.List(ctx, l, client.MatchingLabels{
+		managedByLabelKey: managedByValueKey,
+	}, client.HasLabels{consts.AWSAuthNameKey, roleLabelKey})
+	if err != nil {
+		return err
+	}
+	name := r.clusterAccessConfigMap().Name
+	for i, item := range l.Items {
+		if item.Name != name || (keep != nil && item.Namespace == keep.Namespace) {
+			continue
+		}
+		err = r.Delete(ctx, &l.Items[i])
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("error while deleting cluster access configmap: %w", err)
+		}
+	}
+	return nil
+}
+
+// clusterAccessConfigMap returns the source configmap. It lives next to the AWSAuth,
+// so it can't be owned by the WorkloadIdentity and is deleted on finalize instead.
+func (r *RoleReconciler) clusterAccessConfigMap() *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{}
+	cm.Name = fmt.Sprintf("workloadidentity-%s-%s", r.res.Namespace, r.res.Name)
+	if len(cm.Name) > 253 {
+		cm.Name = "workloadidentity-" + util.MD5(r.res.Namespace+"/"+r.res.Name)
+	}
+	cm.Namespace = "kube-system"
+	if access := r.res.Spec.AWS.ClusterAccess; access != nil {
+		cm.Namespace = util.DefaultString(access.AWSAuthNamespace, cm.Namespace)
+	}
+	return cm
+}
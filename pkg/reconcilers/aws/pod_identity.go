@@ -0,0 +1,84 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/eks"
+	"github.com/invisibl-cloud/identity-manager/pkg/util"
+)
+
+// externalResourceTypePodIdentityAssociation is the type of the
+// pod identity associations tracked in the status
+const externalResourceTypePodIdentityAssociation = "PodIdentityAssociation"
+
+// podIdentityEnvKey is the env injected by EKS Pod Identity into the containers
+const podIdentityEnvKey = "AWS_CONTAINER_CREDENTIALS_FULL_URI"
+
+// needsEKSClient checks whether pod identity is enabled or associations are left to delete
+func (r *RoleReconciler) needsEKSClient() bool {
+	return r.res.Spec.AWS.PodIdentity != nil || len(r.getPodIdentityAssociationArns()) > 0
+}
+
+// doPodIdentityReconcile associates the service accounts with the role,
+// and removes the associations which are no longer desired.
+func (r *RoleReconciler) doPodIdentityReconcile(ctx context.Context) error {
+	if r.eksClient == nil {
+		return nil
+	}
+	associations := []*eks.PodIdentityAssociation{}
+	if r.res.Spec.AWS.PodIdentity != nil {
+		for _, sa := range r.res.Spec.AWS.ServiceAccounts {
+			associations = append(associations, &eks.PodIdentityAssociation{
+				Namespace:      util.DefaultString(sa.Namespace, r.res.Namespace),
+				ServiceAccount: util.DefaultString(sa.Name, r.res.Name),
+				RoleArn:        r.res.Status.ID,
+			})
+		}
+	}
+	arns, err := r.eksClient.SyncPodIdentityAssociations(associations, r.getPodIdentityAssociationArns())
+	if err != nil {
+		return r.normalizeError(ctx, err)
+	}
+	r.setPodIdentityAssociationArns(arns)
+	return nil
+}
+
+// deletePodIdentityAssociations deletes all the associations of the role
+func (r *RoleReconciler) deletePodIdentityAssociations(ctx context.Context) error {
+	if r.eksClient == nil {
+		return nil
+	}
+	err := r.eksClient.DeletePodIdentityAssociations(r.getPodIdentityAssociationArns())
+	if err != nil {
+		return r.normalizeError(ctx, err)
+	}
+	r.setPodIdentityAssociationArns(nil)
+	return nil
+}
+
+func (r *RoleReconciler) getPodIdentityAssociationArns() []string {
+	arns := []string{}
+	for _, res := range r.res.Status.ExternalResources {
+		if res.Type == externalResourceTypePodIdentityAssociation {
+			arns = append(arns, res.ID)
+		}
+	}
+	return arns
+}
+
+func (r *RoleReconciler) setPodIdentityAssociationArns(arns []string) {
+	resources := []v1alpha1.ExternalResource{}
+	for _, res := range r.res.Status.ExternalResources {
+		if res.Type != externalResourceTypePodIdentityAssociation {
+			resources = append(resources, res)
+		}
+	}
+	for _, arn := range arns {
+		resources = append(resources, v1alpha1.ExternalResource{ID: arn, Type: externalResourceTypePodIdentityAssociation})
+	}
+	if len(resources) == 0 {
+		resources = nil
+	}
+	r.res.Status.ExternalResources = resources
+}
@@ -0,0 +1,65 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	iamc "github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/iam"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getPolicyDocuments reads the policy documents referenced by the spec
+// from the ConfigMaps and Secrets in the namespace of the WorkloadIdentity
+func (r *RoleReconciler) getPolicyDocuments(ctx context.Context) (*iamc.PolicyDocuments, error) {
+	documents := &iamc.PolicyDocuments{InlinePolicies: map[string]string{}}
+	// the documents are not needed to delete the role, and may be gone already
+	if r.res.GetDeletionTimestamp() != nil {
+		return documents, nil
+	}
+	if from := r.res.Spec.AWS.AssumeRolePolicyFrom; from != nil && r.res.Spec.AWS.AssumeRolePolicy == "" {
+		document, err := r.getPolicyDocument(ctx, from)
+		if err != nil {
+			return nil, fmt.Errorf("error reading assumeRolePolicyFrom: %w", err)
+		}
+		documents.AssumeRolePolicy = document
+	}
+	for name, from := range r.res.Spec.AWS.InlinePoliciesFrom {
+		from := from
+		document, err := r.getPolicyDocument(ctx, &from)
+		if err != nil {
+			return nil, fmt.Errorf("error reading inlinePoliciesFrom %s: %w", name, err)
+		}
+		documents.InlinePolicies[name] = document
+	}
+	return documents, nil
+}
+
+func (r *RoleReconciler) getPolicyDocument(ctx context.Context, from *v1alpha1.PolicyValueFrom) (string, error) {
+	switch {
+	case from.ConfigMapKeyRef != nil:
+		cm := &corev1.ConfigMap{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: r.res.Namespace, Name: from.ConfigMapKeyRef.Name}, cm)
+		if err != nil {
+			return "", err
+		}
+		document, ok := cm.Data[from.ConfigMapKeyRef.Key]
+		if !ok {
+			return "", fmt.Errorf("missing key %s in configmap %s", from.ConfigMapKeyRef.Key, from.ConfigMapKeyRef.Name)
+		}
+		return document, nil
+	case from.SecretKeyRef != nil:
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: r.res.Namespace, Name: from.SecretKeyRef.Name}, secret)
+		if err != nil {
+			return "", err
+		}
+		document, ok := secret.Data[from.SecretKeyRef.Key]
+		if !ok {
+			return "", fmt.Errorf("missing key %s in secret %s", from.SecretKeyRef.Key, from.SecretKeyRef.Name)
+		}
+		return string(document), nil
+	}
+	return "", fmt.Errorf("missing configMapKeyRef or secretKeyRef")
+}
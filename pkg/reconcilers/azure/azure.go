@@ -3,12 +3,16 @@ package azure
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2018-01-01-preview/authorization"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/google/uuid"
 	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/drift"
+	"github.com/invisibl-cloud/identity-manager/pkg/options"
+	"github.com/invisibl-cloud/identity-manager/pkg/plan"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/azurex"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/azurex/clients/accounts"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/azurex/clients/cosmos"
@@ -16,6 +20,7 @@ import (
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/azurex/clients/imds"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/azurex/clients/msi"
 	"github.com/invisibl-cloud/identity-manager/pkg/reconcilers"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
 	"github.com/invisibl-cloud/identity-manager/pkg/util"
 	"github.com/valyala/fasttemplate"
 	corev1 "k8s.io/api/core/v1"
@@ -32,8 +37,11 @@ type IdentityReconciler struct {
 	client.Client
 	scheme *runtime.Scheme
 	res    *v1alpha1.WorkloadIdentity
+	// options of the manager
+	options *options.Options
 	// internal
 	debug bool
+	tags  map[string]string
 	rbac  *graphrbac.Client
 	msi   *msi.Client
 }
@@ -41,9 +49,10 @@ type IdentityReconciler struct {
 // NewReconciler initializes IdentityReconciler
 func NewReconciler(base *reconcilers.ReconcilerBase, res *v1alpha1.WorkloadIdentity) *IdentityReconciler {
 	return &IdentityReconciler{
-		Client: base.Client(),
-		scheme: base.Scheme(),
-		res:    res,
+		Client:  base.Client(),
+		scheme:  base.Scheme(),
+		res:     res,
+		options: base.Options(),
 	}
 }
 
@@ -53,8 +62,18 @@ func (r *IdentityReconciler) Prepare(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	r.rbac = graphrbac.New(c)
-	r.msi = msi.New(c)
+	// in dry-run mode, the changes are recorded into the plan instead of being applied
+	p := plan.FromContext(ctx)
+	if p != nil {
+		r.Client = plan.NewClient(r.Client, p)
+	}
+	// changes of the tags or the defaults of the provider config are not drift
+	err = drift.FromContext(ctx).SetInputs(r.tags, c.GetConfig().Location, c.GetConfig().ResourceGroup)
+	if err != nil {
+		return err
+	}
+	r.rbac = graphrbac.New(c).WithPlan(p)
+	r.msi = msi.New(c).WithPlan(p)
 	r.debug = r.res.Annotations["debug"] == "true"
 	if _, ok := r.res.Annotations["check-imds"]; ok {
 		imds.Check(ctx, c.GetConfig().ClientID)
@@ -63,37 +82,51 @@ func (r *IdentityReconciler) Prepare(ctx context.Context) error {
 }
 
 func (r *IdentityReconciler) getAzurex(ctx context.Context) (*azurex.Client, error) {
-	creds := r.res.Spec.Credentials
-	if creds == nil {
+	creds, err := reconcilers.LoadCredentials(ctx, r.Client, r.res, r.options)
+	if err != nil {
+		return nil, err
+	}
+	r.tags = creds.Tags()
+	if creds.Data == nil {
 		return azurex.New(azurex.WithEnv())
 	}
-	configMap := map[string]any{}
-	switch creds.Source {
-	case v1alpha1.CredentialsSourceSecret:
-		if creds.SecretRef == nil {
-			return nil, fmt.Errorf("missing secretRef for credentials")
-		}
-		secret := &corev1.Secret{}
-		secret.Name = util.DefaultString(creds.SecretRef.Name, r.res.Name)
-		secret.Namespace = util.DefaultString(creds.SecretRef.Namespace, r.res.Namespace)
-		err := r.Get(ctx, client.ObjectKeyFromObject(secret), secret)
-		if err != nil {
-			return nil, err
-		}
-		for k, v := range secret.Data {
-			configMap[k] = string(v)
-		}
+	return azurex.New(azurex.WithEnv(), azurex.WithConfigMap(creds.ConfigMap()))
+}
+
+// ValidateCredentials checks that the credentials authenticate, by listing the identities of the resource group
+func ValidateCredentials(ctx context.Context, creds *reconcilers.Credentials) error {
+	c, err := azurex.New(azurex.WithEnv(), azurex.WithConfigMap(creds.ConfigMap()))
+	if err != nil {
+		return err
 	}
-	for k, v := range creds.Properties {
-		configMap[k] = v
+	return validateCredentials(ctx, c)
+}
+
+// validateCredentials only fails on authentication errors, the credentials may be missing
+// permissions on the resource group or the resource group may not exist yet
+func validateCredentials(ctx context.Context, c *azurex.Client) error {
+	_, err := msi.New(c).List(ctx)
+	err = azurex.ToError(err)
+	if types.Category(err) == types.ErrorAuthFailed {
+		return err
 	}
-	return azurex.New(azurex.WithEnv(), azurex.WithConfigMap(configMap))
+	return nil
 }
 
 // Reconcile reconciles the workload identity
 func (r *IdentityReconciler) Reconcile(ctx context.Context) error {
-	// reconcile
-	id, err := r.doReconcile(ctx)
+	return azurex.ToError(r.reconcile(ctx))
+}
+
+func (r *IdentityReconciler) reconcile(ctx context.Context) error {
+	// reconcile, or only read the identity, the kubernetes resources are still reconciled
+	var id *msi.Identity
+	var err error
+	if reconcilers.IsObserveOnly(r.res) {
+		id, err = r.doObserve(ctx)
+	} else {
+		id, err = r.doReconcile(ctx)
+	}
 	if err != nil {
 		return err
 	}
@@ -311,19 +344,49 @@ func (r *IdentityReconciler) doAzureIdentityBinding(ctx context.Context, aid *un
 	return nil
 }
 
+// identityTags returns the tags of the identity, the ones of the provider config and the managed-by tag
+func (r *IdentityReconciler) identityTags() map[string]*string {
+	tags := map[string]*string{}
+	for k, v := range r.tags {
+		tags[k] = to.StringPtr(v)
+	}
+	tags["managed-by"] = to.StringPtr("identity-manager.io")
+	return tags
+}
+
 func (r *IdentityReconciler) doReconcile(ctx context.Context) (*msi.Identity, error) {
 	if r.res.Spec.Azure == nil {
 		return nil, fmt.Errorf("missing azure section for provider azure")
 	}
 
 	log := log.FromContext(ctx)
-	// TODO: what if the name gets changed?
-	id, err := r.msi.CreateOrUpdate(ctx, util.DefaultString(r.res.Spec.Name, r.res.Name), map[string]*string{
-		"managed-by": to.StringPtr("identity-manager.io"),
-	})
+	// identities are never renamed, the existing identity is kept
+	name := util.DefaultString(r.res.Spec.Name, r.res.Name)
+	err := types.CheckRename(r.res.Status.Name, name)
+	if err != nil {
+		return nil, err
+	}
+	// identities which were not created by the controller are only managed once adopted
+	if r.res.Status.Name == "" {
+		exists, err := r.msi.Exists(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("Exists: %w", err)
+		}
+		if exists {
+			err = types.CheckExists(r.res.Status.Name, name, reconcilers.IsAdopt(r.res))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	id, err := r.msi.CreateOrUpdate(ctx, name, r.identityTags())
 	if err != nil {
 		return nil, fmt.Errorf("CreateOrUpdate: %w", err)
 	}
+	// the identity is recorded as soon as it is created, so that it is not mistaken on retry
+	// for an existing identity when one of the next steps fails
+	r.res.Status.ID = id.ID
+	r.res.Status.Name = id.Name
 
 	// Sync Custom Roles
 	for _, v := range r.res.Spec.Azure.RoleDefinitions {
@@ -373,13 +436,16 @@ func (r *IdentityReconciler) doReconcile(ctx context.Context) (*msi.Identity, er
 	if r.debug {
 		log.Info("debug", "existingIds", existingIds, "newIds", newIds, "syncSteps", syncSteps)
 	}
+	report := drift.FromContext(ctx)
 	for _, raid := range syncSteps.Add {
+		report.Add(drift.TypeRoleAssignment, idMap[raid], v1alpha1.DriftChangeRemoved)
 		err = r.attachRoleDefinition(ctx, raid, id.PrincipalID, r.res.Spec.Azure.RoleAssignments[idMap[raid]])
 		if err != nil {
 			return nil, err
 		}
 	}
 	for _, raid := range syncSteps.Delete {
+		report.Add(drift.TypeRoleAssignment, raid, v1alpha1.DriftChangeAdded)
 		err = r.detachRoleDefinition(ctx, raid)
 		if err != nil {
 			return nil, err
@@ -389,6 +455,34 @@ func (r *IdentityReconciler) doReconcile(ctx context.Context) (*msi.Identity, er
 	return id, nil
 }
 
+func (r *IdentityReconciler) doObserve(ctx context.Context) (*msi.Identity, error) {
+	if r.res.Spec.Azure == nil {
+		return nil, fmt.Errorf("missing azure section for provider azure")
+	}
+	name := util.DefaultString(r.res.Status.Name, util.DefaultString(r.res.Spec.Name, r.res.Name))
+	id, err := r.msi.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("Get: %w", err)
+	}
+	assignments, err := r.rbac.ListRoleAssignments(ctx, id.PrincipalID)
+	if err != nil {
+		return nil, fmt.Errorf("ListRoleAssignments: %w", err)
+	}
+	roles := []string{}
+	for _, ra := range assignments {
+		if ra.RoleAssignmentPropertiesWithScope != nil {
+			roles = append(roles, to.String(ra.RoleDefinitionID))
+		}
+	}
+	sort.Strings(roles)
+	r.res.Status.Observed = &v1alpha1.ObservedIdentity{
+		ClientID:    id.ClientID,
+		PrincipalID: id.PrincipalID,
+		Roles:       roles,
+	}
+	return id, nil
+}
+
 func (r *IdentityReconciler) detachRoleDefinition(ctx context.Context, id string) error {
 	return r.rbac.DeleteRoleAssignment(ctx, id)
 }
@@ -409,12 +503,35 @@ func (r *IdentityReconciler) attachRoleDefinition(ctx context.Context, id string
 
 // Finalize implements Finalizer interface
 func (r *IdentityReconciler) Finalize(ctx context.Context) error {
-	ok, err := r.msi.EnsureDelete(ctx, util.DefaultString(r.res.Spec.Name, r.res.Name))
+	return azurex.ToError(r.finalize(ctx))
+}
+
+func (r *IdentityReconciler) finalize(ctx context.Context) error {
+	// observed and adopted identities are left untouched
+	if reconcilers.IsRetained(r.res) {
+		return nil
+	}
+	// the identity keeps the name it was created with
+	name := util.DefaultString(r.res.Status.Name, util.DefaultString(r.res.Spec.Name, r.res.Name))
+	ok, err := r.msi.EnsureDelete(ctx, name)
 	if err != nil {
 		return err
 	}
+	// the deletion is asynchronous, wait for the identity to be gone
 	if !ok {
-		return fmt.Errorf("deleting identity %s", r.res.Spec.Name)
+		return types.ErrDeleting
+	}
+	return nil
+}
+
+// Ready implements ReadyReconciler interface
+func (r *IdentityReconciler) Ready(ctx context.Context) error {
+	id, err := r.msi.Get(ctx, r.res.Status.Name)
+	if err != nil {
+		return fmt.Errorf("Get: %w", err)
+	}
+	if id.PrincipalID == "" {
+		return fmt.Errorf("identity %s has no principal yet", id.Name)
 	}
 	return nil
 }
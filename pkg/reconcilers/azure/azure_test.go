@@ -0,0 +1,137 @@
+package azure
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/azurex"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/azurex/clients/graphrbac"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/azurex/clients/msi"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func response(req *http.Request, statusCode int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestReconcilePartialCreate(t *testing.T) {
+	identity := `{"id":"/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/demo","name":"demo",` +
+		`"properties":{"principalId":"11111111-1111-1111-1111-111111111111","clientId":"22222222-2222-2222-2222-222222222222"}}`
+	created := false
+	definitionFails := true
+	var requests []string
+	sender := autorest.SenderFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.Method+" "+req.URL.Path)
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/userAssignedIdentities/demo") && req.Method == http.MethodGet:
+			if !created {
+				return response(req, http.StatusNotFound, `{"error":{"code":"ResourceNotFound"}}`), nil
+			}
+			return response(req, http.StatusOK, identity), nil
+		case strings.HasSuffix(req.URL.Path, "/userAssignedIdentities/demo") && req.Method == http.MethodPut:
+			created = true
+			return response(req, http.StatusCreated, identity), nil
+		case strings.Contains(req.URL.Path, "/roleDefinitions/"):
+			// the identity is created, but creating its role definition fails
+			if definitionFails {
+				definitionFails = false
+				return response(req, http.StatusForbidden, `{"error":{"code":"AuthorizationFailed"}}`), nil
+			}
+			return response(req, http.StatusCreated, `{}`), nil
+		case strings.HasSuffix(req.URL.Path, "/roleAssignments"):
+			return response(req, http.StatusOK, `{"value":[]}`), nil
+		}
+		return response(req, http.StatusNotFound, `{}`), nil
+	})
+	c, err := azurex.New(
+		azurex.WithConfig(&azurex.Config{SubscriptionID: "sub", ResourceGroup: "rg", Location: "westeurope"}),
+		azurex.WithAuthorizer(autorest.NullAuthorizer{}),
+		azurex.WithSender(sender),
+	)
+	require.Nil(t, err)
+	res := &v1alpha1.WorkloadIdentity{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "dev"},
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Provider: v1alpha1.ProviderAzure,
+			Azure: &v1alpha1.WorkloadIdentityAzure{
+				RoleDefinitions: []*v1alpha1.RoleDefinition{{ID: "reader", RoleName: "demo-reader"}},
+			},
+		},
+	}
+	r := &IdentityReconciler{res: res, msi: msi.New(c), rbac: graphrbac.New(c)}
+
+	_, err = r.doReconcile(context.Background())
+	require.NotNil(t, err)
+	assert.Equal(t, "demo", res.Status.Name)
+	assert.Equal(t, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/demo", res.Status.ID)
+
+	// the retry syncs the identity it created instead of asking to adopt it
+	id, err := r.doReconcile(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, "demo", id.Name)
+	assert.Contains(t, requests, "PUT /subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/demo")
+}
+
+func TestValidateCredentials(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		statusCode int
+		body       string
+		expected   types.ErrorCategory
+	}{
+		{
+			desc:       "the identities of the resource group are listed",
+			statusCode: http.StatusOK,
+			body:       `{"value":[{"id":"/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/demo","name":"demo","properties":{}}]}`,
+		},
+		{
+			desc:       "missing permissions do not invalidate the credentials",
+			statusCode: http.StatusForbidden,
+			body:       `{"error":{"code":"AuthorizationFailed"}}`,
+		},
+		{
+			desc:       "a missing resource group does not invalidate the credentials",
+			statusCode: http.StatusNotFound,
+			body:       `{"error":{"code":"ResourceGroupNotFound"}}`,
+		},
+		{
+			desc:       "authentication errors invalidate the credentials",
+			statusCode: http.StatusUnauthorized,
+			body:       `{"error":{"code":"InvalidAuthenticationToken"}}`,
+			expected:   types.ErrorAuthFailed,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.desc, func(t *testing.T) {
+			sender := autorest.SenderFunc(func(req *http.Request) (*http.Response, error) {
+				assert.Equal(t, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities", req.URL.Path)
+				return response(req, testCase.statusCode, testCase.body), nil
+			})
+			c, err := azurex.New(
+				azurex.WithConfig(&azurex.Config{SubscriptionID: "sub", ResourceGroup: "rg"}),
+				azurex.WithAuthorizer(autorest.NullAuthorizer{}),
+				azurex.WithSender(sender),
+			)
+			require.Nil(t, err)
+			err = validateCredentials(context.Background(), c)
+			if testCase.expected == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.Equal(t, testCase.expected, types.Category(err))
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package reconcilers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+	"github.com/invisibl-cloud/identity-manager/pkg/options"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
+	"github.com/invisibl-cloud/identity-manager/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Credentials are the credentials of a provider and the provider config they were loaded from
+type Credentials struct {
+	// Data of the credentials, the secret data overridden by the properties, nil when the environment is used
+	Data map[string]string
+	// Config is the spec of the referenced provider config, nil when not referenced
+	Config *v1alpha1.ProviderConfigSpec
+}
+
+// Bytes returns the data of the credentials as bytes
+func (c *Credentials) Bytes() map[string][]byte {
+	if c.Data == nil {
+		return nil
+	}
+	m := make(map[string][]byte, len(c.Data))
+	for k, v := range c.Data {
+		m[k] = []byte(v)
+	}
+	return m
+}
+
+// ConfigMap returns the data of the credentials as a config map of the azure and gcp clients
+func (c *Credentials) ConfigMap() map[string]any {
+	m := make(map[string]any, len(c.Data))
+	for k, v := range c.Data {
+		m[k] = v
+	}
+	return m
+}
+
+// Tags returns the tags of the provider config
+func (c *Credentials) Tags() map[string]string {
+	if c.Config == nil {
+		return nil
+	}
+	return c.Config.Tags
+}
+
+// Options returns the options of the manager with the name prefix and the tags of the provider config
+func (c *Credentials) Options(o *options.Options) *options.Options {
+	if c.Config == nil || o == nil || (c.Config.NamePrefix == "" && len(c.Config.Tags) == 0) {
+		return o
+	}
+	out := *o
+	out.NamePrefix = util.DefaultString(c.Config.NamePrefix, o.NamePrefix)
+	out.Tags = make(map[string]string, len(o.Tags)+len(c.Config.Tags))
+	for k, v := range o.Tags {
+		out.Tags[k] = v
+	}
+	for k, v := range c.Config.Tags {
+		out.Tags[k] = v
+	}
+	return &out
+}
+
+// LoadCredentials loads the credentials of the WorkloadIdentity. The credentials of the spec take
+// precedence over the ones of the referenced provider config, whose defaults fill the missing values.
+func LoadCredentials(ctx context.Context, c client.Reader, res *v1alpha1.WorkloadIdentity, o *options.Options) (*Credentials, error) {
+	ref := res.Spec.ProviderConfigRef
+	if ref == nil {
+		data, err := LoadSourceCredentials(ctx, c, res.Spec.Credentials, res.Name, res.Namespace, o)
+		if err != nil {
+			return nil, err
+		}
+		return &Credentials{Data: data}, nil
+	}
+	spec, namespace, err := GetProviderConfig(ctx, c, ref, res.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Provider != res.Spec.Provider {
+		return nil, types.Errorf(types.ErrorInvalidSpec, "provider config %s is for %s, not %s", ref.Name, spec.Provider, res.Spec.Provider)
+	}
+	if res.Spec.Credentials == nil {
+		return LoadProviderConfigCredentials(ctx, c, spec, ref.Name, namespace, o)
+	}
+	data, err := LoadSourceCredentials(ctx, c, res.Spec.Credentials, res.Name, res.Namespace, o)
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{Data: withDefaults(data, spec), Config: spec}, nil
+}
+
+// GetProviderConfig returns the spec of the referenced ProviderConfig or ClusterProviderConfig,
+// with the namespace of its secret, empty for a ClusterProviderConfig
+func GetProviderConfig(ctx context.Context, c client.Reader, ref *v1alpha1.ProviderConfigRef, namespace string) (*v1alpha1.ProviderConfigSpec, string, error) {
+	switch ref.Kind {
+	case v1alpha1.ClusterProviderConfigKind:
+		pc := &v1alpha1.ClusterProviderConfig{}
+		err := c.Get(ctx, ktypes.NamespacedName{Name: ref.Name}, pc)
+		if err != nil {
+			return nil, "", providerConfigError(ref, err)
+		}
+		return &pc.Spec, "", nil
+	case "", v1alpha1.ProviderConfigKind:
+		pc := &v1alpha1.ProviderConfig{}
+		err := c.Get(ctx, ktypes.NamespacedName{Name: ref.Name, Namespace: namespace}, pc)
+		if err != nil {
+			return nil, "", providerConfigError(ref, err)
+		}
+		return &pc.Spec, namespace, nil
+	}
+	return nil, "", types.Errorf(types.ErrorInvalidSpec, "invalid provider config kind %s", ref.Kind)
+}
+
+func providerConfigError(ref *v1alpha1.ProviderConfigRef, err error) error {
+	if errors.IsNotFound(err) {
+		return types.Errorf(types.ErrorDependencyMissing, "provider config %s not found", ref.Name)
+	}
+	return fmt.Errorf("error getting provider config %s - %w", ref.Name, err)
+}
+
+// LoadProviderConfigCredentials loads the credentials of a provider config, with its defaults. The namespace
+// of the secret defaults to the namespace of a ProviderConfig and is required for a ClusterProviderConfig.
+func LoadProviderConfigCredentials(ctx context.Context, c client.Reader, spec *v1alpha1.ProviderConfigSpec, name string, namespace string, o *options.Options) (*Credentials, error) {
+	creds := spec.Credentials
+	if creds != nil && creds.SecretRef != nil && creds.SecretRef.Namespace == "" && namespace == "" {
+		return nil, types.Errorf(types.ErrorInvalidSpec, "missing secretRef namespace for credentials of cluster provider config %s", name)
+	}
+	data, err := LoadSourceCredentials(ctx, c, creds, name, namespace, o)
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{Data: withDefaults(data, spec), Config: spec}, nil
+}
+
+// LoadSourceCredentials loads the credentials from their source, the properties override the loaded data.
+// The name and the namespace of the secret default to the given ones. It returns nil without credentials.
+// The files of the Filesystem source must be in the credentials directory of the options.
+func LoadSourceCredentials(ctx context.Context, c client.Reader, creds *v1alpha1.Credentials, name string, namespace string, o *options.Options) (map[string]string, error) {
+	if creds == nil {
+		return nil, nil
+	}
+	data := map[string]string{}
+	source := creds.Source
+	// as defaulted by the webhook of the WorkloadIdentity, provider configs have none
+	if source == "" && creds.SecretRef != nil {
+		source = v1alpha1.CredentialsSourceSecret
+	}
+	switch source {
+	case v1alpha1.CredentialsSourceSecret:
+		if creds.SecretRef == nil {
+			return nil, fmt.Errorf("missing secretRef for credentials")
+		}
+		secret := &corev1.Secret{}
+		secret.Name = util.DefaultString(creds.SecretRef.Name, name)
+		secret.Namespace = util.DefaultString(creds.SecretRef.Namespace, namespace)
+		err := c.Get(ctx, client.ObjectKeyFromObject(secret), secret)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range secret.Data {
+			data[k] = string(v)
+		}
+	case v1alpha1.CredentialsSourceInjectedIdentity, v1alpha1.CredentialsSourceEnvironment:
+		// the providers authenticate with the identity or the environment of the controller
+		data[consts.CredentialsSourceKey] = string(source)
+	case v1alpha1.CredentialsSourceFilesystem:
+		if creds.Path == "" {
+			return nil, types.Errorf(types.ErrorInvalidSpec, "missing path for %s credentials", source)
+		}
+		data[consts.CredentialsSourceKey] = string(source)
+		data[consts.CredentialsPathKey] = creds.Path
+	}
+	for k, v := range creds.Properties {
+		data[k] = v
+	}
+	// checked once merged, the secret and the properties may select the source as well
+	if data[consts.CredentialsSourceKey] == string(v1alpha1.CredentialsSourceFilesystem) {
+		err := checkCredentialsPath(data[consts.CredentialsPathKey], o)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// checkCredentialsPath checks that the file of the Filesystem source is in the credentials directory,
+// so that the other files of the controller, e.g. its service account token, are never used
+func checkCredentialsPath(path string, o *options.Options) error {
+	if o == nil || o.CredentialsDir == "" {
+		return types.Errorf(types.ErrorInvalidSpec, "the %s credentials source is disabled, no credentials directory is set", v1alpha1.CredentialsSourceFilesystem)
+	}
+	rel, err := filepath.Rel(filepath.Clean(o.CredentialsDir), filepath.Clean(path))
+	if err != nil || !filepath.IsAbs(path) || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return types.Errorf(types.ErrorInvalidSpec, "the credentials path %s is not in the credentials directory %s", path, o.CredentialsDir)
+	}
+	return nil
+}
+
+// withDefaults sets the defaults of the provider config which are missing from the credentials
+func withDefaults(data map[string]string, spec *v1alpha1.ProviderConfigSpec) map[string]string {
+	defaults := map[string]string{}
+	switch spec.Provider {
+	case v1alpha1.ProviderAWS:
+		defaults[awsx.RegionName] = spec.Region
+	case v1alpha1.ProviderAzure:
+		// region is an alias of location
+		if _, ok := data["region"]; !ok {
+			defaults["location"] = spec.Location
+		}
+		defaults["resourceGroup"] = spec.ResourceGroup
+	case v1alpha1.ProviderGCP:
+		if _, ok := data["region"]; !ok {
+			defaults["location"] = spec.Location
+		}
+		defaults["project"] = spec.Project
+	}
+	for k, v := range defaults {
+		if v == "" {
+			continue
+		}
+		if _, ok := data[k]; ok {
+			continue
+		}
+		if data == nil {
+			data = map[string]string{}
+		}
+		data[k] = v
+	}
+	return data
+}
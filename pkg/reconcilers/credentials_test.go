@@ -0,0 +1,207 @@
+package reconcilers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+	"github.com/invisibl-cloud/identity-manager/pkg/options"
+	"github.com/invisibl-cloud/identity-manager/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLoadCredentials(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, corev1.AddToScheme(scheme))
+	require.Nil(t, v1alpha1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: "dev"},
+			Data:       map[string][]byte{"aws_access_key_id": []byte("dev-key")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: "identity-manager"},
+			Data:       map[string][]byte{"aws_access_key_id": []byte("shared-key"), "region": []byte("eu-west-1")},
+		},
+		&v1alpha1.ProviderConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: "dev"},
+			Spec: v1alpha1.ProviderConfigSpec{
+				Provider:    v1alpha1.ProviderAWS,
+				Credentials: &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceSecret, SecretRef: &v1alpha1.SecretRef{Name: "aws"}},
+				Region:      "us-east-1",
+			},
+		},
+		&v1alpha1.ClusterProviderConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws"},
+			Spec: v1alpha1.ProviderConfigSpec{
+				Provider:    v1alpha1.ProviderAWS,
+				Credentials: &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceSecret, SecretRef: &v1alpha1.SecretRef{Name: "aws", Namespace: "identity-manager"}},
+				Region:      "us-east-1",
+				NamePrefix:  "prod-",
+				Tags:        map[string]string{"env": "prod"},
+			},
+		},
+		&v1alpha1.ClusterProviderConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "no-namespace"},
+			Spec: v1alpha1.ProviderConfigSpec{
+				Provider:    v1alpha1.ProviderAWS,
+				Credentials: &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceSecret, SecretRef: &v1alpha1.SecretRef{Name: "aws"}},
+			},
+		},
+		&v1alpha1.ClusterProviderConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "gcp"},
+			Spec: v1alpha1.ProviderConfigSpec{
+				Provider: v1alpha1.ProviderGCP,
+				Project:  "my-project",
+				Location: "us-central1",
+			},
+		},
+	).Build()
+	secretCreds := &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceSecret, SecretRef: &v1alpha1.SecretRef{Name: "aws"}}
+
+	testCases := []struct {
+		desc             string
+		provider         v1alpha1.Provider
+		credentials      *v1alpha1.Credentials
+		ref              *v1alpha1.ProviderConfigRef
+		expected         map[string]string
+		expectedCategory types.ErrorCategory
+	}{
+		{
+			desc:     "Without credentials the environment is used",
+			provider: v1alpha1.ProviderAWS,
+		},
+		{
+			desc:        "The credentials of the spec are loaded from the namespace of the WorkloadIdentity",
+			provider:    v1alpha1.ProviderAWS,
+			credentials: secretCreds,
+			expected:    map[string]string{"aws_access_key_id": "dev-key"},
+		},
+		{
+			desc:        "The injected identity of the controller with the properties",
+			provider:    v1alpha1.ProviderAWS,
+			credentials: &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceInjectedIdentity, Properties: map[string]string{"role_arn": "arn:aws:iam::12345678:role/admin"}},
+			expected:    map[string]string{consts.CredentialsSourceKey: "InjectedIdentity", "role_arn": "arn:aws:iam::12345678:role/admin"},
+		},
+		{
+			desc:        "The file mounted into the controller",
+			provider:    v1alpha1.ProviderGCP,
+			credentials: &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceFilesystem, Path: "/etc/identity-manager/credentials/gcp/key.json"},
+			expected:    map[string]string{consts.CredentialsSourceKey: "Filesystem", consts.CredentialsPathKey: "/etc/identity-manager/credentials/gcp/key.json"},
+		},
+		{
+			desc:             "The file of the Filesystem source must be in the credentials directory",
+			provider:         v1alpha1.ProviderGCP,
+			credentials:      &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceFilesystem, Path: "/etc/identity-manager/credentials/../../passwd"},
+			expectedCategory: types.ErrorInvalidSpec,
+		},
+		{
+			desc:     "The file of the properties must be in the credentials directory",
+			provider: v1alpha1.ProviderGCP,
+			credentials: &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceInjectedIdentity, Properties: map[string]string{
+				consts.CredentialsSourceKey: "Filesystem",
+				consts.CredentialsPathKey:   "/var/run/secrets/kubernetes.io/serviceaccount/token",
+			}},
+			expectedCategory: types.ErrorInvalidSpec,
+		},
+		{
+			desc:             "The file of the Filesystem source is required",
+			provider:         v1alpha1.ProviderGCP,
+			credentials:      &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceFilesystem},
+			expectedCategory: types.ErrorInvalidSpec,
+		},
+		{
+			desc:     "A ProviderConfig loads its secret from its namespace with its region",
+			provider: v1alpha1.ProviderAWS,
+			ref:      &v1alpha1.ProviderConfigRef{Name: "aws"},
+			expected: map[string]string{"aws_access_key_id": "dev-key", "region": "us-east-1"},
+		},
+		{
+			desc:     "The region of the credentials takes precedence over the one of the ClusterProviderConfig",
+			provider: v1alpha1.ProviderAWS,
+			ref:      &v1alpha1.ProviderConfigRef{Kind: v1alpha1.ClusterProviderConfigKind, Name: "aws"},
+			expected: map[string]string{"aws_access_key_id": "shared-key", "region": "eu-west-1"},
+		},
+		{
+			desc:        "The credentials of the spec take precedence over the ones of the provider config",
+			provider:    v1alpha1.ProviderAWS,
+			credentials: secretCreds,
+			ref:         &v1alpha1.ProviderConfigRef{Kind: v1alpha1.ClusterProviderConfigKind, Name: "aws"},
+			expected:    map[string]string{"aws_access_key_id": "dev-key", "region": "us-east-1"},
+		},
+		{
+			desc:     "The defaults of a ClusterProviderConfig without credentials",
+			provider: v1alpha1.ProviderGCP,
+			ref:      &v1alpha1.ProviderConfigRef{Kind: v1alpha1.ClusterProviderConfigKind, Name: "gcp"},
+			expected: map[string]string{"project": "my-project", "location": "us-central1"},
+		},
+		{
+			desc:             "The secret of a ClusterProviderConfig requires a namespace",
+			provider:         v1alpha1.ProviderAWS,
+			ref:              &v1alpha1.ProviderConfigRef{Kind: v1alpha1.ClusterProviderConfigKind, Name: "no-namespace"},
+			expectedCategory: types.ErrorInvalidSpec,
+		},
+		{
+			desc:             "The provider of the provider config must match",
+			provider:         v1alpha1.ProviderAzure,
+			ref:              &v1alpha1.ProviderConfigRef{Kind: v1alpha1.ClusterProviderConfigKind, Name: "aws"},
+			expectedCategory: types.ErrorInvalidSpec,
+		},
+		{
+			desc:             "A missing provider config is a missing dependency",
+			provider:         v1alpha1.ProviderAWS,
+			ref:              &v1alpha1.ProviderConfigRef{Name: "missing"},
+			expectedCategory: types.ErrorDependencyMissing,
+		},
+	}
+	for _, testCase := range testCases {
+		res := &v1alpha1.WorkloadIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "dev"},
+			Spec: v1alpha1.WorkloadIdentitySpec{
+				Provider:          testCase.provider,
+				Credentials:       testCase.credentials,
+				ProviderConfigRef: testCase.ref,
+			},
+		}
+		creds, err := LoadCredentials(context.Background(), c, res, &options.Options{CredentialsDir: "/etc/identity-manager/credentials"})
+		if testCase.expectedCategory != "" {
+			assert.Equal(t, testCase.expectedCategory, types.Category(err), testCase.desc)
+			continue
+		}
+		require.Nil(t, err, testCase.desc)
+		assert.Equal(t, testCase.expected, creds.Data, testCase.desc)
+	}
+}
+
+func TestCredentialsOptions(t *testing.T) {
+	o := &options.Options{NamePrefix: "dev-", Tags: map[string]string{"team": "platform", "env": "dev"}}
+	creds := &Credentials{Config: &v1alpha1.ProviderConfigSpec{NamePrefix: "prod-", Tags: map[string]string{"env": "prod"}}}
+
+	merged := creds.Options(o)
+	assert.Equal(t, "prod-", merged.NamePrefix)
+	assert.Equal(t, map[string]string{"team": "platform", "env": "prod"}, map[string]string(merged.Tags))
+	// the options of the manager are unchanged
+	assert.Equal(t, "dev-", o.NamePrefix)
+	assert.Equal(t, "dev", o.Tags["env"])
+	// without provider config the options of the manager are used
+	assert.Same(t, o, (&Credentials{}).Options(o))
+}
+
+func TestCheckCredentialsPath(t *testing.T) {
+	o := &options.Options{CredentialsDir: "/etc/identity-manager/credentials/"}
+	assert.Nil(t, checkCredentialsPath("/etc/identity-manager/credentials/aws/token", o))
+	assert.Nil(t, checkCredentialsPath("/etc/identity-manager/credentials/./key.json", o))
+	assert.NotNil(t, checkCredentialsPath("/etc/identity-manager/credentials", o))
+	assert.NotNil(t, checkCredentialsPath("/etc/identity-manager/credentials-other/key.json", o))
+	assert.NotNil(t, checkCredentialsPath("/etc/identity-manager/credentials/../token", o))
+	assert.NotNil(t, checkCredentialsPath("credentials/key.json", o))
+	// the source is disabled without a credentials directory
+	assert.NotNil(t, checkCredentialsPath("/etc/identity-manager/credentials/key.json", &options.Options{}))
+	assert.NotNil(t, checkCredentialsPath("/etc/identity-manager/credentials/key.json", nil))
+}
@@ -3,12 +3,15 @@ package gcp
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/drift"
+	"github.com/invisibl-cloud/identity-manager/pkg/options"
+	"github.com/invisibl-cloud/identity-manager/pkg/plan"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/gcpx"
 	"github.com/invisibl-cloud/identity-manager/pkg/providers/gcpx/iam"
 	"github.com/invisibl-cloud/identity-manager/pkg/reconcilers"
+	imtypes "github.com/invisibl-cloud/identity-manager/pkg/types"
 	"github.com/invisibl-cloud/identity-manager/pkg/util"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -29,6 +32,8 @@ type IdentityReconciler struct {
 	client.Client
 	scheme *runtime.Scheme
 	res    *v1alpha1.WorkloadIdentity
+	// options of the manager
+	options *options.Options
 	// internal
 	debug bool
 	gcpx  *gcpx.Client
@@ -38,9 +43,10 @@ type IdentityReconciler struct {
 // NewReconciler initializes IdentityReconciler
 func NewReconciler(base *reconcilers.ReconcilerBase, res *v1alpha1.WorkloadIdentity) *IdentityReconciler {
 	return &IdentityReconciler{
-		Client: base.Client(),
-		scheme: base.Scheme(),
-		res:    res,
+		Client:  base.Client(),
+		scheme:  base.Scheme(),
+		res:     res,
+		options: base.Options(),
 	}
 }
 
@@ -51,37 +57,43 @@ func (r *IdentityReconciler) Prepare(ctx context.Context) error {
 		return err
 	}
 	r.gcpx = c
-	r.iamx = iam.New(r.gcpx)
+	// in dry-run mode, the changes are recorded into the plan instead of being applied
+	p := plan.FromContext(ctx)
+	if p != nil {
+		r.Client = plan.NewClient(r.Client, p)
+	}
+	// changes of the defaults of the provider config are not drift
+	err = drift.FromContext(ctx).SetInputs(c.GetConfig().Project, c.GetConfig().Location)
+	if err != nil {
+		return err
+	}
+	r.iamx = iam.New(r.gcpx).WithPlan(p).WithDrift(drift.FromContext(ctx))
 	r.debug = r.res.Annotations["debug"] == "true"
 	return nil
 }
 
 func (r *IdentityReconciler) getGCP(ctx context.Context) (*gcpx.Client, error) {
-	creds := r.res.Spec.Credentials
-	if creds == nil {
+	creds, err := reconcilers.LoadCredentials(ctx, r.Client, r.res, r.options)
+	if err != nil {
+		return nil, err
+	}
+	if creds.Data == nil {
 		return gcpx.New(gcpx.WithEnv())
 	}
-	configMap := map[string]any{}
-	switch creds.Source {
-	case v1alpha1.CredentialsSourceSecret:
-		if creds.SecretRef == nil {
-			return nil, fmt.Errorf("missing secretRef for credentials")
-		}
-		secret := &corev1.Secret{}
-		secret.Name = util.DefaultString(creds.SecretRef.Name, r.res.Name)
-		secret.Namespace = util.DefaultString(creds.SecretRef.Namespace, r.res.Namespace)
-		err := r.Get(ctx, client.ObjectKeyFromObject(secret), secret)
-		if err != nil {
-			return nil, err
-		}
-		for k, v := range secret.Data {
-			configMap[k] = string(v)
-		}
+	return gcpx.New(gcpx.WithEnv(), gcpx.WithConfigMap(creds.ConfigMap()))
+}
+
+// ValidateCredentials checks that the credentials authenticate, by fetching a token
+func ValidateCredentials(ctx context.Context, creds *reconcilers.Credentials) error {
+	c, err := gcpx.New(gcpx.WithEnv(), gcpx.WithConfigMap(creds.ConfigMap()))
+	if err != nil {
+		return err
 	}
-	for k, v := range creds.Properties {
-		configMap[k] = v
+	_, err = c.GetCredentials().TokenSource.Token()
+	if err != nil {
+		return imtypes.Errorf(imtypes.ErrorAuthFailed, "error getting token - %w", err)
 	}
-	return gcpx.New(gcpx.WithEnv(), gcpx.WithConfigMap(configMap))
+	return nil
 }
 
 // Reconcile reconciles the workload identity
@@ -89,7 +101,7 @@ func (r *IdentityReconciler) Reconcile(ctx context.Context) error {
 	// reconcile
 	err := r.doReconcile(ctx)
 	if err != nil {
-		return err
+		return gcpx.ToError(err)
 	}
 	if r.res.Status.ID == "" {
 		return fmt.Errorf("waiting for identity to be created")
@@ -101,14 +113,28 @@ func (r *IdentityReconciler) doReconcile(ctx context.Context) error {
 	if r.res.Spec.GCP == nil {
 		return fmt.Errorf("missing gcp spec")
 	}
+	// the service account is only read, the kubernetes resources are still reconciled
+	if reconcilers.IsObserveOnly(r.res) {
+		return r.doObserve(ctx)
+	}
 
-	// serviceAccount name
-	name := r.res.Status.Name
-	if name == "" {
-		name = util.DefaultString(r.res.Spec.Name, r.res.Name)
-		if len(name) > 30 {
-			uid := strings.ReplaceAll(string(r.res.UID), "-", "")
-			name = name[0:20] + uid[20:30]
+	name := r.serviceAccountName()
+	// service accounts are never renamed, the existing service account is kept
+	err := imtypes.CheckRename(r.res.Status.Name, name)
+	if err != nil {
+		return err
+	}
+	// service accounts which were not created by the controller are only managed once adopted
+	if r.res.Status.Name == "" {
+		exists, err := r.iamx.ServiceAccountExists(ctx, name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			err = imtypes.CheckExists(r.res.Status.Name, name, reconcilers.IsAdopt(r.res))
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -121,29 +147,72 @@ func (r *IdentityReconciler) doReconcile(ctx context.Context) error {
 		r.res.Spec.GCP.Roles,
 		"",
 	)
+	// the service account is recorded as soon as it is created, so that it is not mistaken on retry
+	// for an existing service account when its roles could not be granted
+	if id != "" {
+		r.res.Status.ID = id
+		r.res.Status.Name = name
+	}
 	if err != nil {
 		return err
 	}
-	//log := log.FromContext(ctx)
-	if id != "" {
-		r.res.Status.ID = id
+	return r.doActions(ctx)
+}
+
+func (r *IdentityReconciler) doObserve(ctx context.Context) error {
+	name := util.DefaultString(r.res.Status.Name, r.serviceAccountName())
+	email, roles, err := r.iamx.ObserveServiceAccount(ctx, name, "")
+	if err != nil {
+		return err
 	}
+	r.res.Status.ID = email
 	r.res.Status.Name = name
+	r.res.Status.Observed = &v1alpha1.ObservedIdentity{Email: email, Roles: roles}
 	return r.doActions(ctx)
 }
 
+// serviceAccountName returns the name of the service account, shortened to 30 characters with the uid
+func (r *IdentityReconciler) serviceAccountName() string {
+	return gcpx.ServiceAccountName(r.res)
+}
+
 // Finalize implements Finalizer interface
 func (r *IdentityReconciler) Finalize(ctx context.Context) error {
-	if r.res.Spec.GCP == nil {
+	return gcpx.ToError(r.finalize(ctx))
+}
+
+func (r *IdentityReconciler) finalize(ctx context.Context) error {
+	// observed and adopted service accounts are left untouched
+	if r.res.Spec.GCP == nil || reconcilers.IsRetained(r.res) {
 		return nil
 	}
 	err := r.iamx.DeleteServiceAccount(ctx, r.res.Status.ID)
 	if err != nil {
 		return err
 	}
+	if r.res.Status.Name == "" {
+		return nil
+	}
+	// wait for the service account to be gone
+	exists, err := r.iamx.ServiceAccountExists(ctx, r.res.Status.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return imtypes.ErrDeleting
+	}
 	return nil
 }
 
+// Ready implements ReadyReconciler interface
+func (r *IdentityReconciler) Ready(ctx context.Context) error {
+	err := r.iamx.CheckWorkloadIdentityUser(ctx, r.res.Status.Name, r.res.Namespace, r.res.Spec.GCP.ServiceAccounts)
+	if err != nil {
+		return err
+	}
+	return reconcilers.CheckServiceAccounts(ctx, r.Client, r.res, r.res.Spec.GCP.ServiceAccounts, serviceAccountAnnotationKey, r.res.Status.ID)
+}
+
 func (r *IdentityReconciler) doActions(ctx context.Context) error {
 	// reconcile serviceaccount
 	for _, sa := range r.res.Spec.GCP.ServiceAccounts {
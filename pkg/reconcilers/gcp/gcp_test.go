@@ -0,0 +1,113 @@
+package gcp
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"cloud.google.com/go/iam/admin/apiv1/adminpb"
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/resourcemanager/apiv3/resourcemanagerpb"
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/gcpx"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/gcpx/iam"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeIAM is the IAM service of a project whose service accounts are kept in memory
+type fakeIAM struct {
+	adminpb.UnimplementedIAMServer
+	accounts map[string]*adminpb.ServiceAccount
+	creates  int
+}
+
+func (s *fakeIAM) GetServiceAccount(_ context.Context, req *adminpb.GetServiceAccountRequest) (*adminpb.ServiceAccount, error) {
+	sa, ok := s.accounts[req.Name]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "service account not found")
+	}
+	return sa, nil
+}
+
+func (s *fakeIAM) CreateServiceAccount(_ context.Context, req *adminpb.CreateServiceAccountRequest) (*adminpb.ServiceAccount, error) {
+	s.creates++
+	email := req.AccountId + "@my-project.iam.gserviceaccount.com"
+	sa := &adminpb.ServiceAccount{Name: req.Name + "/serviceAccounts/" + email, Email: email, DisplayName: req.ServiceAccount.DisplayName}
+	s.accounts[sa.Name] = sa
+	return sa, nil
+}
+
+// fakeProjects is the resource manager of a project whose policy cannot be read at first
+type fakeProjects struct {
+	resourcemanagerpb.UnimplementedProjectsServer
+	fails  int
+	policy *iampb.Policy
+}
+
+func (s *fakeProjects) GetIamPolicy(context.Context, *iampb.GetIamPolicyRequest) (*iampb.Policy, error) {
+	if s.fails > 0 {
+		s.fails--
+		return nil, status.Error(codes.PermissionDenied, "missing resourcemanager.projects.getIamPolicy")
+	}
+	return s.policy, nil
+}
+
+func (s *fakeProjects) SetIamPolicy(_ context.Context, req *iampb.SetIamPolicyRequest) (*iampb.Policy, error) {
+	s.policy = req.Policy
+	return s.policy, nil
+}
+
+func TestReconcilePartialCreate(t *testing.T) {
+	iamServer := &fakeIAM{accounts: map[string]*adminpb.ServiceAccount{}}
+	projects := &fakeProjects{fails: 1, policy: &iampb.Policy{}}
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	adminpb.RegisterIAMServer(server, iamServer)
+	resourcemanagerpb.RegisterProjectsServer(server, projects)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.Nil(t, err)
+	defer conn.Close()
+
+	c, err := gcpx.New(
+		gcpx.WithConfig(&gcpx.Config{
+			Project:     "my-project",
+			Credentials: `{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"token"}`,
+		}),
+		gcpx.WithClientOptions(option.WithGRPCConn(conn)),
+	)
+	require.Nil(t, err)
+	res := &v1alpha1.WorkloadIdentity{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "dev"},
+		Spec: v1alpha1.WorkloadIdentitySpec{
+			Provider: v1alpha1.ProviderGCP,
+			GCP:      &v1alpha1.WorkloadIdentityGCP{Roles: []string{"roles/storage.objectViewer"}},
+		},
+	}
+	r := &IdentityReconciler{res: res, iamx: iam.New(c)}
+
+	// the service account is created, but its roles cannot be granted
+	err = r.doReconcile(context.Background())
+	require.NotNil(t, err)
+	assert.Equal(t, "demo", res.Status.Name)
+	assert.Equal(t, "demo@my-project.iam.gserviceaccount.com", res.Status.ID)
+
+	// the retry syncs the service account it created instead of asking to adopt it
+	err = r.doReconcile(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, 1, iamServer.creates)
+	require.Len(t, projects.policy.Bindings, 1)
+	assert.Equal(t, []string{"serviceAccount:demo@my-project.iam.gserviceaccount.com"}, projects.policy.Bindings[0].Members)
+}
@@ -0,0 +1,34 @@
+package reconcilers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CheckServiceAccounts checks whether the service accounts created or updated for the resource
+// are annotated with the identity
+func CheckServiceAccounts(ctx context.Context, c client.Reader, res metav1.Object, sas []*v1alpha1.ServiceAccount, key string, value string) error {
+	for _, sa := range sas {
+		if sa.Action == v1alpha1.ServiceAccountActionDefault {
+			continue
+		}
+		name := util.DefaultString(sa.Name, res.GetName())
+		namespace := util.DefaultString(sa.Namespace, res.GetNamespace())
+		obj := &corev1.ServiceAccount{}
+		err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, obj)
+		if err != nil {
+			return fmt.Errorf("error getting serviceaccount %s/%s - %w", namespace, name, err)
+		}
+		if obj.Annotations[key] != value {
+			return fmt.Errorf("serviceaccount %s/%s is not annotated with %s", namespace, name, key)
+		}
+	}
+	return nil
+}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"crypto/rand"
 	"math/big"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
 	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+	"github.com/invisibl-cloud/identity-manager/pkg/drift"
+	"github.com/invisibl-cloud/identity-manager/pkg/plan"
 	"github.com/invisibl-cloud/identity-manager/pkg/types"
 	"github.com/invisibl-cloud/identity-manager/pkg/util"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -62,12 +67,14 @@ type reconciler struct {
 	// internal
 	specCopy   any
 	statusCopy any
+	planStatus any
 }
 
 // Reconcile implements Reconciler
 func (r *reconciler) Reconcile(ctx context.Context) (ctrl.Result, error) {
 	r.specCopy = r.res.GetSpecCopy()
 	r.statusCopy = r.res.GetStatusCopy()
+	r.planStatus = r.res.GetStatusCopy()
 	// Check if the instance is marked to be deleted, which is
 	// indicated by the deletion timestamp being set.
 	if r.res.GetDeletionTimestamp() != nil {
@@ -93,26 +100,46 @@ func (r *reconciler) doDelete(ctx context.Context) (ctrl.Result, error) {
 		return r.removeFinalizer(ctx)
 	}
 	// if marked as orphan, return
-	isOrphan := r.res.GetAnnotations()[consts.DeletePolicyKey] == consts.OrphanValue
-	if isOrphan {
+	if DeletePolicy(r.res) == consts.OrphanValue {
 		return r.removeFinalizer(ctx)
 	}
+	// in dry-run mode, the finalizer is kept until the deletion is applied
+	if res, ok := r.dryRunResource(); ok {
+		p := plan.New()
+		err := r.finalize(plan.NewContext(ctx, p))
+		// nothing is deleted while planning, there is nothing to wait for
+		if types.IsDeleting(err) {
+			err = nil
+		}
+		return r.doPlan(ctx, res, p, err)
+	}
+	if cs := r.res.GetConditionedStatus(); cs != nil {
+		cs.SetConditions(v1alpha1.Deleting())
+	}
+	err := r.finalize(ctx)
+	if types.IsDeleting(err) {
+		// poll until the identity is gone
+		return r.doReturn(ctx, deletePollInterval)
+	}
+	if err != nil {
+		return r.doStatus(ctx, err)
+	}
+	return r.removeFinalizer(ctx)
+}
+
+func (r *reconciler) finalize(ctx context.Context) error {
 	// PreFinalize
 	switch rec1 := r.rec.(type) {
 	case types.FinalizeReconciler:
 		err := rec1.PreFinalize(ctx)
 		if err != nil {
-			return r.doStatus(ctx, err)
+			return err
 		}
 	}
 	// Run finalization logic for FinalizerAnnotationKey. If the
 	// finalization logic fails, don't remove the finalizer so
 	// that we can retry during the next reconciliation.
-	if err := r.rec.Finalize(ctx); err != nil {
-		//return ctrl.Result{}, err
-		return r.doStatus(ctx, err)
-	}
-	return r.removeFinalizer(ctx)
+	return r.rec.Finalize(ctx)
 }
 
 func (r *reconciler) removeFinalizer(ctx context.Context) (ctrl.Result, error) {
@@ -129,7 +156,118 @@ func (r *reconciler) removeFinalizer(ctx context.Context) (ctrl.Result, error) {
 }
 
 func (r *reconciler) doReconcile(ctx context.Context) (ctrl.Result, error) {
-	return r.doStatus(ctx, r.rec.Reconcile(ctx))
+	if res, ok := r.dryRunResource(); ok {
+		p := plan.New()
+		return r.doPlan(ctx, res, p, r.rec.Reconcile(plan.NewContext(ctx, p)))
+	}
+	// the plan is only kept while in dry-run mode
+	if res, ok := r.res.(types.PlanResource); ok {
+		res.SetPlan(nil)
+	}
+	if res, ok := r.res.(types.DriftResource); ok {
+		return r.doDrift(ctx, res)
+	}
+	return r.doStatus(ctx, r.reconcile(ctx))
+}
+
+// reconcile calls the reconciler and sets the Ready condition
+func (r *reconciler) reconcile(ctx context.Context) error {
+	err := r.rec.Reconcile(ctx)
+	r.setReady(ctx, err)
+	return err
+}
+
+// setReady sets the Ready condition from the availability of the identity, once reconciled
+func (r *reconciler) setReady(ctx context.Context, err error) {
+	cs := r.res.GetConditionedStatus()
+	if cs == nil {
+		return
+	}
+	if err != nil {
+		// the availability is only known once reconciled
+		if cs.GetCondition(v1alpha1.TypeReady).Status == corev1.ConditionUnknown {
+			cs.SetConditions(v1alpha1.Creating())
+		}
+		return
+	}
+	if rec, ok := r.rec.(types.ReadyReconciler); ok {
+		if err := rec.Ready(ctx); err != nil {
+			cs.SetConditions(v1alpha1.Unavailable().WithMessage(err.Error()))
+			return
+		}
+	}
+	cs.SetConditions(v1alpha1.Available())
+}
+
+// doDrift reconciles and reports the drift reverted by the reconciler. The changes applied are only
+// drift when the spec and the inputs resolved outside of it, recorded by the reconciler into the
+// report, did not change since the last successful reconciliation.
+func (r *reconciler) doDrift(ctx context.Context, res types.DriftResource) (ctrl.Result, error) {
+	generation := r.res.GetGeneration()
+	report := drift.New()
+	err := r.reconcile(drift.NewContext(ctx, report))
+	changed := res.GetObservedGeneration() != generation || res.GetObservedInputs() != report.Inputs()
+	if err == nil {
+		res.SetObservedGeneration(generation)
+		res.SetObservedInputs(report.Inputs())
+	}
+	if changed {
+		return r.doStatus(ctx, err)
+	}
+	items := report.Items()
+	cs := r.res.GetConditionedStatus()
+	if len(items) > 0 {
+		changes := make([]string, len(items))
+		for i, item := range items {
+			changes[i] = drift.String(item)
+			if r.base.recorder != nil {
+				r.base.recorder.Eventf(r.res, corev1.EventTypeWarning, EventReasonDrifted, "%s outside of identity-manager", changes[i])
+			}
+		}
+		res.SetDrift(items)
+		if cs != nil {
+			cs.SetConditions(v1alpha1.Drifted(strings.Join(changes, ", ")))
+		}
+	} else if err == nil && cs != nil {
+		cs.SetConditions(v1alpha1.NoDrift())
+	}
+	return r.doStatus(ctx, err)
+}
+
+// dryRunResource returns the resource when it supports and is set to dry-run mode
+func (r *reconciler) dryRunResource() (types.PlanResource, bool) {
+	res, ok := r.res.(types.PlanResource)
+	if !ok {
+		return nil, false
+	}
+	return res, IsDryRun(r.res) || (r.base.Options() != nil && r.base.Options().DryRun)
+}
+
+// doPlan publishes the changes of the plan into the status and the events.
+// The status reconciled while planning is discarded, only its conditions are kept.
+func (r *reconciler) doPlan(ctx context.Context, res types.PlanResource, p *plan.Plan, err error) (ctrl.Result, error) {
+	var conditions []v1alpha1.Condition
+	if cs := r.res.GetConditionedStatus(); cs != nil {
+		conditions = cs.Conditions
+	}
+	reflect.ValueOf(r.res.GetStatus()).Elem().Set(reflect.ValueOf(r.planStatus).Elem())
+	if cs := r.res.GetConditionedStatus(); cs != nil {
+		cs.SetConditions(conditions...)
+	}
+	changes := p.Changes()
+	if !equality.Semantic.DeepEqual(res.GetPlan(), changes) && r.base.recorder != nil {
+		if len(changes) == 0 {
+			r.base.recorder.Event(r.res, corev1.EventTypeNormal, EventReasonDryRun, "no changes planned")
+		}
+		for _, change := range changes {
+			r.base.recorder.Event(r.res, corev1.EventTypeNormal, EventReasonDryRun, change)
+		}
+	}
+	res.SetPlan(changes)
+	if err != nil {
+		return r.doStatus(ctx, err)
+	}
+	return r.doStatus(ctx, v1alpha1.DryRun(len(changes)))
 }
 
 func (r *reconciler) getRequeueAfter(requeueAfter time.Duration) time.Duration {
@@ -190,8 +328,11 @@ func (r *reconciler) doStatus(ctx context.Context, err error) (ctrl.Result, erro
 			c = cr
 		case *v1alpha1.Condition:
 			c = *cr
-		default: // default error
+		default: // default error, with the reason of its category if any
 			c = v1alpha1.ReconcileError(err)
+			if category := types.Category(err); category != "" {
+				c.Reason = v1alpha1.ConditionReason(category)
+			}
 		}
 	} else { // onsuccess
 		c = v1alpha1.ReconcileSuccess()
@@ -216,10 +357,54 @@ func CanIgnore(o metav1.Object) bool {
 	return false
 }
 
+// IsAdopt checks whether adopt annotation is set to true.
+func IsAdopt(o metav1.Object) bool {
+	return util.Contains(trueValues, o.GetAnnotations()[consts.AdoptKey])
+}
+
+// IsObserveOnly checks whether management-policy annotation is set to ObserveOnly.
+func IsObserveOnly(o metav1.Object) bool {
+	return o.GetAnnotations()[consts.ManagementPolicyKey] == consts.ObserveOnlyValue
+}
+
+// DeletePolicy returns the delete-policy annotation, RetainOnImport when not set or unknown.
+func DeletePolicy(o metav1.Object) string {
+	switch policy := o.GetAnnotations()[consts.DeletePolicyKey]; policy {
+	case consts.DeleteValue, consts.OrphanValue:
+		return policy
+	}
+	return consts.RetainOnImportValue
+}
+
+// IsImported checks whether the identity was not created by identity-manager, i.e. imported or adopted.
+func IsImported(o metav1.Object) bool {
+	return o.GetAnnotations()[consts.ImportKey] != "" || IsAdopt(o)
+}
+
+// IsRetained checks whether the cloud identity is kept when the resource is deleted: observed
+// identities are never deleted, imported identities only with the Delete policy.
+func IsRetained(o metav1.Object) bool {
+	return IsObserveOnly(o) || (IsImported(o) && DeletePolicy(o) != consts.DeleteValue)
+}
+
+// IsDryRun checks whether dry-run annotation is set to true.
+func IsDryRun(o metav1.Object) bool {
+	return util.Contains(trueValues, o.GetAnnotations()[consts.DryRunKey])
+}
+
 var (
-	// trueValues  = []string{"true", "1", "enabled", "yes", "on", "enable", "active", "ok"}
+	trueValues  = []string{"true", "1", "enabled", "yes", "on", "enable", "active", "ok"}
 	falseValues = []string{"false", "0", "disabled", "no", "off", "disable", "inactive"}
 )
 
 // ReconcilerKey indicates reconciler annotation key
 const ReconcilerKey = "identity-manager.io/reconciler"
+
+// deletePollInterval is the interval in seconds at which the deletion of an identity is polled
+const deletePollInterval = 10
+
+// EventReasonDryRun is the reason of the events of the planned changes
+const EventReasonDryRun = "DryRun"
+
+// EventReasonDrifted is the reason of the events of the drift
+const EventReasonDrifted = "Drifted"
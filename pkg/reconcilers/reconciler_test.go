@@ -0,0 +1,143 @@
+package reconcilers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
+	"github.com/invisibl-cloud/identity-manager/pkg/drift"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestIsRetained(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		annotations    map[string]string
+		expectedPolicy string
+		expected       bool
+	}{
+		{
+			desc:           "A created identity is deleted",
+			expectedPolicy: consts.RetainOnImportValue,
+		},
+		{
+			desc:           "An imported identity is retained",
+			annotations:    map[string]string{consts.ImportKey: "arn:aws:iam::12345678:role/existing"},
+			expectedPolicy: consts.RetainOnImportValue,
+			expected:       true,
+		},
+		{
+			desc:           "An adopted identity is retained",
+			annotations:    map[string]string{consts.AdoptKey: "true"},
+			expectedPolicy: consts.RetainOnImportValue,
+			expected:       true,
+		},
+		{
+			desc:           "An imported identity is deleted with the Delete policy",
+			annotations:    map[string]string{consts.ImportKey: "arn:aws:iam::12345678:role/existing", consts.DeletePolicyKey: consts.DeleteValue},
+			expectedPolicy: consts.DeleteValue,
+		},
+		{
+			desc:           "An observed identity is retained with the Delete policy",
+			annotations:    map[string]string{consts.ManagementPolicyKey: consts.ObserveOnlyValue, consts.DeletePolicyKey: consts.DeleteValue},
+			expectedPolicy: consts.DeleteValue,
+			expected:       true,
+		},
+		{
+			desc:           "An unknown policy is RetainOnImport",
+			annotations:    map[string]string{consts.DeletePolicyKey: "Retain"},
+			expectedPolicy: consts.RetainOnImportValue,
+		},
+	}
+	for _, testCase := range testCases {
+		o := &metav1.ObjectMeta{Annotations: testCase.annotations}
+		assert.Equal(t, testCase.expectedPolicy, DeletePolicy(o), testCase.desc)
+		assert.Equal(t, testCase.expected, IsRetained(o), testCase.desc)
+	}
+}
+
+// policyReconciler applies the policy of a ConfigMap to a fake cloud identity, reverting its drift
+type policyReconciler struct {
+	client client.Client
+	policy string
+}
+
+func (r *policyReconciler) Reconcile(ctx context.Context) error {
+	cm := &corev1.ConfigMap{}
+	err := r.client.Get(ctx, ktypes.NamespacedName{Name: "policy", Namespace: "dev"}, cm)
+	if err != nil {
+		return err
+	}
+	report := drift.FromContext(ctx)
+	err = report.SetInputs(cm.Data)
+	if err != nil {
+		return err
+	}
+	if r.policy != cm.Data["policy"] {
+		report.Add(drift.TypeInlinePolicy, "policy", v1alpha1.DriftChangeModified)
+		r.policy = cm.Data["policy"]
+	}
+	return nil
+}
+
+func (r *policyReconciler) Finalize(context.Context) error {
+	return nil
+}
+
+func TestReconcileDrift(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.Nil(t, corev1.AddToScheme(scheme))
+	require.Nil(t, v1alpha1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "dev"},
+			Data:       map[string]string{"policy": "s3:GetObject"},
+		},
+		&v1alpha1.WorkloadIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "dev", Generation: 1},
+		},
+	).Build()
+	recorder := record.NewFakeRecorder(10)
+	base := &ReconcilerBase{client: c, scheme: scheme, recorder: recorder}
+	rec := &policyReconciler{client: c}
+	req := ctrl.Request{NamespacedName: ktypes.NamespacedName{Name: "demo", Namespace: "dev"}}
+	reconcile := func() *v1alpha1.WorkloadIdentity {
+		res := &v1alpha1.WorkloadIdentity{}
+		_, err := Reconcile(context.Background(), base, req, res, rec)
+		require.Nil(t, err)
+		require.Nil(t, c.Get(context.Background(), req.NamespacedName, res))
+		return res
+	}
+
+	// the first reconciliation applies the spec
+	res := reconcile()
+	assert.Equal(t, int64(1), res.Status.ObservedGeneration)
+	assert.NotEmpty(t, res.Status.ObservedInputs)
+	assert.Empty(t, res.Status.Drift)
+
+	// the change of the referenced ConfigMap is applied, it is not drift
+	cm := &corev1.ConfigMap{}
+	require.Nil(t, c.Get(context.Background(), ktypes.NamespacedName{Name: "policy", Namespace: "dev"}, cm))
+	cm.Data["policy"] = "s3:*"
+	require.Nil(t, c.Update(context.Background(), cm))
+	res = reconcile()
+	assert.Empty(t, res.Status.Drift)
+	assert.Empty(t, recorder.Events)
+
+	// the change of the identity outside of the controller is drift
+	rec.policy = "*"
+	res = reconcile()
+	assert.Equal(t, []v1alpha1.Drift{{Type: drift.TypeInlinePolicy, Name: "policy", Change: v1alpha1.DriftChangeModified}}, res.Status.Drift)
+	assert.Equal(t, corev1.ConditionTrue, res.Status.GetCondition(v1alpha1.TypeDrifted).Status)
+	assert.Len(t, recorder.Events, 1)
+}
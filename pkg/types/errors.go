@@ -0,0 +1,92 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrorCategory categorizes the errors of the cloud providers. The category is the reason of the Synced condition.
+type ErrorCategory string
+
+// Error categories.
+const (
+	// ErrorAuthFailed is returned when the credentials are missing, invalid or expired
+	ErrorAuthFailed ErrorCategory = "AuthFailed"
+	// ErrorPermissionDenied is returned when the credentials are not allowed to perform the operation
+	ErrorPermissionDenied ErrorCategory = "PermissionDenied"
+	// ErrorThrottled is returned when the requests are rate limited
+	ErrorThrottled ErrorCategory = "Throttled"
+	// ErrorInvalidSpec is returned when the spec is rejected
+	ErrorInvalidSpec ErrorCategory = "InvalidSpec"
+	// ErrorDependencyMissing is returned when a resource referenced by the spec does not exist
+	ErrorDependencyMissing ErrorCategory = "DependencyMissing"
+	// ErrorQuotaExceeded is returned when a quota or a limit of the account is reached
+	ErrorQuotaExceeded ErrorCategory = "QuotaExceeded"
+	// ErrorConflict is returned when the resource conflicts with an existing or concurrently modified resource
+	ErrorConflict ErrorCategory = "Conflict"
+)
+
+// HTTPErrorCategory returns the category of the http status code, empty when not categorized
+func HTTPErrorCategory(statusCode int) ErrorCategory {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return ErrorInvalidSpec
+	case http.StatusUnauthorized:
+		return ErrorAuthFailed
+	case http.StatusForbidden:
+		return ErrorPermissionDenied
+	case http.StatusNotFound:
+		return ErrorDependencyMissing
+	case http.StatusConflict:
+		return ErrorConflict
+	case http.StatusTooManyRequests:
+		return ErrorThrottled
+	}
+	return ""
+}
+
+// Error is a categorized error, with the request id of the cloud provider if any
+type Error struct {
+	Category  ErrorCategory
+	Message   string
+	RequestID string
+	Err       error
+}
+
+// NewError returns a categorized error, the message is the one of err
+func NewError(category ErrorCategory, err error) *Error {
+	return &Error{Category: category, Message: err.Error(), Err: err}
+}
+
+// Errorf returns a categorized error with a formatted message
+func Errorf(category ErrorCategory, format string, args ...any) *Error {
+	return NewError(category, fmt.Errorf(format, args...))
+}
+
+// WithRequestID sets the request id of the cloud provider, for support tickets
+func (e *Error) WithRequestID(requestID string) *Error {
+	e.RequestID = requestID
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.RequestID == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (request id: %s)", e.Message, e.RequestID)
+}
+
+// Unwrap returns the original error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Category returns the category of the error, empty when not categorized
+func Category(err error) ErrorCategory {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Category
+	}
+	return ""
+}
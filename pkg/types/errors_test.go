@@ -0,0 +1,41 @@
+package types
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		err              error
+		expectedCategory ErrorCategory
+		expectedMessage  string
+	}{
+		{
+			desc:            "An uncategorized error",
+			err:             errors.New("failed"),
+			expectedMessage: "failed",
+		},
+		{
+			desc:             "A categorized error with a request id",
+			err:              Errorf(ErrorPermissionDenied, "not allowed").WithRequestID("1234"),
+			expectedCategory: ErrorPermissionDenied,
+			expectedMessage:  "not allowed (request id: 1234)",
+		},
+		{
+			desc:             "A wrapped categorized error",
+			err:              CheckRename("a", "b"),
+			expectedCategory: ErrorInvalidSpec,
+			expectedMessage:  "renaming the identity is not supported: from a to b, revert spec.name or delete and recreate the WorkloadIdentity",
+		},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expectedCategory, Category(testCase.err), testCase.desc)
+		assert.Equal(t, testCase.expectedMessage, testCase.err.Error(), testCase.desc)
+	}
+	assert.ErrorIs(t, CheckExists("", "a", false), ErrExists)
+	assert.Equal(t, ErrorConflict, Category(CheckExists("", "a", false)))
+}
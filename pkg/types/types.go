@@ -5,18 +5,59 @@ import (
 	"errors"
 
 	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/consts"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // ErrIgnore defines the ignorable error
 var ErrIgnore = errors.New("IgnoreError")
 
+// ErrRename is returned when the name of an existing identity would change.
+// Identities are never renamed in place: the name is part of the identity (role arn,
+// client id, service account email) referenced by workloads and policies.
+var ErrRename = errors.New("renaming the identity is not supported")
+
+// ErrExists is returned when an identity with the desired name already exists, and was not
+// created by the controller. Existing identities are only managed once adopted.
+var ErrExists = errors.New("the identity already exists")
+
+// ErrDeleting is returned by Finalize while the identity is being deleted: the deletion
+// is polled until the identity is gone.
+var ErrDeleting = errors.New("the identity is being deleted")
+
+// IsDeleting checks whether the error is ErrDeleting
+func IsDeleting(err error) bool {
+	return errors.Is(err, ErrDeleting)
+}
+
+// CheckExists returns ErrExists when the existing identity was not created by the controller,
+// i.e. the current name is not set, unless it is adopted
+func CheckExists(current, desired string, adopt bool) error {
+	if current != "" || adopt {
+		return nil
+	}
+	return Errorf(ErrorConflict, "%w: %s, set the %s annotation to adopt it", ErrExists, desired, consts.AdoptKey)
+}
+
+// CheckRename returns ErrRename when the desired name differs from the name the identity was created with
+func CheckRename(current, desired string) error {
+	if current == "" || current == desired {
+		return nil
+	}
+	return Errorf(ErrorInvalidSpec, "%w: from %s to %s, revert spec.name or delete and recreate the WorkloadIdentity", ErrRename, current, desired)
+}
+
 // Reconciler is the interface that facilitates Reconcile and Finalize
 type Reconciler interface {
 	Reconcile(ctx context.Context) error
 	Finalize(ctx context.Context) error
 }
 
+// ReadyReconciler is the interface of the reconcilers which check the availability of the reconciled identity
+type ReadyReconciler interface {
+	Ready(ctx context.Context) error
+}
+
 // FinalizeReconciler is the interface that facilitates PreFinalize
 type FinalizeReconciler interface {
 	PreFinalize(ctx context.Context) error
@@ -37,7 +78,26 @@ type Resource interface {
 	GetConditionedStatus() *v1alpha1.ConditionedStatus
 }
 
+// PlanResource is the interface of the resources which can be reconciled in dry-run mode
+type PlanResource interface {
+	Resource
+	GetPlan() []string
+	SetPlan(changes []string)
+}
+
+// DriftResource is the interface of the resources which report the drift reverted by the reconciler
+type DriftResource interface {
+	Resource
+	GetObservedGeneration() int64
+	SetObservedGeneration(generation int64)
+	GetObservedInputs() string
+	SetObservedInputs(inputs string)
+	SetDrift(drift []v1alpha1.Drift)
+}
+
 // test whether the CRs implements Resource any
 var (
 	_ = Resource(&v1alpha1.WorkloadIdentity{})
+	_ = PlanResource(&v1alpha1.WorkloadIdentity{})
+	_ = DriftResource(&v1alpha1.WorkloadIdentity{})
 )
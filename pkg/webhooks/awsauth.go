@@ -0,0 +1,161 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+//+kubebuilder:webhook:path=/mutate-identity-manager-io-v1alpha1-awsauth,mutating=true,failurePolicy=fail,sideEffects=None,groups=identity-manager.io,resources=awsauths,verbs=create;update,versions=v1alpha1,name=mawsauth.identity-manager.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-identity-manager-io-v1alpha1-awsauth,mutating=false,failurePolicy=fail,sideEffects=None,groups=identity-manager.io,resources=awsauths,verbs=create;update,versions=v1alpha1,name=vawsauth.identity-manager.io,admissionReviewVersions=v1
+
+var (
+	awsRoleArnPattern   = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/.+`)
+	awsUserArnPattern   = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:user/.+`)
+	awsAccountIDPattern = regexp.MustCompile(`^\d{12}$`)
+)
+
+const (
+	accessScopeTypeCluster   = "cluster"
+	accessScopeTypeNamespace = "namespace"
+)
+
+// AWSAuthWebhook defaults and validates AWSAuths
+type AWSAuthWebhook struct{}
+
+// Default implements admission.CustomDefaulter
+func (w *AWSAuthWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	res, ok := obj.(*v1alpha1.AWSAuth)
+	if !ok {
+		return fmt.Errorf("expected an AWSAuth but got a %T", obj)
+	}
+	if res.Spec.Mode == "" {
+		res.Spec.Mode = v1alpha1.AWSAuthModeConfigMap
+	}
+	for i := range res.Spec.MapRoles {
+		defaultAccessPolicies(res.Spec.MapRoles[i].AccessPolicies)
+	}
+	for i := range res.Spec.MapUsers {
+		defaultAccessPolicies(res.Spec.MapUsers[i].AccessPolicies)
+	}
+	return nil
+}
+
+func defaultAccessPolicies(policies []v1alpha1.AccessPolicy) {
+	for i := range policies {
+		if policies[i].AccessScopeType == "" {
+			policies[i].AccessScopeType = accessScopeTypeCluster
+		}
+	}
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (w *AWSAuthWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	res, ok := obj.(*v1alpha1.AWSAuth)
+	if !ok {
+		return fmt.Errorf("expected an AWSAuth but got a %T", obj)
+	}
+	return toInvalid("AWSAuth", res.Name, validateAWSAuth(res))
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (w *AWSAuthWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldRes, ok := oldObj.(*v1alpha1.AWSAuth)
+	if !ok {
+		return fmt.Errorf("expected an AWSAuth but got a %T", oldObj)
+	}
+	res, ok := newObj.(*v1alpha1.AWSAuth)
+	if !ok {
+		return fmt.Errorf("expected an AWSAuth but got a %T", newObj)
+	}
+	errs := validateAWSAuth(res)
+	errs = append(errs, validateAWSAuthUpdate(oldRes, res)...)
+	return toInvalid("AWSAuth", res.Name, errs)
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (w *AWSAuthWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+func validateAWSAuth(res *v1alpha1.AWSAuth) field.ErrorList {
+	errs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+	if res.Spec.Mode == v1alpha1.AWSAuthModeAccessEntries && res.Spec.AccessEntries == nil {
+		errs = append(errs, field.Required(specPath.Child("accessEntries"), "required for mode AccessEntries"))
+	}
+	roleArns := map[string]bool{}
+	for i, item := range res.Spec.MapRoles {
+		path := specPath.Child("mapRoles").Index(i)
+		if !awsRoleArnPattern.MatchString(item.RoleArn) {
+			errs = append(errs, field.Invalid(path.Child("rolearn"), item.RoleArn, "must be an IAM role arn"))
+		}
+		if roleArns[item.RoleArn] {
+			errs = append(errs, field.Duplicate(path.Child("rolearn"), item.RoleArn))
+		}
+		roleArns[item.RoleArn] = true
+		errs = append(errs, validateAccessPolicies(path.Child("accessPolicies"), item.AccessPolicies)...)
+	}
+	userArns := map[string]bool{}
+	for i, item := range res.Spec.MapUsers {
+		path := specPath.Child("mapUsers").Index(i)
+		if !awsUserArnPattern.MatchString(item.UserArn) {
+			errs = append(errs, field.Invalid(path.Child("userarn"), item.UserArn, "must be an IAM user arn"))
+		}
+		if userArns[item.UserArn] {
+			errs = append(errs, field.Duplicate(path.Child("userarn"), item.UserArn))
+		}
+		userArns[item.UserArn] = true
+		errs = append(errs, validateAccessPolicies(path.Child("accessPolicies"), item.AccessPolicies)...)
+	}
+	for i, account := range res.Spec.MapAccounts {
+		if !awsAccountIDPattern.MatchString(account) {
+			errs = append(errs, field.Invalid(specPath.Child("mapAccounts").Index(i), account, "must be a 12 digit AWS account id"))
+		}
+	}
+	return errs
+}
+
+// validateAWSAuthUpdate checks that the cluster and the credentials of the managed access entries are kept,
+// they are only removed from them. Switching to the ConfigMap mode first removes the access entries.
+func validateAWSAuthUpdate(oldRes, res *v1alpha1.AWSAuth) field.ErrorList {
+	errs := field.ErrorList{}
+	if !oldRes.OwnsAccessEntries() || equality.Semantic.DeepEqual(oldRes.Spec.AccessEntries, res.Spec.AccessEntries) {
+		return errs
+	}
+	path := field.NewPath("spec", "accessEntries")
+	if res.Spec.AccessEntries == nil {
+		return append(errs, field.Forbidden(path, "cannot be removed while access entries are managed, switch to mode ConfigMap first"))
+	}
+	if oldRes.Spec.AccessEntries.ClusterName != res.Spec.AccessEntries.ClusterName {
+		errs = append(errs, field.Forbidden(path.Child("clusterName"), "cannot be changed while access entries are managed, switch to mode ConfigMap first"))
+	}
+	if !equality.Semantic.DeepEqual(oldRes.Spec.AccessEntries.Credentials, res.Spec.AccessEntries.Credentials) {
+		errs = append(errs, field.Forbidden(path.Child("credentials"), "cannot be changed while access entries are managed, switch to mode ConfigMap first"))
+	}
+	return errs
+}
+
+// validateAccessPolicies checks the scopes of the access policies.
+// They are kept in the ConfigMap mode, so that switching modes back and forth is possible.
+func validateAccessPolicies(path *field.Path, policies []v1alpha1.AccessPolicy) field.ErrorList {
+	errs := field.ErrorList{}
+	for i, policy := range policies {
+		switch policy.AccessScopeType {
+		case accessScopeTypeNamespace:
+			if len(policy.Namespaces) == 0 {
+				errs = append(errs, field.Required(path.Index(i).Child("namespaces"), "required for the namespace access scope"))
+			}
+		default:
+			if len(policy.Namespaces) > 0 {
+				errs = append(errs, field.Forbidden(path.Index(i).Child("namespaces"), "only allowed for the namespace access scope"))
+			}
+		}
+	}
+	return errs
+}
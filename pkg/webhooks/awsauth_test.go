@@ -0,0 +1,156 @@
+package webhooks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	testRoleArn = "arn:aws:iam::123456789012:role/admin"
+	testUserArn = "arn:aws:iam::123456789012:user/admin"
+)
+
+func TestAWSAuthDefault(t *testing.T) {
+	res := &v1alpha1.AWSAuth{
+		Spec: v1alpha1.AWSAuthSpec{
+			MapRoles: []v1alpha1.MapRoleItem{{
+				RoleArn:        testRoleArn,
+				AccessPolicies: []v1alpha1.AccessPolicy{{PolicyArn: "a"}, {PolicyArn: "b", AccessScopeType: "namespace"}},
+			}},
+			MapUsers: []v1alpha1.MapUserItem{{
+				UserArn:        testUserArn,
+				AccessPolicies: []v1alpha1.AccessPolicy{{PolicyArn: "a"}},
+			}},
+		},
+	}
+	err := (&AWSAuthWebhook{}).Default(context.Background(), res)
+	assert.Nil(t, err)
+	assert.Equal(t, v1alpha1.AWSAuthModeConfigMap, res.Spec.Mode)
+	assert.Equal(t, "cluster", res.Spec.MapRoles[0].AccessPolicies[0].AccessScopeType)
+	assert.Equal(t, "namespace", res.Spec.MapRoles[0].AccessPolicies[1].AccessScopeType)
+	assert.Equal(t, "cluster", res.Spec.MapUsers[0].AccessPolicies[0].AccessScopeType)
+}
+
+func TestAWSAuthValidate(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		spec     v1alpha1.AWSAuthSpec
+		expected []string
+	}{
+		{
+			desc: "valid",
+			spec: v1alpha1.AWSAuthSpec{
+				MapRoles:    []v1alpha1.MapRoleItem{{RoleArn: testRoleArn, Username: "admin", Groups: []string{"system:masters"}}},
+				MapUsers:    []v1alpha1.MapUserItem{{UserArn: testUserArn, Username: "admin", Groups: []string{"system:masters"}}},
+				MapAccounts: []string{"123456789012"},
+			},
+		},
+		{
+			desc:     "missing access entries",
+			spec:     v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeAccessEntries},
+			expected: []string{"spec.accessEntries: Required value: required for mode AccessEntries"},
+		},
+		{
+			desc: "invalid and duplicate arns",
+			spec: v1alpha1.AWSAuthSpec{
+				MapRoles: []v1alpha1.MapRoleItem{
+					{RoleArn: testRoleArn},
+					{RoleArn: testRoleArn},
+					{RoleArn: testUserArn},
+				},
+				MapUsers:    []v1alpha1.MapUserItem{{UserArn: testRoleArn}},
+				MapAccounts: []string{"1234"},
+			},
+			expected: []string{
+				`spec.mapRoles[1].rolearn: Duplicate value: "` + testRoleArn + `"`,
+				`spec.mapRoles[2].rolearn: Invalid value: "` + testUserArn + `": must be an IAM role arn`,
+				`spec.mapUsers[0].userarn: Invalid value: "` + testRoleArn + `": must be an IAM user arn`,
+				`spec.mapAccounts[0]: Invalid value: "1234": must be a 12 digit AWS account id`,
+			},
+		},
+		{
+			desc: "invalid access scopes",
+			spec: v1alpha1.AWSAuthSpec{
+				Mode:          v1alpha1.AWSAuthModeAccessEntries,
+				AccessEntries: &v1alpha1.AWSAuthAccessEntries{ClusterName: "prod"},
+				MapRoles: []v1alpha1.MapRoleItem{{
+					RoleArn: testRoleArn,
+					AccessPolicies: []v1alpha1.AccessPolicy{
+						{PolicyArn: "a", AccessScopeType: "namespace"},
+						{PolicyArn: "b", AccessScopeType: "cluster", Namespaces: []string{"dev"}},
+					},
+				}},
+			},
+			expected: []string{
+				"spec.mapRoles[0].accessPolicies[0].namespaces: Required value: required for the namespace access scope",
+				"spec.mapRoles[0].accessPolicies[1].namespaces: Forbidden: only allowed for the namespace access scope",
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.desc, func(t *testing.T) {
+			res := &v1alpha1.AWSAuth{ObjectMeta: metav1.ObjectMeta{Name: "aws-auth"}, Spec: testCase.spec}
+			err := (&AWSAuthWebhook{}).ValidateCreate(context.Background(), res)
+			assert.Equal(t, testCase.expected, causes(err))
+		})
+	}
+}
+
+func TestAWSAuthValidateUpdate(t *testing.T) {
+	accessEntries := &v1alpha1.AWSAuthAccessEntries{ClusterName: "prod"}
+	testCases := []struct {
+		desc     string
+		oldSpec  v1alpha1.AWSAuthSpec
+		oldMode  v1alpha1.AWSAuthMode
+		spec     v1alpha1.AWSAuthSpec
+		expected []string
+	}{
+		{
+			desc:    "access entries kept",
+			oldSpec: v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeAccessEntries, AccessEntries: accessEntries},
+			oldMode: v1alpha1.AWSAuthModeAccessEntries,
+			spec:    v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeConfigMap, AccessEntries: accessEntries},
+		},
+		{
+			desc:     "access entries removed while managed",
+			oldSpec:  v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeConfigMap, AccessEntries: accessEntries},
+			oldMode:  v1alpha1.AWSAuthModeAccessEntries,
+			spec:     v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeConfigMap},
+			expected: []string{"spec.accessEntries: Forbidden: cannot be removed while access entries are managed, switch to mode ConfigMap first"},
+		},
+		{
+			desc:    "cluster and credentials changed while managed",
+			oldSpec: v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeAccessEntries, AccessEntries: accessEntries},
+			spec: v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeAccessEntries, AccessEntries: &v1alpha1.AWSAuthAccessEntries{
+				ClusterName: "dev",
+				Credentials: &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceEnvironment},
+			}},
+			expected: []string{
+				"spec.accessEntries.clusterName: Forbidden: cannot be changed while access entries are managed, switch to mode ConfigMap first",
+				"spec.accessEntries.credentials: Forbidden: cannot be changed while access entries are managed, switch to mode ConfigMap first",
+			},
+		},
+		{
+			desc:    "access entries removed in ConfigMap mode",
+			oldSpec: v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeConfigMap, AccessEntries: accessEntries},
+			oldMode: v1alpha1.AWSAuthModeConfigMap,
+			spec:    v1alpha1.AWSAuthSpec{Mode: v1alpha1.AWSAuthModeConfigMap},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.desc, func(t *testing.T) {
+			oldRes := &v1alpha1.AWSAuth{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws-auth"},
+				Spec:       testCase.oldSpec,
+				Status:     v1alpha1.AWSAuthStatus{Mode: testCase.oldMode},
+			}
+			res := &v1alpha1.AWSAuth{ObjectMeta: metav1.ObjectMeta{Name: "aws-auth"}, Spec: testCase.spec}
+			err := (&AWSAuthWebhook{}).ValidateUpdate(context.Background(), oldRes, res)
+			assert.Equal(t, testCase.expected, causes(err))
+		})
+	}
+}
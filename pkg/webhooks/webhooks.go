@@ -0,0 +1,24 @@
+package webhooks
+
+import (
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/options"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// SetupWithManager registers the defaulting and validating webhooks of the CRDs with the Manager.
+func SetupWithManager(mgr ctrl.Manager, options *options.Options) error {
+	err := ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.WorkloadIdentity{}).
+		WithDefaulter(&WorkloadIdentityWebhook{Options: options}).
+		WithValidator(&WorkloadIdentityWebhook{Options: options}).
+		Complete()
+	if err != nil {
+		return err
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.AWSAuth{}).
+		WithDefaulter(&AWSAuthWebhook{}).
+		WithValidator(&AWSAuthWebhook{}).
+		Complete()
+}
@@ -0,0 +1,229 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/options"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/awsx/iam"
+	"github.com/invisibl-cloud/identity-manager/pkg/providers/gcpx"
+	"github.com/invisibl-cloud/identity-manager/pkg/util"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+//+kubebuilder:webhook:path=/mutate-identity-manager-io-v1alpha1-workloadidentity,mutating=true,failurePolicy=fail,sideEffects=None,groups=identity-manager.io,resources=workloadidentities,verbs=create;update,versions=v1alpha1,name=mworkloadidentity.identity-manager.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-identity-manager-io-v1alpha1-workloadidentity,mutating=false,failurePolicy=fail,sideEffects=None,groups=identity-manager.io,resources=workloadidentities,verbs=create;update,versions=v1alpha1,name=vworkloadidentity.identity-manager.io,admissionReviewVersions=v1
+
+// AWS role names: alphanumeric and +=,.@_-
+var awsRoleNamePattern = regexp.MustCompile(`^[\w+=,.@-]+$`)
+
+// GCP service account ids: 6-30 lowercase letters, digits or hyphens, starting with a letter
+var gcpAccountIDPattern = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+const (
+	gcpMinAccountIDLength = 6
+	awsMinSessionDuration = 3600
+	awsMaxSessionDuration = 43200
+)
+
+// WorkloadIdentityWebhook defaults and validates WorkloadIdentities
+type WorkloadIdentityWebhook struct {
+	Options *options.Options
+}
+
+// Default implements admission.CustomDefaulter
+func (w *WorkloadIdentityWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	res, ok := obj.(*v1alpha1.WorkloadIdentity)
+	if !ok {
+		return fmt.Errorf("expected a WorkloadIdentity but got a %T", obj)
+	}
+	spec := &res.Spec
+	// infer the provider from the only provider spec set
+	if spec.Provider == "" {
+		switch {
+		case spec.AWS != nil && spec.Azure == nil && spec.GCP == nil:
+			spec.Provider = v1alpha1.ProviderAWS
+		case spec.Azure != nil && spec.AWS == nil && spec.GCP == nil:
+			spec.Provider = v1alpha1.ProviderAzure
+		case spec.GCP != nil && spec.AWS == nil && spec.Azure == nil:
+			spec.Provider = v1alpha1.ProviderGCP
+		}
+	}
+	if spec.Credentials != nil && spec.Credentials.Source == "" && spec.Credentials.SecretRef != nil {
+		spec.Credentials.Source = v1alpha1.CredentialsSourceSecret
+	}
+	if spec.AWS != nil && spec.AWS.Path == "" {
+		spec.AWS.Path = "/"
+	}
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (w *WorkloadIdentityWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	res, ok := obj.(*v1alpha1.WorkloadIdentity)
+	if !ok {
+		return fmt.Errorf("expected a WorkloadIdentity but got a %T", obj)
+	}
+	return toInvalid("WorkloadIdentity", res.Name, w.validate(res))
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (w *WorkloadIdentityWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	res, ok := newObj.(*v1alpha1.WorkloadIdentity)
+	if !ok {
+		return fmt.Errorf("expected a WorkloadIdentity but got a %T", newObj)
+	}
+	old, ok := oldObj.(*v1alpha1.WorkloadIdentity)
+	if !ok {
+		return fmt.Errorf("expected a WorkloadIdentity but got a %T", oldObj)
+	}
+	errs := w.validate(res)
+	if old.Spec.Provider != res.Spec.Provider {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "provider"), "is immutable"))
+	}
+	// identities are never renamed, see types.CheckRename
+	if old.Status.Name != "" && w.identityName(old) != w.identityName(res) {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "name"),
+			"is immutable once the identity is created: delete and recreate the WorkloadIdentity to rename it"))
+	}
+	return toInvalid("WorkloadIdentity", res.Name, errs)
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (w *WorkloadIdentityWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+func (w *WorkloadIdentityWebhook) validate(res *v1alpha1.WorkloadIdentity) field.ErrorList {
+	errs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+	// the spec of the provider is required, the others are not allowed
+	providers := []struct {
+		provider v1alpha1.Provider
+		path     *field.Path
+		isSet    bool
+	}{
+		{v1alpha1.ProviderAWS, specPath.Child("aws"), res.Spec.AWS != nil},
+		{v1alpha1.ProviderAzure, specPath.Child("azure"), res.Spec.Azure != nil},
+		{v1alpha1.ProviderGCP, specPath.Child("gcp"), res.Spec.GCP != nil},
+	}
+	for _, p := range providers {
+		if p.provider == res.Spec.Provider && !p.isSet {
+			errs = append(errs, field.Required(p.path, fmt.Sprintf("required for provider %s", res.Spec.Provider)))
+		}
+		if p.provider != res.Spec.Provider && p.isSet {
+			errs = append(errs, field.Forbidden(p.path, fmt.Sprintf("not allowed for provider %s", res.Spec.Provider)))
+		}
+	}
+	errs = append(errs, validateCredentials(specPath.Child("credentials"), res.Spec.Credentials)...)
+	switch res.Spec.Provider {
+	case v1alpha1.ProviderAWS:
+		errs = append(errs, w.validateAWS(res)...)
+	case v1alpha1.ProviderGCP:
+		errs = append(errs, validateGCP(res)...)
+	}
+	return errs
+}
+
+func (w *WorkloadIdentityWebhook) validateAWS(res *v1alpha1.WorkloadIdentity) field.ErrorList {
+	errs := field.ErrorList{}
+	if res.Spec.AWS == nil {
+		return errs
+	}
+	awsPath := field.NewPath("spec", "aws")
+	roleName := iam.RoleName(res, w.Options)
+	namePath := field.NewPath("spec", "name")
+	if len(roleName) > iam.MaxRoleNameLength {
+		errs = append(errs, field.TooLong(namePath, roleName, iam.MaxRoleNameLength))
+	}
+	if !awsRoleNamePattern.MatchString(roleName) {
+		errs = append(errs, field.Invalid(namePath, roleName, "must contain only alphanumeric characters and +=,.@_-"))
+	}
+	duration := res.Spec.AWS.MaxSessionDuration
+	if duration != 0 && (duration < awsMinSessionDuration || duration > awsMaxSessionDuration) {
+		errs = append(errs, field.Invalid(awsPath.Child("maxSessionDuration"), duration,
+			fmt.Sprintf("must be between %d and %d seconds", awsMinSessionDuration, awsMaxSessionDuration)))
+	}
+	if res.Spec.AWS.AssumeRolePolicy != "" && res.Spec.AWS.AssumeRolePolicyFrom != nil {
+		errs = append(errs, field.Forbidden(awsPath.Child("assumeRolePolicyFrom"), "not allowed with assumeRolePolicy"))
+	}
+	if res.Spec.AWS.AssumeRolePolicyFrom != nil {
+		errs = append(errs, validatePolicyValueFrom(awsPath.Child("assumeRolePolicyFrom"), res.Spec.AWS.AssumeRolePolicyFrom)...)
+	}
+	for name, from := range res.Spec.AWS.InlinePoliciesFrom {
+		from := from
+		errs = append(errs, validatePolicyValueFrom(awsPath.Child("inlinePoliciesFrom").Key(name), &from)...)
+	}
+	return errs
+}
+
+// validateCredentials checks that the fields of the credentials are the ones of their source
+func validateCredentials(path *field.Path, creds *v1alpha1.Credentials) field.ErrorList {
+	errs := field.ErrorList{}
+	if creds == nil {
+		return errs
+	}
+	if creds.Source == v1alpha1.CredentialsSourceFilesystem && creds.Path == "" {
+		errs = append(errs, field.Required(path.Child("path"), fmt.Sprintf("required for source %s", creds.Source)))
+	}
+	if creds.Source != v1alpha1.CredentialsSourceFilesystem && creds.Path != "" {
+		errs = append(errs, field.Forbidden(path.Child("path"), fmt.Sprintf("only allowed for source %s", v1alpha1.CredentialsSourceFilesystem)))
+	}
+	if creds.Source != "" && creds.Source != v1alpha1.CredentialsSourceSecret && creds.SecretRef != nil {
+		errs = append(errs, field.Forbidden(path.Child("secretRef"), fmt.Sprintf("only allowed for source %s", v1alpha1.CredentialsSourceSecret)))
+	}
+	return errs
+}
+
+func validatePolicyValueFrom(path *field.Path, from *v1alpha1.PolicyValueFrom) field.ErrorList {
+	errs := field.ErrorList{}
+	if from.ConfigMapKeyRef == nil && from.SecretKeyRef == nil {
+		errs = append(errs, field.Required(path, "one of configMapKeyRef or secretKeyRef is required"))
+	}
+	if from.ConfigMapKeyRef != nil && from.SecretKeyRef != nil {
+		errs = append(errs, field.Forbidden(path, "only one of configMapKeyRef or secretKeyRef can be set"))
+	}
+	return errs
+}
+
+// identityName returns the name of the cloud identity as derived by the controller of the provider
+func (w *WorkloadIdentityWebhook) identityName(res *v1alpha1.WorkloadIdentity) string {
+	switch res.Spec.Provider {
+	case v1alpha1.ProviderAWS:
+		return iam.TruncatedRoleName(res, w.Options)
+	case v1alpha1.ProviderGCP:
+		return gcpx.ServiceAccountName(res)
+	}
+	return util.DefaultString(res.Spec.Name, res.Name)
+}
+
+func validateGCP(res *v1alpha1.WorkloadIdentity) field.ErrorList {
+	errs := field.ErrorList{}
+	namePath := field.NewPath("spec", "name")
+	if res.Spec.Name == "" {
+		namePath = field.NewPath("metadata", "name")
+	}
+	// names longer than the limit are shortened by the controller
+	accountID := gcpx.ServiceAccountName(res)
+	if len(accountID) < gcpMinAccountIDLength || len(accountID) > gcpx.MaxAccountIDLength {
+		errs = append(errs, field.Invalid(namePath, accountID,
+			fmt.Sprintf("service account id must be between %d and %d characters", gcpMinAccountIDLength, gcpx.MaxAccountIDLength)))
+	}
+	if !gcpAccountIDPattern.MatchString(accountID) {
+		errs = append(errs, field.Invalid(namePath, accountID,
+			"service account id must contain only lowercase letters, digits or hyphens and start with a letter"))
+	}
+	return errs
+}
+
+// toInvalid converts the validation errors into an invalid api error
+func toInvalid(kind, name string, errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(v1alpha1.GroupVersion.WithKind(kind).GroupKind(), name, errs)
+}
@@ -0,0 +1,263 @@
+package webhooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/invisibl-cloud/identity-manager/api/v1alpha1"
+	"github.com/invisibl-cloud/identity-manager/pkg/options"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newWorkloadIdentity(name string, spec v1alpha1.WorkloadIdentitySpec) *v1alpha1.WorkloadIdentity {
+	return &v1alpha1.WorkloadIdentity{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "dev", UID: "6f1c1b0e-93c4-4d7e-8a53-2b0f4e1d9c27"},
+		Spec:       spec,
+	}
+}
+
+func TestWorkloadIdentityDefault(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		spec     v1alpha1.WorkloadIdentitySpec
+		expected v1alpha1.WorkloadIdentitySpec
+	}{
+		{
+			desc: "infers the provider and defaults the aws path",
+			spec: v1alpha1.WorkloadIdentitySpec{AWS: &v1alpha1.WorkloadIdentityAWS{}},
+			expected: v1alpha1.WorkloadIdentitySpec{
+				Provider: v1alpha1.ProviderAWS,
+				AWS:      &v1alpha1.WorkloadIdentityAWS{Path: "/"},
+			},
+		},
+		{
+			desc: "keeps the provider when several provider specs are set",
+			spec: v1alpha1.WorkloadIdentitySpec{
+				Azure: &v1alpha1.WorkloadIdentityAzure{},
+				GCP:   &v1alpha1.WorkloadIdentityGCP{},
+			},
+			expected: v1alpha1.WorkloadIdentitySpec{
+				Azure: &v1alpha1.WorkloadIdentityAzure{},
+				GCP:   &v1alpha1.WorkloadIdentityGCP{},
+			},
+		},
+		{
+			desc: "defaults the credentials source",
+			spec: v1alpha1.WorkloadIdentitySpec{
+				GCP:         &v1alpha1.WorkloadIdentityGCP{},
+				Credentials: &v1alpha1.Credentials{SecretRef: &v1alpha1.SecretRef{Name: "creds"}},
+			},
+			expected: v1alpha1.WorkloadIdentitySpec{
+				Provider: v1alpha1.ProviderGCP,
+				GCP:      &v1alpha1.WorkloadIdentityGCP{},
+				Credentials: &v1alpha1.Credentials{
+					Source:    v1alpha1.CredentialsSourceSecret,
+					SecretRef: &v1alpha1.SecretRef{Name: "creds"},
+				},
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.desc, func(t *testing.T) {
+			res := newWorkloadIdentity("app", testCase.spec)
+			err := (&WorkloadIdentityWebhook{}).Default(context.Background(), res)
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expected, res.Spec)
+		})
+	}
+}
+
+func TestWorkloadIdentityValidate(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		name     string
+		spec     v1alpha1.WorkloadIdentitySpec
+		old      *v1alpha1.WorkloadIdentitySpec
+		oldName  string
+		options  *options.Options
+		expected []string
+	}{
+		{
+			desc: "valid aws",
+			spec: v1alpha1.WorkloadIdentitySpec{Provider: v1alpha1.ProviderAWS, AWS: &v1alpha1.WorkloadIdentityAWS{}},
+		},
+		{
+			desc: "missing provider spec",
+			spec: v1alpha1.WorkloadIdentitySpec{Provider: v1alpha1.ProviderAzure, AWS: &v1alpha1.WorkloadIdentityAWS{}},
+			expected: []string{
+				"spec.aws: Forbidden: not allowed for provider Azure",
+				"spec.azure: Required value: required for provider Azure",
+			},
+		},
+		{
+			desc: "aws role name too long with the name prefix",
+			spec: v1alpha1.WorkloadIdentitySpec{
+				Name:     strings.Repeat("a", 60),
+				Provider: v1alpha1.ProviderAWS,
+				AWS:      &v1alpha1.WorkloadIdentityAWS{},
+			},
+			options:  &options.Options{NamePrefix: "prod-"},
+			expected: []string{"spec.name: Too long: must have at most 64 bytes"},
+		},
+		{
+			desc: "invalid aws role name and session duration",
+			spec: v1alpha1.WorkloadIdentitySpec{
+				Name:     "app/role",
+				Provider: v1alpha1.ProviderAWS,
+				AWS:      &v1alpha1.WorkloadIdentityAWS{MaxSessionDuration: 60},
+			},
+			expected: []string{
+				`spec.name: Invalid value: "app/role": must contain only alphanumeric characters and +=,.@_-`,
+				"spec.aws.maxSessionDuration: Invalid value: 60: must be between 3600 and 43200 seconds",
+			},
+		},
+		{
+			desc: "invalid aws policy sources",
+			spec: v1alpha1.WorkloadIdentitySpec{
+				Provider: v1alpha1.ProviderAWS,
+				AWS: &v1alpha1.WorkloadIdentityAWS{
+					AssumeRolePolicy:     "{}",
+					AssumeRolePolicyFrom: &v1alpha1.PolicyValueFrom{},
+					InlinePoliciesFrom: map[string]v1alpha1.PolicyValueFrom{
+						"s3": {
+							ConfigMapKeyRef: &v1alpha1.KeyRef{Name: "a", Key: "b"},
+							SecretKeyRef:    &v1alpha1.KeyRef{Name: "a", Key: "b"},
+						},
+					},
+				},
+			},
+			expected: []string{
+				"spec.aws.assumeRolePolicyFrom: Forbidden: not allowed with assumeRolePolicy",
+				"spec.aws.assumeRolePolicyFrom: Required value: one of configMapKeyRef or secretKeyRef is required",
+				"spec.aws.inlinePoliciesFrom[s3]: Forbidden: only one of configMapKeyRef or secretKeyRef can be set",
+			},
+		},
+		{
+			desc: "invalid gcp account id",
+			spec: v1alpha1.WorkloadIdentitySpec{
+				Name:     "App",
+				Provider: v1alpha1.ProviderGCP,
+				GCP:      &v1alpha1.WorkloadIdentityGCP{},
+			},
+			expected: []string{
+				`spec.name: Invalid value: "App": service account id must be between 6 and 30 characters`,
+				`spec.name: Invalid value: "App": service account id must contain only lowercase letters, digits or hyphens and start with a letter`,
+			},
+		},
+		{
+			desc: "long gcp resource names are shortened by the controller",
+			name: strings.Repeat("a", 40),
+			spec: v1alpha1.WorkloadIdentitySpec{Provider: v1alpha1.ProviderGCP, GCP: &v1alpha1.WorkloadIdentityGCP{}},
+		},
+		{
+			desc: "long gcp account ids are shortened by the controller",
+			spec: v1alpha1.WorkloadIdentitySpec{
+				Name:     strings.Repeat("a", 40),
+				Provider: v1alpha1.ProviderGCP,
+				GCP:      &v1alpha1.WorkloadIdentityGCP{},
+			},
+		},
+		{
+			desc: "valid filesystem credentials",
+			spec: v1alpha1.WorkloadIdentitySpec{
+				Provider:    v1alpha1.ProviderAWS,
+				AWS:         &v1alpha1.WorkloadIdentityAWS{},
+				Credentials: &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceFilesystem, Path: "/var/run/secrets/aws/token"},
+			},
+		},
+		{
+			desc: "invalid credentials of their source",
+			spec: v1alpha1.WorkloadIdentitySpec{
+				Provider: v1alpha1.ProviderAWS,
+				AWS:      &v1alpha1.WorkloadIdentityAWS{},
+				Credentials: &v1alpha1.Credentials{
+					Source:    v1alpha1.CredentialsSourceInjectedIdentity,
+					SecretRef: &v1alpha1.SecretRef{Name: "creds"},
+					Path:      "/var/run/secrets/aws/token",
+				},
+			},
+			expected: []string{
+				"spec.credentials.path: Forbidden: only allowed for source Filesystem",
+				"spec.credentials.secretRef: Forbidden: only allowed for source Secret",
+			},
+		},
+		{
+			desc: "missing path of filesystem credentials",
+			spec: v1alpha1.WorkloadIdentitySpec{
+				Provider:    v1alpha1.ProviderAWS,
+				AWS:         &v1alpha1.WorkloadIdentityAWS{},
+				Credentials: &v1alpha1.Credentials{Source: v1alpha1.CredentialsSourceFilesystem},
+			},
+			expected: []string{"spec.credentials.path: Required value: required for source Filesystem"},
+		},
+		{
+			desc:     "provider is immutable",
+			spec:     v1alpha1.WorkloadIdentitySpec{Provider: v1alpha1.ProviderGCP, GCP: &v1alpha1.WorkloadIdentityGCP{}},
+			old:      &v1alpha1.WorkloadIdentitySpec{Provider: v1alpha1.ProviderAWS, AWS: &v1alpha1.WorkloadIdentityAWS{}},
+			expected: []string{"spec.provider: Forbidden: is immutable"},
+		},
+		{
+			desc:     "name is immutable once the identity is created",
+			spec:     v1alpha1.WorkloadIdentitySpec{Name: "new-name", Provider: v1alpha1.ProviderGCP, GCP: &v1alpha1.WorkloadIdentityGCP{}},
+			old:      &v1alpha1.WorkloadIdentitySpec{Name: "old-name", Provider: v1alpha1.ProviderGCP, GCP: &v1alpha1.WorkloadIdentityGCP{}},
+			oldName:  "old-name",
+			expected: []string{"spec.name: Forbidden: is immutable once the identity is created: delete and recreate the WorkloadIdentity to rename it"},
+		},
+		{
+			desc:    "name can be set to the aws role name derived by the controller",
+			spec:    v1alpha1.WorkloadIdentitySpec{Name: "dev-app-identity", Provider: v1alpha1.ProviderAWS, AWS: &v1alpha1.WorkloadIdentityAWS{}},
+			old:     &v1alpha1.WorkloadIdentitySpec{Provider: v1alpha1.ProviderAWS, AWS: &v1alpha1.WorkloadIdentityAWS{}},
+			oldName: "dev-app-identity",
+		},
+		{
+			desc:    "name can be set to the gcp account id derived by the controller",
+			name:    strings.Repeat("a", 40),
+			spec:    v1alpha1.WorkloadIdentitySpec{Name: strings.Repeat("a", 20) + "2b0f4e1d9c", Provider: v1alpha1.ProviderGCP, GCP: &v1alpha1.WorkloadIdentityGCP{}},
+			old:     &v1alpha1.WorkloadIdentitySpec{Provider: v1alpha1.ProviderGCP, GCP: &v1alpha1.WorkloadIdentityGCP{}},
+			oldName: strings.Repeat("a", 20) + "2b0f4e1d9c",
+		},
+		{
+			desc: "name can change before the identity is created",
+			spec: v1alpha1.WorkloadIdentitySpec{Name: "new-name", Provider: v1alpha1.ProviderGCP, GCP: &v1alpha1.WorkloadIdentityGCP{}},
+			old:  &v1alpha1.WorkloadIdentitySpec{Name: "old-name", Provider: v1alpha1.ProviderGCP, GCP: &v1alpha1.WorkloadIdentityGCP{}},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.desc, func(t *testing.T) {
+			name := testCase.name
+			if name == "" {
+				name = "app-identity"
+			}
+			res := newWorkloadIdentity(name, testCase.spec)
+			webhook := &WorkloadIdentityWebhook{Options: testCase.options}
+			var err error
+			if testCase.old != nil {
+				old := newWorkloadIdentity(name, *testCase.old)
+				old.Status.Name = testCase.oldName
+				err = webhook.ValidateUpdate(context.Background(), old, res)
+			} else {
+				err = webhook.ValidateCreate(context.Background(), res)
+			}
+			assert.Equal(t, testCase.expected, causes(err))
+		})
+	}
+}
+
+// causes returns the messages of the causes of an invalid api error
+func causes(err error) []string {
+	if err == nil {
+		return nil
+	}
+	statusErr, ok := err.(*apierrors.StatusError)
+	if !ok || statusErr.ErrStatus.Details == nil {
+		return []string{err.Error()}
+	}
+	msgs := []string{}
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		msgs = append(msgs, cause.Field+": "+cause.Message)
+	}
+	return msgs
+}